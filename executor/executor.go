@@ -38,13 +38,48 @@ func main() {
 	var orchestratorPort int
 	var workers int
 	var metricsPort int
+	var maxRequestNames int
+	transport := worker.DefaultTransportConfig()
 	flag.StringVar(&orchestratorHost, "host", "imgr-orchestrator",
 		"The hostname of the orchestrator process")
 	flag.IntVar(&orchestratorPort, "port", 8099, "The port of the orchestrator process")
 	flag.IntVar(&workers, "workers", 1, "The number of worker threads to start")
 	flag.IntVar(&metricsPort, "metrics-port", 9100, "The port to expose executor metrics on")
+	flag.IntVar(&maxRequestNames, "max-request-names", metrics.DefaultMaxRequestNames,
+		"The number of distinct request names to publish as metric labels before folding the rest into \"other\"")
+	flag.DurationVar(&transport.RequestTimeout, "request-timeout", transport.RequestTimeout,
+		"Per-request timeout for target requests, including the response body (0 is bounded by the job window only)")
+	flag.DurationVar(&transport.DialTimeout, "dial-timeout", transport.DialTimeout,
+		"Timeout for establishing a TCP connection to the target")
+	flag.DurationVar(&transport.TLSHandshakeTimeout, "tls-handshake-timeout", transport.TLSHandshakeTimeout,
+		"Timeout for the TLS handshake with the target")
+	flag.DurationVar(&transport.ResponseHeaderTimeout, "response-header-timeout", transport.ResponseHeaderTimeout,
+		"Timeout for the target's response headers after the request is written (0 disables)")
+	flag.IntVar(&transport.MaxIdleConnsPerHost, "max-idle-conns-per-host", transport.MaxIdleConnsPerHost,
+		"Idle keep-alive connections to keep per target host")
+	flag.BoolVar(&transport.DisableKeepAlives, "disable-keep-alives", transport.DisableKeepAlives,
+		"Open a new connection for every target request")
+	flag.StringVar(&transport.HTTPVersion, "http-version", transport.HTTPVersion,
+		"Force the HTTP version for target requests: 1.1 or 2 (empty negotiates HTTP/2 over TLS)")
+	flag.StringVar(&transport.TLS.CAFile, "tls-ca-file", transport.TLS.CAFile,
+		"PEM CA bundle to verify target certificates with instead of the system roots")
+	flag.StringVar(&transport.TLS.CertFile, "tls-cert-file", transport.TLS.CertFile,
+		"PEM client certificate for targets that require mutual TLS")
+	flag.StringVar(&transport.TLS.KeyFile, "tls-key-file", transport.TLS.KeyFile,
+		"PEM private key for tls-cert-file")
+	flag.StringVar(&transport.TLS.ServerName, "tls-server-name", transport.TLS.ServerName,
+		"Server name sent for SNI and verified against the target certificate")
+	flag.StringVar(&transport.TLS.MinVersion, "tls-min-version", transport.TLS.MinVersion,
+		"Minimum TLS version for target requests: 1.0, 1.1, 1.2 or 1.3")
+	flag.BoolVar(&transport.TLS.InsecureSkipVerify, "tls-insecure-skip-verify", transport.TLS.InsecureSkipVerify,
+		"Skip verification of target certificates")
 	flag.Parse()
 
+	if err := worker.ConfigureTransport(transport); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	workerUuid, err := uuid.NewRandom()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to generate executor id: %v", err)
@@ -52,7 +87,7 @@ func main() {
 	}
 	workerId = types.WorkerId{Id: workerUuid.String(), Workers: workers}
 
-	collector := metrics.NewPrometheusMetricsCollector(prometheus.DefaultRegisterer)
+	collector := metrics.NewPrometheusMetricsCollectorWithNameLimit(prometheus.DefaultRegisterer, maxRequestNames)
 	go serveMetrics(metricsPort)
 
 	hostString := fmt.Sprintf("%s:%d", orchestratorHost, orchestratorPort)
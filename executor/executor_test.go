@@ -34,7 +34,6 @@ func TestReportJobPublishesSummary(t *testing.T) {
 		PlannedRequests:   2,
 		CompletedRequests: 2,
 		SuccessCount:      2,
-		LatencyMillis:     []int64{5, 7},
 	})
 	if err != nil {
 		t.Fatalf("unexpected report error: %v", err)
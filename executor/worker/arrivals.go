@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"github.com/PeladoCollado/imager/types"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+// arrivalOffsets returns the intended send time of each request in the job, relative to the job start. Every
+// arrival process but recorded keeps the average rate at RatePerSec, so the offered load only differs in its shape.
+func arrivalOffsets(job types.Job, jobDuration time.Duration) []time.Duration {
+	count := len(job.Requests)
+	offsets := make([]time.Duration, count)
+	if count == 0 {
+		return offsets
+	}
+	window := sendWindow(job, jobDuration)
+	evenOffset := func(idx int) time.Duration {
+		return time.Duration(int64(window) * int64(idx) / int64(count))
+	}
+
+	arrival := types.ArrivalProcess{Type: types.ArrivalUniform}
+	if job.Arrival != nil {
+		arrival = *job.Arrival
+	}
+	switch arrival.Type {
+	case types.ArrivalPoisson:
+		// A Poisson process conditioned on n arrivals in a window places them uniformly at random, so
+		// sorting n uniform samples gives exponential gaps while keeping all n requests inside the window.
+		rng := newArrivalRand(arrival.Seed)
+		for idx := range offsets {
+			offsets[idx] = time.Duration(rng.Int63n(int64(window) + 1))
+		}
+		slices.Sort(offsets)
+	case types.ArrivalBurst:
+		burstSize := arrival.BurstSize
+		if burstSize <= 0 {
+			burstSize = 1
+		}
+		for idx := range offsets {
+			offsets[idx] = evenOffset(idx / burstSize * burstSize)
+		}
+	case types.ArrivalRecorded:
+		if len(job.OffsetsMillis) == count {
+			for idx, offset := range job.OffsetsMillis {
+				offsets[idx] = time.Duration(offset) * time.Millisecond
+			}
+			break
+		}
+		// Jobs without offsets only know the gaps between their own requests.
+		evenGap := window / time.Duration(count)
+		for idx := 1; idx < count; idx++ {
+			gap := evenGap
+			if delay := job.Requests[idx].DelayMillis; delay > 0 {
+				gap = time.Duration(delay) * time.Millisecond
+			}
+			offsets[idx] = offsets[idx-1] + gap
+		}
+	default:
+		for idx := range offsets {
+			offsets[idx] = evenOffset(idx)
+		}
+	}
+	return offsets
+}
+
+// sendWindow returns the span the job's requests are spread over at its rate. Jobs without a rate spread their
+// requests across the job duration.
+func sendWindow(job types.Job, jobDuration time.Duration) time.Duration {
+	if job.RatePerSec > 0 {
+		return time.Second * time.Duration(len(job.Requests)) / time.Duration(job.RatePerSec)
+	}
+	return jobDuration
+}
+
+func newArrivalRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(rand.NewSource(seed))
+}
@@ -0,0 +1,90 @@
+package worker
+
+import (
+	"github.com/PeladoCollado/imager/types"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestArrivalOffsetsUniform(t *testing.T) {
+	job := types.Job{Requests: make([]types.RequestSpec, 4), RatePerSec: 4}
+
+	offsets := arrivalOffsets(job, time.Second)
+	expected := []time.Duration{0, 250 * time.Millisecond, 500 * time.Millisecond, 750 * time.Millisecond}
+	if !slices.Equal(offsets, expected) {
+		t.Fatalf("expected evenly spaced offsets %v, got %v", expected, offsets)
+	}
+}
+
+func TestArrivalOffsetsPoissonIsSeededAndStaysInWindow(t *testing.T) {
+	job := types.Job{
+		Requests:   make([]types.RequestSpec, 100),
+		RatePerSec: 100,
+		Arrival:    &types.ArrivalProcess{Type: types.ArrivalPoisson, Seed: 42},
+	}
+
+	first := arrivalOffsets(job, time.Second)
+	second := arrivalOffsets(job, time.Second)
+	if !slices.Equal(first, second) {
+		t.Fatalf("expected the same seed to reproduce the same offsets")
+	}
+	if !slices.IsSorted(first) {
+		t.Fatalf("expected poisson offsets to be sorted")
+	}
+	if last := first[len(first)-1]; last > time.Second {
+		t.Fatalf("expected all arrivals inside the 1s window, last at %s", last)
+	}
+
+	job.Arrival = &types.ArrivalProcess{Type: types.ArrivalPoisson, Seed: 43}
+	if slices.Equal(first, arrivalOffsets(job, time.Second)) {
+		t.Fatalf("expected a different seed to produce different offsets")
+	}
+}
+
+func TestArrivalOffsetsBurst(t *testing.T) {
+	job := types.Job{
+		Requests:   make([]types.RequestSpec, 6),
+		RatePerSec: 6,
+		Arrival:    &types.ArrivalProcess{Type: types.ArrivalBurst, BurstSize: 3},
+	}
+
+	offsets := arrivalOffsets(job, time.Second)
+	expected := []time.Duration{0, 0, 0, 500 * time.Millisecond, 500 * time.Millisecond, 500 * time.Millisecond}
+	if !slices.Equal(offsets, expected) {
+		t.Fatalf("expected burst offsets %v, got %v", expected, offsets)
+	}
+}
+
+func TestArrivalOffsetsRecorded(t *testing.T) {
+	job := types.Job{
+		Requests: []types.RequestSpec{
+			{DelayMillis: 900},
+			{DelayMillis: 100},
+			{},
+			{DelayMillis: 400},
+		},
+		RatePerSec: 4,
+		Arrival:    &types.ArrivalProcess{Type: types.ArrivalRecorded},
+	}
+
+	offsets := arrivalOffsets(job, time.Second)
+	expected := []time.Duration{0, 100 * time.Millisecond, 350 * time.Millisecond, 750 * time.Millisecond}
+	if !slices.Equal(offsets, expected) {
+		t.Fatalf("expected recorded offsets %v, got %v", expected, offsets)
+	}
+}
+
+func TestArrivalOffsetsRecordedUsesJobOffsets(t *testing.T) {
+	job := types.Job{
+		Requests:      []types.RequestSpec{{DelayMillis: 900}, {DelayMillis: 100}},
+		OffsetsMillis: []int64{250, 250},
+		RatePerSec:    2,
+		Arrival:       &types.ArrivalProcess{Type: types.ArrivalRecorded},
+	}
+	offsets := arrivalOffsets(job, time.Second)
+	expected := []time.Duration{250 * time.Millisecond, 250 * time.Millisecond}
+	if !slices.Equal(offsets, expected) {
+		t.Fatalf("expected the job's offsets %v, got %v", expected, offsets)
+	}
+}
@@ -0,0 +1,195 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/PeladoCollado/imager/types"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// acceptsStatus reports whether a status code passes the expectation's status check. Without an explicit status
+// list, any status below 300 is accepted.
+func acceptsStatus(expect *types.Expectation, statusCode int) bool {
+	if expect == nil || len(expect.Status) == 0 {
+		return statusCode < 300
+	}
+	return slices.Contains(expect.Status, statusCode)
+}
+
+// maxBufferedBodyBytes bounds the response bodies read into memory for body checks and extractions whose
+// expectation sets no MaxBodyBytes of its own.
+var maxBufferedBodyBytes int64 = 10 << 20
+
+// bufferedBodyLimit is the largest response body that is read into memory for the expectation.
+func bufferedBodyLimit(expect *types.Expectation) int64 {
+	if expect != nil && expect.MaxBodyBytes > 0 {
+		return expect.MaxBodyBytes
+	}
+	return maxBufferedBodyBytes
+}
+
+// expectsBody reports whether checking the expectation needs the response body in memory.
+func expectsBody(expect *types.Expectation) bool {
+	return expect != nil && (expect.BodyContains != "" || expect.BodyMatches != "" || len(expect.JSONPath) > 0)
+}
+
+// checkResponse runs the expectation's header and body checks and returns the first one that fails. body is only
+// populated when expectsBody is true; bodySize is always the number of bytes read.
+func checkResponse(expect *types.Expectation, header http.Header, body []byte, bodySize int64) error {
+	if expect == nil {
+		return nil
+	}
+	if expect.MaxBodyBytes > 0 && bodySize > expect.MaxBodyBytes {
+		return fmt.Errorf("response body of %d bytes exceeds the %d byte limit", bodySize, expect.MaxBodyBytes)
+	}
+	for name, expected := range expect.Headers {
+		values, ok := header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return fmt.Errorf("response header %s is missing", name)
+		}
+		if expected != "" && !slices.Contains(values, expected) {
+			return fmt.Errorf("response header %s expected %q, got %q", name, expected, strings.Join(values, ", "))
+		}
+	}
+	if expect.BodyContains != "" && !bytes.Contains(body, []byte(expect.BodyContains)) {
+		return fmt.Errorf("response body does not contain %q", expect.BodyContains)
+	}
+	if expect.BodyMatches != "" {
+		pattern, err := compilePattern(expect.BodyMatches)
+		if err != nil {
+			return fmt.Errorf("invalid bodyMatches pattern %q: %w", expect.BodyMatches, err)
+		}
+		if !pattern.Match(body) {
+			return fmt.Errorf("response body does not match %q", expect.BodyMatches)
+		}
+	}
+	if len(expect.JSONPath) > 0 {
+		var document any
+		if err := json.Unmarshal(body, &document); err != nil {
+			return fmt.Errorf("response body is not JSON: %w", err)
+		}
+		for _, check := range expect.JSONPath {
+			if err := checkJSONPath(document, check); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func checkJSONPath(document any, check types.JSONPathExpectation) error {
+	actual, err := lookupJSONPath(document, check.Path)
+	if err != nil {
+		return err
+	}
+	expected, err := normalizeJSON(check.Equals)
+	if err != nil {
+		return fmt.Errorf("invalid expected value for %s: %w", check.Path, err)
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		actualJSON, _ := json.Marshal(actual)
+		expectedJSON, _ := json.Marshal(expected)
+		return fmt.Errorf("%s expected %s, got %s", check.Path, expectedJSON, actualJSON)
+	}
+	return nil
+}
+
+// normalizeJSON round-trips a value through JSON so that it compares equal to the same value decoded from a
+// response body, e.g. an int becomes a float64.
+func normalizeJSON(value any) (any, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	err = json.Unmarshal(encoded, &normalized)
+	return normalized, err
+}
+
+// lookupJSONPath resolves a path made of object keys and array indexes, such as $.items[0].status or
+// $['content-type'], against a decoded JSON document.
+func lookupJSONPath(document any, path string) (any, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("JSONPath %q must start with $", path)
+	}
+	current := document
+	rest := path[1:]
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "['"):
+			end := strings.Index(rest, "']")
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated ['key']", path)
+			}
+			key := rest[2:end]
+			rest = rest[end+2:]
+			object, ok := current.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: %q is not inside an object", path, key)
+			}
+			if current, ok = object[key]; !ok {
+				return nil, fmt.Errorf("%s: key %q not found", path, key)
+			}
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("JSONPath %q has an unterminated index", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("JSONPath %q has an invalid index %q", path, rest[1:end])
+			}
+			rest = rest[end+1:]
+			array, ok := current.([]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: index %d is not inside an array", path, index)
+			}
+			if index < 0 || index >= len(array) {
+				return nil, fmt.Errorf("%s: index %d out of range for %d elements", path, index, len(array))
+			}
+			current = array[index]
+		case rest[0] == '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			rest = rest[end+1:]
+			if key == "" {
+				return nil, fmt.Errorf("JSONPath %q has an empty key", path)
+			}
+			object, ok := current.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s: %q is not inside an object", path, key)
+			}
+			if current, ok = object[key]; !ok {
+				return nil, fmt.Errorf("%s: key %q not found", path, key)
+			}
+		default:
+			return nil, fmt.Errorf("JSONPath %q is not supported; use $.key, $['key'] and [index] segments", path)
+		}
+	}
+	return current, nil
+}
+
+// patterns caches compiled bodyMatches expressions, since every request drawn from a source shares them.
+var patterns sync.Map
+
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	if cached, ok := patterns.Load(expr); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	pattern, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(expr, pattern)
+	return pattern, nil
+}
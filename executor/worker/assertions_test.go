@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"context"
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckResponseEvaluatesEachExpectation(t *testing.T) {
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	body := []byte(`{"status":"ok","items":[{"id":7,"tags":["a"]}],"content-type":"json"}`)
+
+	cases := []struct {
+		name   string
+		expect types.Expectation
+		fails  string
+	}{
+		{name: "body contains", expect: types.Expectation{BodyContains: `"ok"`}},
+		{name: "body missing", expect: types.Expectation{BodyContains: "error"}, fails: "does not contain"},
+		{name: "body matches", expect: types.Expectation{BodyMatches: `"id":\d+`}},
+		{name: "body does not match", expect: types.Expectation{BodyMatches: `^\[`}, fails: "does not match"},
+		{name: "invalid pattern", expect: types.Expectation{BodyMatches: `(`}, fails: "invalid bodyMatches"},
+		{name: "json path", expect: types.Expectation{JSONPath: []types.JSONPathExpectation{
+			{Path: "$.status", Equals: "ok"},
+			{Path: "$.items[0].id", Equals: 7},
+			{Path: "$.items[0].tags", Equals: []string{"a"}},
+			{Path: "$['content-type']", Equals: "json"},
+		}}},
+		{name: "json path mismatch", expect: types.Expectation{JSONPath: []types.JSONPathExpectation{
+			{Path: "$.status", Equals: "degraded"},
+		}}, fails: `$.status expected "degraded", got "ok"`},
+		{name: "json path missing", expect: types.Expectation{JSONPath: []types.JSONPathExpectation{
+			{Path: "$.items[3].id", Equals: 1},
+		}}, fails: "out of range"},
+		{name: "header present", expect: types.Expectation{Headers: map[string]string{"content-type": ""}}},
+		{name: "header value", expect: types.Expectation{Headers: map[string]string{"Content-Type": "text/plain"}},
+			fails: "expected \"text/plain\""},
+		{name: "header missing", expect: types.Expectation{Headers: map[string]string{"X-Trace": ""}},
+			fails: "is missing"},
+		{name: "body size", expect: types.Expectation{MaxBodyBytes: 10}, fails: "exceeds the 10 byte limit"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkResponse(&tc.expect, header, body, int64(len(body)))
+			if tc.fails == "" {
+				if err != nil {
+					t.Fatalf("expected expectation to pass, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.fails) {
+				t.Fatalf("expected failure containing %q, got %v", tc.fails, err)
+			}
+		})
+	}
+}
+
+func TestSendRequestBoundsBufferedResponseBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 64) + "ok"))
+	}))
+	defer server.Close()
+
+	previous := maxBufferedBodyBytes
+	maxBufferedBodyBytes = 32
+	t.Cleanup(func() { maxBufferedBodyBytes = previous })
+
+	cases := []struct {
+		name   string
+		expect types.Expectation
+		passes bool
+	}{
+		{name: "default cap", expect: types.Expectation{BodyContains: "ok"}},
+		{name: "own limit", expect: types.Expectation{BodyContains: "ok", MaxBodyBytes: 16}},
+		{name: "own larger limit", expect: types.Expectation{BodyContains: "ok", MaxBodyBytes: 128}, passes: true},
+	}
+	for _, tc := range cases {
+		spec := types.RequestSpec{Method: "GET", Path: "/", Expect: &tc.expect}
+		result := sendRequest(context.Background(), client, server.URL, spec, nil, nil, 0, &fakeMetrics{})
+		if result.success != tc.passes {
+			t.Fatalf("%s: expected success=%t, got %+v", tc.name, tc.passes, result)
+		}
+		if !tc.passes && result.category != metrics.ErrorCategoryAssertion {
+			t.Fatalf("%s: expected an assertion failure, got %s", tc.name, result.category)
+		}
+	}
+}
+
+func TestLookupJSONPathRejectsUnsupportedPaths(t *testing.T) {
+	document := map[string]any{"a": []any{"x"}}
+	for _, path := range []string{"a", "$..a", "$.a[x]", "$.a[0", "$[*]"} {
+		if _, err := lookupJSONPath(document, path); err == nil {
+			t.Fatalf("expected path %q to be rejected", path)
+		}
+	}
+}
+
+func TestRunJobReportsAssertionFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/error-payload":
+			w.Write([]byte(`{"status":"error"}`))
+		default:
+			w.Write([]byte(`{"status":"ok"}`))
+		}
+	}))
+	defer server.Close()
+
+	statusOK := &types.Expectation{JSONPath: []types.JSONPathExpectation{{Path: "$.status", Equals: "ok"}}}
+	job := types.Job{
+		ID: "job-assert",
+		Requests: []types.RequestSpec{
+			{Method: "GET", Path: "/healthy", Name: "healthy", Expect: statusOK},
+			{Method: "GET", Path: "/error-payload", Name: "broken", Expect: statusOK},
+			{Method: "GET", Path: "/missing", Expect: &types.Expectation{Status: []int{http.StatusNotFound}}},
+			{Method: "GET", Path: "/healthy", Expect: &types.Expectation{Status: []int{http.StatusCreated}}},
+			{Method: "GET", Path: "/unavailable", Expect: &types.Expectation{Status: []int{http.StatusOK}}},
+		},
+		TargetURLs:     []string{server.URL},
+		RatePerSec:     5,
+		DurationMillis: time.Second.Milliseconds(),
+	}
+	collector := &categoryMetrics{}
+
+	report := RunJob(context.Background(), job, collector)
+	if report.SuccessCount != 2 || report.FailureCount != 3 || report.AssertionFailureCount != 3 {
+		t.Fatalf("expected 2 successes and 3 assertion failures, got %+v", report)
+	}
+	// A 503 that fails its status expectation is an assertion failure only, so it is not counted twice.
+	if report.TimeoutCount != 0 {
+		t.Fatalf("expected no timeouts, got %d", report.TimeoutCount)
+	}
+	if broken := report.Names["broken"]; broken.AssertionFailureCount != 1 {
+		t.Fatalf("expected the broken request to fail its assertion, got %+v", broken)
+	}
+	if collector.categories[metrics.ErrorCategoryAssertion] != 3 {
+		t.Fatalf("expected 3 assertion failure events, got %v", collector.categories)
+	}
+}
+
+// categoryMetrics counts failure events by category.
+type categoryMetrics struct {
+	fakeMetrics
+	categories map[string]int
+}
+
+func (c *categoryMetrics) PostFailure(event metrics.ErrorEvent) {
+	c.fakeMetrics.PostFailure(event)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.categories == nil {
+		c.categories = make(map[string]int)
+	}
+	c.categories[event.Category]++
+}
@@ -0,0 +1,134 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/base64"
+	"github.com/PeladoCollado/imager/types"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// requestBody returns the body of the request and its length, or -1 when the length is not known up front. Files
+// are opened here and streamed as the request is sent, so they are never held in memory. reopen opens the body again
+// for Body, BodyBase64 and BodyFile, so that the client can resend it on a redirect or retry. contentType is set for
+// multipart bodies, whose header has to carry the boundary.
+func requestBody(requestSpec types.RequestSpec) (body io.ReadCloser,
+	reopen func() (io.ReadCloser, error),
+	length int64,
+	contentType string,
+	err error) {
+	if err := requestSpec.ValidateBody(); err != nil {
+		return nil, nil, 0, "", err
+	}
+	switch {
+	case requestSpec.Body != "":
+		reopen = func() (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(requestSpec.Body)), nil
+		}
+		length = int64(len(requestSpec.Body))
+	case requestSpec.BodyBase64 != "":
+		decoded, err := base64.StdEncoding.DecodeString(requestSpec.BodyBase64)
+		if err != nil {
+			return nil, nil, 0, "", err
+		}
+		reopen = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(decoded)), nil
+		}
+		length = int64(len(decoded))
+	case requestSpec.BodyFile != "":
+		reopen = func() (io.ReadCloser, error) {
+			return os.Open(requestSpec.BodyFile)
+		}
+	case len(requestSpec.Multipart) > 0:
+		reader, writer := io.Pipe()
+		form := multipart.NewWriter(writer)
+		go func() {
+			writer.CloseWithError(writeMultipart(form, requestSpec.Multipart))
+		}()
+		return reader, nil, -1, form.FormDataContentType(), nil
+	default:
+		return nil, nil, 0, "", nil
+	}
+	if body, err = reopen(); err != nil {
+		return nil, nil, 0, "", err
+	}
+	if file, ok := body.(*os.File); ok {
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, nil, 0, "", err
+		}
+		length = info.Size()
+	}
+	return body, reopen, length, "", nil
+}
+
+// writeMultipart writes the parts to form and closes it. The client closes the pipe when it stops reading the
+// body, which fails the next write and ends the copy.
+func writeMultipart(form *multipart.Writer, parts []types.MultipartPart) error {
+	for _, part := range parts {
+		if part.File == "" {
+			if err := form.WriteField(part.Name, part.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writeMultipartFile(form, part); err != nil {
+			return err
+		}
+	}
+	return form.Close()
+}
+
+func writeMultipartFile(form *multipart.Writer, part types.MultipartPart) error {
+	file, err := os.Open(part.File)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	fileName := part.FileName
+	if fileName == "" {
+		fileName = filepath.Base(part.File)
+	}
+	contentType := part.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", multipart.FileContentDisposition(part.Name, fileName))
+	header.Set("Content-Type", contentType)
+	writer, err := form.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// setBody attaches a body from requestBody to the request, after its headers are set so that a multipart boundary
+// wins over a recorded Content-Type.
+func setBody(request *http.Request,
+	body io.ReadCloser,
+	reopen func() (io.ReadCloser, error),
+	length int64,
+	contentType string) {
+	if body == nil {
+		return
+	}
+	request.Body = body
+	request.GetBody = reopen
+	request.ContentLength = length
+	if length == 0 {
+		request.Body = http.NoBody
+		request.GetBody = nil
+		body.Close()
+	}
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+}
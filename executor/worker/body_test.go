@@ -0,0 +1,136 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/types"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSendRequestStreamsBinaryAndFileBodies(t *testing.T) {
+	payload := []byte{0x00, 0x01, 0xfe, 0xff}
+	file := filepath.Join(t.TempDir(), "upload.bin")
+	if err := os.WriteFile(file, payload, 0o644); err != nil {
+		t.Fatalf("unable to write body file: %v", err)
+	}
+	var received [][]byte
+	var lengths []int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, body)
+		lengths = append(lengths, r.ContentLength)
+	}))
+	defer server.Close()
+
+	for _, spec := range []types.RequestSpec{
+		{Method: "POST", Path: "/inline", BodyBase64: "AAH+/w=="},
+		{Method: "POST", Path: "/file", BodyFile: file},
+	} {
+		result := sendRequest(context.Background(), client, server.URL, spec, nil, nil, 0, &fakeMetrics{})
+		if result.category != "" {
+			t.Fatalf("expected %s to succeed, got %s", spec.Path, result.category)
+		}
+	}
+	for i, body := range received {
+		if !bytes.Equal(body, payload) || lengths[i] != int64(len(payload)) {
+			t.Fatalf("expected body %v with its length, got %v (%d)", payload, body, lengths[i])
+		}
+	}
+}
+
+func TestSendRequestResendsBodiesOnRedirect(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "upload.txt")
+	if err := os.WriteFile(file, []byte("payload"), 0o644); err != nil {
+		t.Fatalf("unable to write body file: %v", err)
+	}
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path != "/moved" {
+			http.Redirect(w, r, "/moved", http.StatusTemporaryRedirect)
+			return
+		}
+		received = append(received, string(body))
+	}))
+	defer server.Close()
+
+	for _, spec := range []types.RequestSpec{
+		{Method: "POST", Path: "/inline", Body: "payload"},
+		{Method: "POST", Path: "/encoded", BodyBase64: "cGF5bG9hZA=="},
+		{Method: "POST", Path: "/file", BodyFile: file},
+	} {
+		result := sendRequest(context.Background(), client, server.URL, spec, nil, nil, 0, &fakeMetrics{})
+		if result.category != "" {
+			t.Fatalf("expected %s to succeed, got %s", spec.Path, result.category)
+		}
+	}
+	if len(received) != 3 {
+		t.Fatalf("expected every request to follow the redirect, got %d", len(received))
+	}
+	for _, body := range received {
+		if body != "payload" {
+			t.Fatalf("expected the body to be resent, got %q", body)
+		}
+	}
+}
+
+func TestSendRequestBuildsMultipartBodies(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cat.png")
+	if err := os.WriteFile(file, []byte("png bytes"), 0o644); err != nil {
+		t.Fatalf("unable to write body file: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("title") != "cat" {
+			http.Error(w, "missing title", http.StatusBadRequest)
+			return
+		}
+		image, header, err := r.FormFile("image")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		content, _ := io.ReadAll(image)
+		if string(content) != "png bytes" || header.Filename != "cat.png" ||
+			header.Header.Get("Content-Type") != "image/png" {
+			http.Error(w, "unexpected image part", http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	spec := types.RequestSpec{
+		Method:  "POST",
+		Path:    "/upload",
+		Headers: map[string][]string{"Content-Type": {"multipart/form-data; boundary=recorded"}},
+		Multipart: []types.MultipartPart{
+			{Name: "title", Value: "cat"},
+			{Name: "image", File: file, ContentType: "image/png"},
+		},
+	}
+	result := sendRequest(context.Background(), client, server.URL, spec, nil, nil, 0, &fakeMetrics{})
+	if result.category != "" {
+		t.Fatalf("expected the multipart upload to succeed, got %s", result.category)
+	}
+}
+
+func TestSendRequestRejectsMissingBodyFiles(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("expected no request to be sent")
+	}))
+	defer server.Close()
+
+	spec := types.RequestSpec{Method: "POST", Path: "/file", BodyFile: filepath.Join(t.TempDir(), "missing.bin")}
+	result := sendRequest(context.Background(), client, server.URL, spec, nil, nil, 0, &fakeMetrics{})
+	if result.category != metrics.ErrorCategoryInvalidRequest {
+		t.Fatalf("expected an invalid request, got %s", result.category)
+	}
+}
@@ -0,0 +1,78 @@
+package worker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"github.com/PeladoCollado/imager/metrics"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// classifyError maps a transport or body read error to one of the metrics.ErrorCategory constants. Timeouts are
+// checked first, since a deadline can interrupt any of the other phases.
+func classifyError(err error) string {
+	var dnsErr *net.DNSError
+	switch {
+	case errorQualifiesAsTimeout(err):
+		return metrics.ErrorCategoryTimeout
+	case errors.As(err, &dnsErr):
+		return metrics.ErrorCategoryDNS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return metrics.ErrorCategoryConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE), errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF):
+		// The transport reports a connection the target closed before responding as a bare EOF.
+		return metrics.ErrorCategoryConnectionReset
+	case errorIsTLSFailure(err):
+		return metrics.ErrorCategoryTLS
+	}
+	return metrics.ErrorCategoryOther
+}
+
+// statusCategory classifies a response status that was not accepted.
+func statusCategory(status int) string {
+	switch {
+	case status >= http.StatusBadRequest && status < http.StatusInternalServerError:
+		return metrics.ErrorCategoryClientError
+	case status >= http.StatusInternalServerError && status < 600:
+		return metrics.ErrorCategoryServerError
+	}
+	return metrics.ErrorCategoryUnexpectedStatus
+}
+
+// errorQualifiesAsTimeout reports whether a request failed because a deadline passed: the request timeout, a
+// transport timeout, or the end of the job window.
+func errorQualifiesAsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// errorIsTLSFailure reports whether a request failed because the TLS handshake with the target failed, e.g. an
+// untrusted or mismatched certificate or a rejected client certificate.
+func errorIsTLSFailure(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	// crypto/tls reports alerts sent by the target, such as a rejected client certificate, as "remote error"
+	// net.OpErrors wrapping an unexported alert type.
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "remote error" {
+		return true
+	}
+	return errors.As(err, &recordErr) ||
+		errors.As(err, &alertErr) ||
+		errors.As(err, &verifyErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &invalidErr)
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/types"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err      error
+		category string
+	}{
+		{err: fmt.Errorf("Get \"http://target\": %w", context.DeadlineExceeded), category: metrics.ErrorCategoryTimeout},
+		{err: &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "target"}},
+			category: metrics.ErrorCategoryDNS},
+		{err: &net.OpError{Op: "dial", Err: &os.SyscallError{Syscall: "connect", Err: syscall.ECONNREFUSED}},
+			category: metrics.ErrorCategoryConnectionRefused},
+		{err: &net.OpError{Op: "read", Err: &os.SyscallError{Syscall: "read", Err: syscall.ECONNRESET}},
+			category: metrics.ErrorCategoryConnectionReset},
+		{err: fmt.Errorf("Get \"http://target\": %w", io.EOF), category: metrics.ErrorCategoryConnectionReset},
+		{err: &url.Error{Op: "Get", URL: "https://target", Err: x509.UnknownAuthorityError{}},
+			category: metrics.ErrorCategoryTLS},
+		{err: errors.New("something else"), category: metrics.ErrorCategoryOther},
+	}
+	for _, tc := range cases {
+		if got := classifyError(tc.err); got != tc.category {
+			t.Fatalf("expected category %q for %v, got %q", tc.category, tc.err, got)
+		}
+	}
+}
+
+func TestStatusCategory(t *testing.T) {
+	for status, expected := range map[int]string{
+		http.StatusFound:               metrics.ErrorCategoryUnexpectedStatus,
+		http.StatusNotFound:            metrics.ErrorCategoryClientError,
+		http.StatusTooManyRequests:     metrics.ErrorCategoryClientError,
+		http.StatusServiceUnavailable:  metrics.ErrorCategoryServerError,
+		http.StatusInternalServerError: metrics.ErrorCategoryServerError,
+	} {
+		if got := statusCategory(status); got != expected {
+			t.Fatalf("expected category %q for status %d, got %q", expected, status, got)
+		}
+	}
+}
+
+func TestRunJobCountsErrorCategories(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/reset":
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.(*net.TCPConn).SetLinger(0)
+				conn.Close()
+			}
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer server.Close()
+
+	job := types.Job{
+		ID: "job-errors",
+		Requests: []types.RequestSpec{
+			{Path: "/ok"},
+			{Path: "/missing"},
+			{Path: "/broken"},
+			{Path: "/reset"},
+			{Method: "NOT A METHOD", Path: "/ok"},
+		},
+		TargetURLs:     []string{server.URL},
+		RatePerSec:     5,
+		DurationMillis: time.Second.Milliseconds(),
+	}
+	collector := &categoryMetrics{}
+
+	report := RunJob(context.Background(), job, collector)
+	expected := map[string]int{
+		metrics.ErrorCategoryClientError:     1,
+		metrics.ErrorCategoryServerError:     1,
+		metrics.ErrorCategoryConnectionReset: 1,
+		metrics.ErrorCategoryInvalidRequest:  1,
+	}
+	if report.SuccessCount != 1 || report.FailureCount != 4 {
+		t.Fatalf("expected 1 success and 4 failures, got %+v", report)
+	}
+	if len(report.Errors) != len(expected) {
+		t.Fatalf("expected error categories %v, got %v", expected, report.Errors)
+	}
+	for category, count := range expected {
+		if report.Errors[category] != count || collector.categories[category] != count {
+			t.Fatalf("expected %d %s failures, got report %v and events %v", count, category, report.Errors,
+				collector.categories)
+		}
+	}
+}
+
+func TestExecuteRequestClassifiesRefusedConnections(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	target := "http://" + listener.Addr().String()
+	listener.Close()
+
+	collector := &categoryMetrics{}
+	result := executeRequest(context.Background(), target, types.RequestSpec{Path: "/"}, 0, collector)
+	if result.success || result.category != metrics.ErrorCategoryConnectionRefused {
+		t.Fatalf("expected a refused connection, got %+v", result)
+	}
+	if collector.categories[metrics.ErrorCategoryConnectionRefused] != 1 {
+		t.Fatalf("expected a connection_refused failure event, got %v", collector.categories)
+	}
+}
@@ -0,0 +1,207 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/types"
+	"maps"
+	"net/http"
+	"net/http/cookiejar"
+	"regexp"
+	"time"
+)
+
+// variablePattern matches a ${name} reference to a session variable.
+var variablePattern = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// runScenario runs the steps of a scenario in order as one session, with its own cookie jar and variables. It stops
+// at the first failed step; the scenario result carries that step's failure and every step's own result.
+func runScenario(ctx context.Context,
+	target string,
+	requestSpec types.RequestSpec,
+	timeout time.Duration,
+	metricsCollector metrics.MetricsCollector) requestResult {
+	name := requestSpec.ResultName()
+	scenario := requestSpec.Scenario
+	if err := scenario.Validate(); err != nil {
+		return invalidRequest(metricsCollector, requestSpec, name, fmt.Errorf("invalid scenario: %w", err))
+	}
+
+	// cookiejar.New only fails on invalid options.
+	jar, _ := cookiejar.New(nil)
+	session := *client
+	session.Jar = jar
+	variables := maps.Clone(scenario.Variables)
+	if variables == nil {
+		variables = make(map[string]string)
+	}
+
+	result := requestResult{executed: true, name: name, success: true}
+	start := time.Now()
+	for i, step := range scenario.Steps {
+		stepSpec := step.RequestSpec
+		stepSpec.Name = stepName(name, scenario.StepName(i))
+		var stepResult requestResult
+		if expanded, err := expandVariables(stepSpec, variables); err != nil {
+			stepResult = invalidRequest(metricsCollector, stepSpec, stepSpec.Name, err)
+		} else {
+			stepResult = sendRequest(ctx, &session, target, expanded, step.Extract, variables, timeout, metricsCollector)
+		}
+		result.steps = append(result.steps, stepResult)
+		if !stepResult.success {
+			result.success = false
+			result.timeout = stepResult.timeout
+			result.category = stepResult.category
+			break
+		}
+	}
+	result.duration = time.Since(start)
+	return result
+}
+
+// stepName is the name a scenario step is reported under.
+func stepName(scenario string, step string) string {
+	if scenario == "" {
+		return step
+	}
+	return scenario + "/" + step
+}
+
+// expandVariables substitutes ${name} references in the path, query string, header values and body of a step.
+// Values are substituted as-is, without escaping.
+func expandVariables(requestSpec types.RequestSpec, variables map[string]string) (types.RequestSpec, error) {
+	var undefined string
+	expand := func(text string) string {
+		return variablePattern.ReplaceAllStringFunc(text, func(reference string) string {
+			variable := reference[2 : len(reference)-1]
+			value, ok := variables[variable]
+			if !ok && undefined == "" {
+				undefined = variable
+			}
+			return value
+		})
+	}
+	requestSpec.Path = expand(requestSpec.Path)
+	requestSpec.QueryString = expand(requestSpec.QueryString)
+	requestSpec.Body = expand(requestSpec.Body)
+	if len(requestSpec.Headers) > 0 {
+		headers := make(map[string][]string, len(requestSpec.Headers))
+		for key, values := range requestSpec.Headers {
+			expanded := make([]string, len(values))
+			for i, value := range values {
+				expanded[i] = expand(value)
+			}
+			headers[key] = expanded
+		}
+		requestSpec.Headers = headers
+	}
+	if undefined != "" {
+		return requestSpec, fmt.Errorf("undefined variable %q", undefined)
+	}
+	return requestSpec, nil
+}
+
+// extractsBody reports whether any of the extractions reads the response body.
+func extractsBody(extract []types.Extraction) bool {
+	for _, extraction := range extract {
+		if extraction.JSONPath != "" || extraction.Regex != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// extractValues captures the extracted response values into variables. Nothing is captured unless every
+// extraction finds its value.
+func extractValues(extract []types.Extraction,
+	response *http.Response,
+	body []byte,
+	jar http.CookieJar,
+	variables map[string]string) error {
+	if len(extract) == 0 {
+		return nil
+	}
+	var document any
+	captured := make(map[string]string, len(extract))
+	for _, extraction := range extract {
+		var value string
+		var err error
+		switch {
+		case extraction.JSONPath != "":
+			if document == nil {
+				if err := json.Unmarshal(body, &document); err != nil {
+					return fmt.Errorf("extract %s: response body is not JSON: %w", extraction.Variable, err)
+				}
+			}
+			value, err = extractJSONPath(document, extraction.JSONPath)
+		case extraction.Regex != "":
+			value, err = extractRegex(body, extraction.Regex)
+		case extraction.Header != "":
+			value, err = extractHeader(response.Header, extraction.Header)
+		default:
+			value, err = extractCookie(response, jar, extraction.Cookie)
+		}
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", extraction.Variable, err)
+		}
+		captured[extraction.Variable] = value
+	}
+	maps.Copy(variables, captured)
+	return nil
+}
+
+func extractJSONPath(document any, path string) (string, error) {
+	value, err := lookupJSONPath(document, path)
+	if err != nil {
+		return "", err
+	}
+	if text, ok := value.(string); ok {
+		return text, nil
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+func extractRegex(body []byte, expression string) (string, error) {
+	pattern, err := compilePattern(expression)
+	if err != nil {
+		return "", fmt.Errorf("invalid regex %q: %w", expression, err)
+	}
+	match := pattern.FindSubmatch(body)
+	if match == nil {
+		return "", fmt.Errorf("response body does not match %q", expression)
+	}
+	if len(match) > 1 {
+		return string(match[1]), nil
+	}
+	return string(match[0]), nil
+}
+
+func extractHeader(header http.Header, name string) (string, error) {
+	values := header.Values(name)
+	if len(values) == 0 {
+		return "", fmt.Errorf("response header %s is missing", name)
+	}
+	return values[0], nil
+}
+
+func extractCookie(response *http.Response, jar http.CookieJar, name string) (string, error) {
+	for _, cookie := range response.Cookies() {
+		if cookie.Name == name {
+			return cookie.Value, nil
+		}
+	}
+	if jar != nil && response.Request != nil {
+		for _, cookie := range jar.Cookies(response.Request.URL) {
+			if cookie.Name == name {
+				return cookie.Value, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cookie %s is not set", name)
+}
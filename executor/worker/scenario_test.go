@@ -0,0 +1,167 @@
+package worker
+
+import (
+	"context"
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunJobRunsScenarioAsOneSession(t *testing.T) {
+	var ordersServed atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "sid", Value: "session-1", Path: "/"})
+			w.Header().Set("X-Csrf-Token", "csrf-1")
+			w.Write([]byte(`{"token":"abc","user":{"id":42},"ref":"order=ord-9;"}`))
+		case "/users/42/orders":
+			cookie, err := r.Cookie("sid")
+			if err != nil || cookie.Value != "session-1" || r.Header.Get("Authorization") != "Bearer abc" ||
+				r.Header.Get("X-Csrf-Token") != "csrf-1" || r.URL.Query().Get("tenant") != "acme" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body := make([]byte, r.ContentLength)
+			r.Body.Read(body)
+			if string(body) != `{"order":"ord-9"}` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			ordersServed.Add(1)
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	checkout := types.RequestSpec{
+		Name: "checkout",
+		Scenario: &types.Scenario{
+			Variables: map[string]string{"tenant": "acme"},
+			Steps: []types.ScenarioStep{
+				{
+					RequestSpec: types.RequestSpec{Method: http.MethodPost, Path: "/login", Name: "login"},
+					Extract: []types.Extraction{
+						{Variable: "token", JSONPath: "$.token"},
+						{Variable: "user", JSONPath: "$.user.id"},
+						{Variable: "order", Regex: `order=([^;]+)`},
+						{Variable: "csrf", Header: "X-Csrf-Token"},
+						{Variable: "sid", Cookie: "sid"},
+					},
+				},
+				{RequestSpec: types.RequestSpec{
+					Method:      http.MethodPost,
+					Path:        "/users/${user}/orders",
+					QueryString: "tenant=${tenant}",
+					Headers: map[string][]string{
+						"Authorization": {"Bearer ${token}"},
+						"X-Csrf-Token":  {"${csrf}"},
+					},
+					Body: `{"order":"${order}"}`,
+				}},
+			},
+		},
+	}
+	job := types.Job{
+		ID:             "job-scenario",
+		Requests:       []types.RequestSpec{checkout, checkout},
+		TargetURLs:     []string{server.URL},
+		RatePerSec:     2,
+		DurationMillis: time.Second.Milliseconds(),
+	}
+	collector := &fakeMetrics{}
+
+	report := RunJob(context.Background(), job, collector)
+	if report.CompletedRequests != 2 || report.SuccessCount != 2 {
+		t.Fatalf("expected 2 successful scenario runs, got %+v", report)
+	}
+	if ordersServed.Load() != 2 {
+		t.Fatalf("expected the second step to use the extracted values, got %d orders", ordersServed.Load())
+	}
+	if checkoutResults := report.Names["checkout"]; checkoutResults.SuccessCount != 2 {
+		t.Fatalf("expected the scenario to be reported under its name, got %+v", report.Names)
+	}
+	for _, step := range []string{"checkout/login", "checkout/step-2"} {
+		if results := report.Steps[step]; results.SuccessCount != 2 || results.Latency.Count != 2 {
+			t.Fatalf("expected 2 successes for step %s, got %+v", step, report.Steps)
+		}
+	}
+	if report.Phases.FirstByte.Count != 4 {
+		t.Fatalf("expected phases for every step, got %d", report.Phases.FirstByte.Count)
+	}
+}
+
+func TestRunScenarioStopsAtFailedStep(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer server.Close()
+
+	scenario := types.RequestSpec{Name: "flow", Scenario: &types.Scenario{Steps: []types.ScenarioStep{
+		{RequestSpec: types.RequestSpec{Path: "/start", Name: "start"},
+			Extract: []types.Extraction{{Variable: "token", JSONPath: "$.token"}}},
+		{RequestSpec: types.RequestSpec{Path: "/next/${token}", Name: "next"}},
+	}}}
+	collector := &categoryMetrics{}
+
+	result := executeRequest(context.Background(), server.URL, scenario, 0, collector)
+	if result.success || result.category != metrics.ErrorCategoryAssertion {
+		t.Fatalf("expected the scenario to fail its extraction, got %+v", result)
+	}
+	if len(result.steps) != 1 || result.steps[0].name != "flow/start" {
+		t.Fatalf("expected the scenario to stop after its first step, got %+v", result.steps)
+	}
+	if requests.Load() != 1 {
+		t.Fatalf("expected 1 request, got %d", requests.Load())
+	}
+}
+
+func TestRunScenarioRejectsInvalidScenarios(t *testing.T) {
+	invalid := []*types.Scenario{
+		{},
+		{Steps: []types.ScenarioStep{{Extract: []types.Extraction{{Variable: "token"}}}}},
+		{Steps: []types.ScenarioStep{{RequestSpec: types.RequestSpec{Scenario: &types.Scenario{}}}}},
+	}
+	for _, scenario := range invalid {
+		collector := &categoryMetrics{}
+		result := executeRequest(context.Background(), "http://127.0.0.1:1", types.RequestSpec{Scenario: scenario}, 0,
+			collector)
+		if result.success || collector.categories[metrics.ErrorCategoryInvalidRequest] != 1 {
+			t.Fatalf("expected scenario %+v to be rejected, got %+v", scenario, result)
+		}
+	}
+}
+
+func TestExpandVariables(t *testing.T) {
+	spec := types.RequestSpec{
+		Path:        "/items/${id}",
+		QueryString: "q=${query}&page=2",
+		Headers:     map[string][]string{"Authorization": {"Bearer ${token}"}},
+		Body:        "${id}:${id}",
+	}
+	expanded, err := expandVariables(spec, map[string]string{"id": "7", "query": "shoes", "token": "t"})
+	if err != nil {
+		t.Fatalf("unexpected expansion error: %v", err)
+	}
+	if expanded.Path != "/items/7" || expanded.QueryString != "q=shoes&page=2" || expanded.Body != "7:7" ||
+		expanded.Headers["Authorization"][0] != "Bearer t" {
+		t.Fatalf("unexpected expansion %+v", expanded)
+	}
+	if spec.Headers["Authorization"][0] != "Bearer ${token}" {
+		t.Fatalf("expected the original headers to be left untouched, got %v", spec.Headers)
+	}
+
+	if _, err := expandVariables(spec, map[string]string{"id": "7"}); err == nil ||
+		!strings.Contains(err.Error(), "undefined variable") {
+		t.Fatalf("expected an undefined variable error, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/types"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMutualTLSTargetRequests(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCertificate(t, nil, "imager-test-ca", nil)
+	server := newTestCertificate(t, ca, "target", []string{"target.internal"})
+	clientCert := newTestCertificate(t, ca, "executor", nil)
+	caFile := writeTestPEM(t, dir, "ca.pem", "CERTIFICATE", ca.der)
+	certFile := writeTestPEM(t, dir, "client.pem", "CERTIFICATE", clientCert.der)
+	keyFile := writeTestPEM(t, dir, "client-key.pem", "EC PRIVATE KEY", clientCert.keyDER(t))
+
+	target := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.certificate)
+	target.TLS = &tls.Config{
+		Certificates: []tls.Certificate{server.tlsCertificate()},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	// The rejected handshakes are expected; keep the server from logging them.
+	target.Config.ErrorLog = log.New(io.Discard, "", 0)
+	target.StartTLS()
+	defer target.Close()
+
+	mutual := TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "target.internal"}
+	cases := []struct {
+		name    string
+		tls     TLSConfig
+		success bool
+	}{
+		{name: "mutual TLS with SNI override", tls: mutual, success: true},
+		{name: "minimum version 1.3", tls: withMinVersion(mutual, "1.3"), success: true},
+		{name: "certificate name mismatch", tls: TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile}},
+		{name: "untrusted certificate", tls: TLSConfig{CertFile: certFile, KeyFile: keyFile, ServerName: "target.internal"}},
+		{name: "missing client certificate", tls: TLSConfig{CAFile: caFile, ServerName: "target.internal"}},
+		{name: "insecure skip verify", tls: TLSConfig{CertFile: certFile, KeyFile: keyFile, InsecureSkipVerify: true},
+			success: true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := DefaultTransportConfig()
+			cfg.TLS = tc.tls
+			httpClient, err := NewHTTPClient(cfg)
+			if err != nil {
+				t.Fatalf("unexpected client error: %v", err)
+			}
+			previous := client
+			client = httpClient
+			t.Cleanup(func() {
+				client = previous
+			})
+
+			collector := &categoryMetrics{}
+			result := executeRequest(context.Background(), target.URL, types.RequestSpec{Path: "/"}, 0, collector)
+			if result.success != tc.success {
+				t.Fatalf("expected success=%t, got %+v", tc.success, result)
+			}
+			if !tc.success && collector.categories[metrics.ErrorCategoryTLS] != 1 {
+				t.Fatalf("expected a TLS failure, got categories %v", collector.categories)
+			}
+		})
+	}
+}
+
+func TestTLSConfigRejectsInvalidSettings(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	invalid := []TLSConfig{
+		{MinVersion: "1.4"},
+		{CAFile: filepath.Join(dir, "missing.pem")},
+		{CAFile: notPEM},
+		{CertFile: notPEM},
+		{CertFile: notPEM, KeyFile: notPEM},
+	}
+	for _, cfg := range invalid {
+		if _, err := cfg.clientConfig(); err == nil {
+			t.Fatalf("expected TLS config %+v to be rejected", cfg)
+		}
+	}
+}
+
+func withMinVersion(cfg TLSConfig, version string) TLSConfig {
+	cfg.MinVersion = version
+	return cfg
+}
+
+type testCertificate struct {
+	certificate *x509.Certificate
+	der         []byte
+	key         *ecdsa.PrivateKey
+}
+
+// newTestCertificate issues a certificate signed by parent, or a self-signed CA when parent is nil.
+func newTestCertificate(t *testing.T, parent *testCertificate, commonName string, dnsNames []string) *testCertificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.certificate, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("unable to create certificate: %v", err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("unable to parse certificate: %v", err)
+	}
+	return &testCertificate{certificate: certificate, der: der, key: key}
+}
+
+func (c *testCertificate) keyDER(t *testing.T) []byte {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatalf("unable to marshal key: %v", err)
+	}
+	return der
+}
+
+func (c *testCertificate) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key, Leaf: c.certificate}
+}
+
+func writeTestPEM(t *testing.T, dir string, name string, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("unable to write %s: %v", name, err)
+	}
+	return path
+}
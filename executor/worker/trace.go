@@ -0,0 +1,106 @@
+package worker
+
+import (
+	"crypto/tls"
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/types"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestTrace captures the phases of a single request through net/http/httptrace. The transport may call the
+// hooks from its dialing goroutines, so every access is locked.
+type requestTrace struct {
+	lock sync.Mutex
+
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+
+	timings metrics.RequestTimings
+}
+
+func (r *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.mark(&r.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.measure(r.dnsStart, &r.timings.DNS)
+		},
+		ConnectStart: func(string, string) {
+			r.mark(&r.connectStart)
+		},
+		ConnectDone: func(_ string, _ string, err error) {
+			if err == nil {
+				r.measure(r.connectStart, &r.timings.Connect)
+			}
+		},
+		TLSHandshakeStart: func() {
+			r.mark(&r.tlsStart)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				r.measure(r.tlsStart, &r.timings.TLSHandshake)
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			r.mark(&r.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			r.mark(&r.firstByte)
+			r.measure(r.wroteRequest, &r.timings.FirstByte)
+		},
+	}
+}
+
+// mark records the current time into an unset phase start. With several dial attempts racing, the first one
+// to start marks the phase.
+func (r *requestTrace) mark(at *time.Time) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+func (r *requestTrace) measure(start time.Time, phase *time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !start.IsZero() && *phase == 0 {
+		*phase = time.Since(start)
+	}
+}
+
+// bodyRead ends the transfer phase once the response body has been fully read.
+func (r *requestTrace) bodyRead() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.firstByte.IsZero() {
+		r.timings.Transfer = time.Since(r.firstByte)
+	}
+}
+
+func (r *requestTrace) result() metrics.RequestTimings {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.timings
+}
+
+// recordPhases adds a request's timings to a job's phase histograms. Phases that did not happen are skipped.
+func recordPhases(phases *types.PhaseLatencies, timings metrics.RequestTimings) {
+	recordPhase(&phases.DNS, timings.DNS)
+	recordPhase(&phases.Connect, timings.Connect)
+	recordPhase(&phases.TLSHandshake, timings.TLSHandshake)
+	recordPhase(&phases.FirstByte, timings.FirstByte)
+	recordPhase(&phases.Transfer, timings.Transfer)
+}
+
+func recordPhase(histogram *types.LatencyHistogram, duration time.Duration) {
+	if duration > 0 {
+		histogram.Record(duration)
+	}
+}
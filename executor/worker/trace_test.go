@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"github.com/PeladoCollado/imager/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecuteRequestTracesRequestPhases(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("first chunk"))
+		w.(http.Flusher).Flush()
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte("second chunk"))
+	}))
+	server.StartTLS()
+	defer server.Close()
+
+	previous := client
+	client = server.Client()
+	t.Cleanup(func() {
+		client = previous
+	})
+
+	first := executeRequest(context.Background(), server.URL, types.RequestSpec{Path: "/"}, 0, &fakeMetrics{})
+	if !first.success {
+		t.Fatalf("expected first request to succeed, got %+v", first)
+	}
+	if first.timings.Connect <= 0 || first.timings.TLSHandshake <= 0 {
+		t.Fatalf("expected the first request to open a TLS connection, got %+v", first.timings)
+	}
+	if first.timings.DNS != 0 {
+		t.Fatalf("expected no DNS lookup for an IP target, got %s", first.timings.DNS)
+	}
+	if first.timings.FirstByte < 50*time.Millisecond {
+		t.Fatalf("expected first byte to include server time, got %s", first.timings.FirstByte)
+	}
+	if first.timings.Transfer < 50*time.Millisecond {
+		t.Fatalf("expected transfer to include the delayed second chunk, got %s", first.timings.Transfer)
+	}
+
+	second := executeRequest(context.Background(), server.URL, types.RequestSpec{Path: "/"}, 0, &fakeMetrics{})
+	if second.timings.Connect != 0 || second.timings.TLSHandshake != 0 {
+		t.Fatalf("expected the second request to reuse the connection, got %+v", second.timings)
+	}
+}
+
+func TestRunJobSummarizesRequestPhases(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	job := types.Job{
+		ID:             "job-phases",
+		Requests:       make([]types.RequestSpec, 4),
+		TargetURLs:     []string{strings.Replace(server.URL, "127.0.0.1", "localhost", 1)},
+		RatePerSec:     4,
+		DurationMillis: time.Second.Milliseconds(),
+	}
+
+	report := RunJob(context.Background(), job, &fakeMetrics{})
+	if report.SuccessCount != 4 {
+		t.Fatalf("expected 4 successful requests, got %+v", report)
+	}
+	if report.Phases.FirstByte.Count != 4 || report.Phases.Transfer.Count != 4 {
+		t.Fatalf("expected first byte and transfer for every request, got %+v", report.Phases)
+	}
+	if report.Phases.DNS.Count == 0 || report.Phases.Connect.Count == 0 {
+		t.Fatalf("expected DNS and connect for new connections, got %+v", report.Phases)
+	}
+	if report.Phases.TLSHandshake.Count != 0 {
+		t.Fatalf("expected no TLS handshakes against a plain HTTP target, got %d", report.Phases.TLSHandshake.Count)
+	}
+}
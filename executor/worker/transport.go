@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/PeladoCollado/imager/types"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	// HTTPVersionAuto negotiates HTTP/2 over TLS and uses HTTP/1.1 otherwise.
+	HTTPVersionAuto = ""
+	// HTTPVersion1 forces HTTP/1.1, even against targets that offer HTTP/2.
+	HTTPVersion1 = "1.1"
+	// HTTPVersion2 forces HTTP/2, over TLS or as cleartext HTTP/2 with prior knowledge.
+	HTTPVersion2 = "2"
+)
+
+// TransportConfig tunes the HTTP client executors send target requests with.
+type TransportConfig struct {
+	// RequestTimeout bounds each request, including reading the response body. Jobs can override it. Zero leaves
+	// requests bounded only by the job window.
+	RequestTimeout        time.Duration
+	DialTimeout           time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	MaxIdleConnsPerHost   int
+	// DisableKeepAlives opens a new connection for every request.
+	DisableKeepAlives bool
+	// HTTPVersion is HTTPVersionAuto, HTTPVersion1 or HTTPVersion2.
+	HTTPVersion string
+
+	TLS TLSConfig
+}
+
+// TLSConfig configures TLS for https targets. Files are PEM encoded, typically mounted from a Kubernetes Secret.
+type TLSConfig struct {
+	// CAFile holds the CA certificates that target certificates are verified against instead of the system pool.
+	CAFile string
+	// CertFile and KeyFile hold the client certificate presented to targets that require mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name sent for SNI and checked against the target certificate.
+	ServerName string
+	// MinVersion is the lowest accepted TLS version: 1.0, 1.1, 1.2 or 1.3. Empty keeps Go's default.
+	MinVersion         string
+	InsecureSkipVerify bool
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func (c TLSConfig) clientConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+	if c.MinVersion != "" {
+		version, ok := tlsVersions[c.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported tls-min-version %q", c.MinVersion)
+		}
+		config.MinVersion = version
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read tls-ca-file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls-ca-file %s contains no PEM certificates", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, fmt.Errorf("tls-cert-file and tls-key-file must be set together")
+	}
+	if c.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		DialTimeout:         30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		MaxIdleConnsPerHost: 100,
+	}
+}
+
+// NewHTTPClient builds a client for target requests from the transport config. Timeouts are left to the
+// per-request context so that jobs can override them.
+func NewHTTPClient(cfg TransportConfig) (*http.Client, error) {
+	if cfg.RequestTimeout < 0 || cfg.DialTimeout < 0 || cfg.TLSHandshakeTimeout < 0 || cfg.ResponseHeaderTimeout < 0 {
+		return nil, fmt.Errorf("request-timeout, dial-timeout, tls-handshake-timeout and response-header-timeout must be >= 0")
+	}
+	if cfg.MaxIdleConnsPerHost < 0 {
+		return nil, fmt.Errorf("max-idle-conns-per-host must be >= 0")
+	}
+	tlsConfig, err := cfg.TLS.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   cfg.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     cfg.DisableKeepAlives,
+		TLSClientConfig:       tlsConfig,
+	}
+	switch cfg.HTTPVersion {
+	case HTTPVersionAuto:
+		transport.ForceAttemptHTTP2 = true
+	case HTTPVersion1:
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP1(true)
+	case HTTPVersion2:
+		transport.Protocols = new(http.Protocols)
+		transport.Protocols.SetHTTP2(true)
+		transport.Protocols.SetUnencryptedHTTP2(true)
+	default:
+		return nil, fmt.Errorf("unsupported http-version %q", cfg.HTTPVersion)
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+// ConfigureTransport replaces the client used for target requests. It must be called before any job runs.
+func ConfigureTransport(cfg TransportConfig) error {
+	configured, err := NewHTTPClient(cfg)
+	if err != nil {
+		return err
+	}
+	client = configured
+	defaultRequestTimeout = cfg.RequestTimeout
+	return nil
+}
+
+// requestTimeout returns the per-request timeout for a job: the job's own, or else the executor default.
+func requestTimeout(job types.Job) time.Duration {
+	if timeout := job.RequestTimeout(); timeout > 0 {
+		return timeout
+	}
+	return defaultRequestTimeout
+}
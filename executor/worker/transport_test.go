@@ -0,0 +1,164 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/PeladoCollado/imager/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunJobTimesOutSlowRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	job := types.Job{
+		ID:                   "job-timeout",
+		Requests:             []types.RequestSpec{{Path: "/slow"}, {Path: "/fast"}},
+		TargetURLs:           []string{server.URL},
+		RatePerSec:           2,
+		DurationMillis:       time.Second.Milliseconds(),
+		RequestTimeoutMillis: 100,
+	}
+
+	started := time.Now()
+	report := RunJob(context.Background(), job, &fakeMetrics{})
+	if report.SuccessCount != 1 || report.FailureCount != 1 || report.TimeoutCount != 1 {
+		t.Fatalf("expected the slow request to time out, got %+v", report)
+	}
+	if elapsed := time.Since(started); elapsed > 1500*time.Millisecond {
+		t.Fatalf("expected the request timeout to cut the slow request short, took %s", elapsed)
+	}
+}
+
+func TestRequestTimeoutPrefersJobSetting(t *testing.T) {
+	previous := defaultRequestTimeout
+	defaultRequestTimeout = 5 * time.Second
+	t.Cleanup(func() {
+		defaultRequestTimeout = previous
+	})
+
+	if got := requestTimeout(types.Job{}); got != 5*time.Second {
+		t.Fatalf("expected executor default 5s, got %s", got)
+	}
+	if got := requestTimeout(types.Job{RequestTimeoutMillis: 250}); got != 250*time.Millisecond {
+		t.Fatalf("expected job timeout 250ms, got %s", got)
+	}
+}
+
+func TestErrorQualifiesAsTimeout(t *testing.T) {
+	cases := []struct {
+		err     error
+		timeout bool
+	}{
+		{err: context.DeadlineExceeded, timeout: true},
+		{err: fmt.Errorf("Get \"http://target\": %w", context.DeadlineExceeded), timeout: true},
+		{err: &net.OpError{Op: "dial", Err: timeoutError{}}, timeout: true},
+		{err: context.Canceled, timeout: false},
+		{err: errors.New("connection refused"), timeout: false},
+	}
+	for _, tc := range cases {
+		if got := errorQualifiesAsTimeout(tc.err); got != tc.timeout {
+			t.Fatalf("expected timeout=%t for %v, got %t", tc.timeout, tc.err, got)
+		}
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestNewHTTPClientForcesHTTPVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	serverTLS := server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	for version, expectedMajor := range map[string]int{HTTPVersionAuto: 2, HTTPVersion1: 1, HTTPVersion2: 2} {
+		cfg := DefaultTransportConfig()
+		cfg.HTTPVersion = version
+		httpClient, err := NewHTTPClient(cfg)
+		if err != nil {
+			t.Fatalf("unexpected client error: %v", err)
+		}
+		httpClient.Transport.(*http.Transport).TLSClientConfig = serverTLS.Clone()
+
+		response, err := httpClient.Get(server.URL)
+		if err != nil {
+			t.Fatalf("unexpected request error for http-version %q: %v", version, err)
+		}
+		response.Body.Close()
+		if response.ProtoMajor != expectedMajor {
+			t.Fatalf("expected HTTP/%d for http-version %q, got %s", expectedMajor, version, response.Proto)
+		}
+	}
+}
+
+func TestNewHTTPClientDisablesKeepAlives(t *testing.T) {
+	var connections atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	for _, disable := range []bool{false, true} {
+		connections.Store(0)
+		cfg := DefaultTransportConfig()
+		cfg.DisableKeepAlives = disable
+		httpClient, err := NewHTTPClient(cfg)
+		if err != nil {
+			t.Fatalf("unexpected client error: %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			response, err := httpClient.Get(server.URL)
+			if err != nil {
+				t.Fatalf("unexpected request error: %v", err)
+			}
+			response.Body.Close()
+		}
+		expected := int32(1)
+		if disable {
+			expected = 3
+		}
+		if got := connections.Load(); got != expected {
+			t.Fatalf("expected %d connections with disable-keep-alives=%t, got %d", expected, disable, got)
+		}
+	}
+}
+
+func TestNewHTTPClientRejectsInvalidConfig(t *testing.T) {
+	invalid := []TransportConfig{
+		{HTTPVersion: "3"},
+		{DialTimeout: -time.Second},
+		{MaxIdleConnsPerHost: -1},
+	}
+	for _, cfg := range invalid {
+		if _, err := NewHTTPClient(cfg); err == nil {
+			t.Fatalf("expected config %+v to be rejected", cfg)
+		}
+	}
+}
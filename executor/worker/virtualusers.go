@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"context"
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/types"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// failureBackoff is the shortest pause a virtual user takes after a failed request, so that a user with no think
+// time does not spin on requests that fail at once, such as an invalid spec or a refused connection.
+var failureBackoff = 50 * time.Millisecond
+
+// runVirtualUsers runs a closed workload model: each virtual user loops request -> think time -> request until
+// the send window closes. The job's requests are a shared pool that users cycle through. Results are added to the
+// report as they arrive, since a user with no think time can send any number of requests.
+func runVirtualUsers(sendCtx context.Context,
+	requestCtx context.Context,
+	job types.Job,
+	report *types.JobReport,
+	metricsCollector metrics.MetricsCollector) {
+	if job.VirtualUsers <= 0 || len(job.Requests) == 0 {
+		return
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var nextIndex int
+	takeNext := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		idx := nextIndex
+		nextIndex++
+		return idx
+	}
+
+	seed := time.Now().UnixNano()
+	if job.Arrival != nil && job.Arrival.Seed != 0 {
+		seed = job.Arrival.Seed
+	}
+	for user := 0; user < job.VirtualUsers; user++ {
+		wg.Add(1)
+		go func(rng *rand.Rand) {
+			defer wg.Done()
+			for sendCtx.Err() == nil {
+				idx := takeNext()
+				requestSpec := job.Requests[idx%len(job.Requests)]
+				target := job.TargetURLs[idx%len(job.TargetURLs)]
+
+				result := executeRequest(requestCtx, target, requestSpec, requestTimeout(job), metricsCollector)
+				// A virtual user never sends before its previous response arrives, so there is no
+				// scheduled-start backlog to correct for.
+				result.correctedDuration = result.duration
+				lock.Lock()
+				recordResult(report, result)
+				lock.Unlock()
+
+				pause := nextThinkTime(rng, job.ThinkTime)
+				if !result.success {
+					pause = max(pause, failureBackoff)
+				}
+				if !sleepFor(sendCtx, pause) {
+					return
+				}
+			}
+		}(rand.New(rand.NewSource(seed + int64(user))))
+	}
+	wg.Wait()
+}
+
+// nextThinkTime samples the pause a virtual user takes before its next request.
+func nextThinkTime(rng *rand.Rand, thinkTime *types.ThinkTime) time.Duration {
+	if thinkTime == nil {
+		return 0
+	}
+	switch thinkTime.Distribution {
+	case types.ThinkTimeUniform:
+		if thinkTime.MaxMillis <= thinkTime.MinMillis {
+			return time.Duration(thinkTime.MinMillis) * time.Millisecond
+		}
+		spread := thinkTime.MaxMillis - thinkTime.MinMillis
+		return time.Duration(thinkTime.MinMillis+rng.Int63n(spread+1)) * time.Millisecond
+	case types.ThinkTimeExponential:
+		return time.Duration(rng.ExpFloat64() * float64(thinkTime.Mean()))
+	default:
+		return thinkTime.Mean()
+	}
+}
+
+// sleepFor pauses for the given duration and reports false if the context ends first.
+func sleepFor(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	return waitUntil(ctx, time.Now().Add(d))
+}
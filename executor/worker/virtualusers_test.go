@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"github.com/PeladoCollado/imager/types"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunJobClosedModelBoundsConcurrencyToVirtualUsers(t *testing.T) {
+	var lock sync.Mutex
+	inFlight := 0
+	maxInFlight := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		lock.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		lock.Lock()
+		inFlight--
+		lock.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	job := types.Job{
+		ID:             "job-closed",
+		Requests:       []types.RequestSpec{{Method: "GET", Path: "/a"}, {Method: "GET", Path: "/b"}},
+		TargetURLs:     []string{server.URL},
+		DurationMillis: 500,
+		LoadModel:      types.LoadModelClosed,
+		VirtualUsers:   2,
+		ThinkTime:      &types.ThinkTime{Distribution: types.ThinkTimeConstant, MeanMillis: 30},
+	}
+
+	report := RunJob(context.Background(), job, &fakeMetrics{})
+
+	lock.Lock()
+	defer lock.Unlock()
+	if maxInFlight > 2 {
+		t.Fatalf("expected at most 2 concurrent requests, got %d", maxInFlight)
+	}
+	// Each user loops every ~50ms for 500ms, so both users together should complete well over the pool size.
+	if report.CompletedRequests < 6 {
+		t.Fatalf("expected virtual users to cycle through the request pool, completed %d", report.CompletedRequests)
+	}
+	if report.SuccessCount != report.CompletedRequests {
+		t.Fatalf("expected every request to succeed, got %+v", report)
+	}
+	if report.ThroughputRPS <= 0 {
+		t.Fatalf("expected achieved throughput to be reported, got %f", report.ThroughputRPS)
+	}
+}
+
+func TestRunJobClosedModelBacksOffAfterFailures(t *testing.T) {
+	job := types.Job{
+		ID:             "job-closed-failing",
+		Requests:       []types.RequestSpec{{Method: "GET", Path: "/a", BodyFile: "/missing/body.bin"}},
+		TargetURLs:     []string{"http://127.0.0.1:1"},
+		DurationMillis: 200,
+		LoadModel:      types.LoadModelClosed,
+		VirtualUsers:   1,
+	}
+
+	report := RunJob(context.Background(), job, &fakeMetrics{})
+	// Without think time, the user waits out the failure backoff between requests that fail at once.
+	if report.CompletedRequests == 0 || report.CompletedRequests > 5 {
+		t.Fatalf("expected a few requests a failure backoff apart, completed %d", report.CompletedRequests)
+	}
+	if report.FailureCount != report.CompletedRequests {
+		t.Fatalf("expected every request to fail, got %+v", report)
+	}
+}
+
+func TestNextThinkTimeDistributions(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	constant := &types.ThinkTime{Distribution: types.ThinkTimeConstant, MeanMillis: 250}
+	if got := nextThinkTime(rng, constant); got != 250*time.Millisecond {
+		t.Fatalf("expected constant think time 250ms, got %s", got)
+	}
+
+	uniform := &types.ThinkTime{Distribution: types.ThinkTimeUniform, MinMillis: 100, MaxMillis: 200}
+	for i := 0; i < 100; i++ {
+		got := nextThinkTime(rng, uniform)
+		if got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("uniform think time out of bounds: %s", got)
+		}
+	}
+
+	exponential := &types.ThinkTime{Distribution: types.ThinkTimeExponential, MeanMillis: 100}
+	var total time.Duration
+	for i := 0; i < 2000; i++ {
+		total += nextThinkTime(rng, exponential)
+	}
+	if mean := total / 2000; mean < 80*time.Millisecond || mean > 120*time.Millisecond {
+		t.Fatalf("expected exponential think time mean near 100ms, got %s", mean)
+	}
+
+	if got := nextThinkTime(rng, nil); got != 0 {
+		t.Fatalf("expected no think time without a spec, got %s", got)
+	}
+}
@@ -1,7 +1,6 @@
 package worker
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"github.com/PeladoCollado/imager/metrics"
@@ -9,19 +8,23 @@ import (
 	"github.com/PeladoCollado/imager/types"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
-var client http.Client
+var client = &http.Client{}
+
+// defaultRequestTimeout applies to jobs that do not set their own request timeout.
+var defaultRequestTimeout time.Duration
 
 func RunJob(ctx context.Context, job types.Job, metricsCollector metrics.MetricsCollector) types.JobReport {
 	report := types.JobReport{
 		JobID:           job.ID,
 		RoundID:         job.RoundID,
 		PlannedRequests: job.RequestedCount(),
-		LatencyMillis:   make([]int64, 0, job.RequestedCount()),
 	}
 	metricsCollector.RecordJobPickedUp(job.RequestedCount())
 
@@ -37,50 +40,200 @@ func RunJob(ctx context.Context, job types.Job, metricsCollector metrics.Metrics
 		return report
 	}
 
-	runCtx, cancel := context.WithTimeout(ctx, jobDuration)
-	defer cancel()
+	// New sends are only scheduled inside the job window. Requests that are still in flight when the
+	// window closes get one more job duration to finish. The orchestrator waits three job durations for
+	// a round's reports, which leaves a job duration for dispatch and for posting the report.
+	sendCtx, cancelSends := context.WithTimeout(ctx, jobDuration)
+	defer cancelSends()
+	requestCtx, cancelRequests := context.WithTimeout(ctx, 2*jobDuration)
+	defer cancelRequests()
 
-	for idx, requestSpec := range job.Requests {
-		select {
-		case <-runCtx.Done():
-			return report
-		default:
+	start := time.Now()
+	if job.LoadModel == types.LoadModelClosed {
+		runVirtualUsers(sendCtx, requestCtx, job, &report, metricsCollector)
+	} else {
+		for _, result := range runOpenModel(sendCtx, requestCtx, job, jobDuration, &report, metricsCollector) {
+			recordResult(&report, result)
 		}
+	}
+	elapsed := time.Since(start)
+	if elapsed > 0 {
+		report.ThroughputRPS = float64(report.CompletedRequests) / elapsed.Seconds()
+	}
+	return report
+}
 
-		target := job.TargetURLs[idx%len(job.TargetURLs)]
-		result := executeRequest(runCtx, target, requestSpec, metricsCollector)
-		if !result.executed {
-			continue
+// recordResult adds the result of one executed request, or scenario, to the report.
+func recordResult(report *types.JobReport, result requestResult) {
+	if !result.executed {
+		return
+	}
+	report.CompletedRequests++
+	report.Latency.Record(result.duration)
+	report.CorrectedLatency.Record(result.correctedDuration)
+	recordPhases(&report.Phases, result.timings)
+	if result.success {
+		report.SuccessCount++
+	} else {
+		report.FailureCount++
+	}
+	if result.timeout {
+		report.TimeoutCount++
+	}
+	if result.category != "" {
+		if report.Errors == nil {
+			report.Errors = make(map[string]int)
 		}
-		report.CompletedRequests++
-		report.LatencyMillis = append(report.LatencyMillis, result.duration.Milliseconds())
-		if result.success {
-			report.SuccessCount++
-		} else {
-			report.FailureCount++
+		report.Errors[result.category]++
+	}
+	if result.category == metrics.ErrorCategoryAssertion {
+		report.AssertionFailureCount++
+	}
+	if result.name != "" {
+		report.Names = recordNamedResult(report.Names, result)
+	}
+	for _, step := range result.steps {
+		recordPhases(&report.Phases, step.timings)
+		report.Steps = recordNamedResult(report.Steps, step)
+	}
+}
+
+// recordNamedResult adds a result to the entry for its name, allocating results on first use.
+func recordNamedResult(results map[string]types.NamedResults, result requestResult) map[string]types.NamedResults {
+	if results == nil {
+		results = make(map[string]types.NamedResults)
+	}
+	named := results[result.name]
+	named.CompletedRequests++
+	named.Latency.Record(result.duration)
+	if result.success {
+		named.SuccessCount++
+	} else {
+		named.FailureCount++
+	}
+	if result.timeout {
+		named.TimeoutCount++
+	}
+	if result.category == metrics.ErrorCategoryAssertion {
+		named.AssertionFailureCount++
+	}
+	results[result.name] = named
+	return results
+}
+
+// runOpenModel sends each request at its intended start time, evenly spaced at the job rate, and records the
+// send lag on the report.
+func runOpenModel(sendCtx context.Context,
+	requestCtx context.Context,
+	job types.Job,
+	jobDuration time.Duration,
+	report *types.JobReport,
+	metricsCollector metrics.MetricsCollector) []requestResult {
+	offsets := arrivalOffsets(job, jobDuration)
+	results := make(chan requestResult, len(job.Requests))
+	var inFlight sync.WaitGroup
+	var totalLag time.Duration
+	sent := 0
+
+	start := time.Now()
+	for idx, requestSpec := range job.Requests {
+		intendedStart := start.Add(offsets[idx])
+		if !waitUntil(sendCtx, intendedStart) {
+			break
 		}
-		if result.timeout {
-			report.TimeoutCount++
+		lag := time.Since(intendedStart)
+		totalLag += lag
+		sent++
+		if lagMillis := lag.Milliseconds(); lagMillis > report.MaxSendLagMillis {
+			report.MaxSendLagMillis = lagMillis
 		}
+
+		target := job.TargetURLs[idx%len(job.TargetURLs)]
+		inFlight.Add(1)
+		go func(target string, requestSpec types.RequestSpec, intendedStart time.Time) {
+			defer inFlight.Done()
+			result := executeRequest(requestCtx, target, requestSpec, requestTimeout(job), metricsCollector)
+			result.correctedDuration = time.Since(intendedStart)
+			results <- result
+		}(target, requestSpec, intendedStart)
+	}
+	inFlight.Wait()
+	close(results)
+
+	if sent > 0 {
+		report.MeanSendLagMillis = (totalLag / time.Duration(sent)).Milliseconds()
+	}
+	collected := make([]requestResult, 0, sent)
+	for result := range results {
+		collected = append(collected, result)
+	}
+	return collected
+}
+
+// waitUntil blocks until the given time and reports false if the context ends first.
+func waitUntil(ctx context.Context, at time.Time) bool {
+	wait := time.Until(at)
+	if wait <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
-	return report
 }
 
 type requestResult struct {
 	executed bool
+	name     string
 	success  bool
 	timeout  bool
+	// category is the metrics.ErrorCategory of a failed request.
+	category string
+	// duration is the service time, measured from when the request was actually sent.
 	duration time.Duration
+	// correctedDuration is measured from the request's scheduled start, so send lag caused by a stalled
+	// target or a saturated executor is included (coordinated-omission correction).
+	correctedDuration time.Duration
+	timings           metrics.RequestTimings
+	// steps holds the per-step results of a scenario, named "<scenario>/<step>".
+	steps []requestResult
 }
 
+// executeRequest runs one element of a job: a single request, or a whole scenario.
 func executeRequest(ctx context.Context,
 	target string,
 	requestSpec types.RequestSpec,
+	timeout time.Duration,
 	metricsCollector metrics.MetricsCollector) requestResult {
+	if requestSpec.Scenario != nil {
+		return runScenario(ctx, target, requestSpec, timeout, metricsCollector)
+	}
+	return sendRequest(ctx, client, target, requestSpec, nil, nil, timeout, metricsCollector)
+}
+
+// sendRequest sends one request with httpClient. Once the response passes its checks, extract captures values
+// from it into variables.
+func sendRequest(ctx context.Context,
+	httpClient *http.Client,
+	target string,
+	requestSpec types.RequestSpec,
+	extract []types.Extraction,
+	variables map[string]string,
+	timeout time.Duration,
+	metricsCollector metrics.MetricsCollector) requestResult {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	name := requestSpec.ResultName()
 	requestURL, err := buildRequestURL(target, requestSpec.Path, requestSpec.QueryString)
 	if err != nil {
-		metricsCollector.PostFailure(metrics.ErrorEvent{ErrMsg: err.Error()})
-		return requestResult{executed: true}
+		return invalidRequest(metricsCollector, requestSpec, name, err)
 	}
 
 	method := requestSpec.Method
@@ -88,78 +241,183 @@ func executeRequest(ctx context.Context,
 		method = http.MethodGet
 	}
 
-	var body io.Reader
-	if requestSpec.Body != "" {
-		body = bytes.NewBufferString(requestSpec.Body)
+	body, reopen, length, contentType, err := requestBody(requestSpec)
+	if err != nil {
+		return invalidRequest(metricsCollector, requestSpec, name, err)
 	}
-
-	request, err := http.NewRequestWithContext(ctx, method, requestURL, body)
+	request, err := http.NewRequestWithContext(ctx, method, requestURL, nil)
 	if err != nil {
-		metricsCollector.PostFailure(metrics.ErrorEvent{ErrMsg: err.Error()})
-		return requestResult{executed: true}
+		if body != nil {
+			body.Close()
+		}
+		return invalidRequest(metricsCollector, requestSpec, name, err)
 	}
 	for key, values := range requestSpec.Headers {
 		request.Header[key] = append([]string(nil), values...)
 	}
+	setBody(request, body, reopen, length, contentType)
+
+	trace := &requestTrace{}
+	request = request.WithContext(httptrace.WithClientTrace(request.Context(), trace.clientTrace()))
 
 	start := time.Now()
-	response, err := client.Do(request)
-	firstByteDuration := time.Since(start)
+	response, err := httpClient.Do(request)
+	responseDuration := time.Since(start)
 	if err != nil {
+		category := classifyError(err)
 		metricsCollector.PostFailure(metrics.ErrorEvent{
 			Status:   0,
 			ErrMsg:   err.Error(),
-			Duration: firstByteDuration,
+			Duration: responseDuration,
+			Name:     name,
+			Tags:     requestSpec.Tags,
+			Category: category,
 		})
 		return requestResult{
 			executed: true,
-			timeout:  errorQualifiesAsTimeout(err, firstByteDuration),
-			duration: firstByteDuration,
+			name:     name,
+			timeout:  category == metrics.ErrorCategoryTimeout,
+			category: category,
+			duration: responseDuration,
+			timings:  trace.result(),
 		}
 	}
 	defer response.Body.Close()
 
-	if response.StatusCode >= 300 {
+	expect := requestSpec.Expect
+	if expect != nil && len(expect.Status) > 0 && !acceptsStatus(expect, response.StatusCode) {
+		err := fmt.Errorf("unexpected status %d, expected one of %v", response.StatusCode, expect.Status)
+		result := failAssertion(metricsCollector, requestSpec, name, response.StatusCode, err, responseDuration)
+		result.timings = trace.result()
+		return result
+	}
+	if !acceptsStatus(expect, response.StatusCode) {
 		errMsg := readErrorBody(response.Body)
+		category := statusCategory(response.StatusCode)
 		metricsCollector.PostFailure(metrics.ErrorEvent{
 			Status:   response.StatusCode,
 			ErrMsg:   errMsg,
-			Duration: firstByteDuration,
+			Duration: responseDuration,
+			Name:     name,
+			Tags:     requestSpec.Tags,
+			Category: category,
 		})
 		return requestResult{
 			executed: true,
+			name:     name,
 			timeout:  statusQualifiesAsTimeout(response.StatusCode),
-			duration: firstByteDuration,
+			category: category,
+			duration: responseDuration,
+			timings:  trace.result(),
 		}
 	}
 
-	bytesRead, readErr := io.Copy(io.Discard, response.Body)
+	var responseBody []byte
+	var bytesRead int64
+	var readErr error
+	var tooLarge error
+	if expectsBody(expect) || extractsBody(extract) {
+		// Only one byte past the limit is read, so an oversized response never has to fit in memory.
+		limit := bufferedBodyLimit(expect)
+		responseBody, readErr = io.ReadAll(io.LimitReader(response.Body, limit+1))
+		bytesRead = int64(len(responseBody))
+		if bytesRead > limit {
+			tooLarge = fmt.Errorf("response body exceeds the %d byte limit", limit)
+		}
+	} else {
+		bytesRead, readErr = io.Copy(io.Discard, response.Body)
+	}
 	if readErr != nil {
+		category := classifyError(readErr)
 		metricsCollector.PostFailure(metrics.ErrorEvent{
 			Status:   response.StatusCode,
 			ErrMsg:   readErr.Error(),
 			Duration: time.Since(start),
+			Name:     name,
+			Tags:     requestSpec.Tags,
+			Category: category,
 		})
 		return requestResult{
 			executed: true,
+			name:     name,
+			timeout:  category == metrics.ErrorCategoryTimeout,
+			category: category,
 			duration: time.Since(start),
+			timings:  trace.result(),
 		}
 	}
 
 	duration := time.Since(start)
+	trace.bodyRead()
+	timings := trace.result()
+	if tooLarge != nil {
+		result := failAssertion(metricsCollector, requestSpec, name, response.StatusCode, tooLarge, duration)
+		result.timings = timings
+		return result
+	}
+	if err := checkResponse(expect, response.Header, responseBody, bytesRead); err != nil {
+		result := failAssertion(metricsCollector, requestSpec, name, response.StatusCode, err, duration)
+		result.timings = timings
+		return result
+	}
+	if err := extractValues(extract, response, responseBody, httpClient.Jar, variables); err != nil {
+		result := failAssertion(metricsCollector, requestSpec, name, response.StatusCode, err, duration)
+		result.timings = timings
+		return result
+	}
 	metricsCollector.PostSuccess(metrics.SuccessEvent{
-		Status:        response.StatusCode,
-		ResponseSize:  bytesRead,
-		Duration:      duration,
-		FirstByteTime: firstByteDuration,
+		Status:       response.StatusCode,
+		ResponseSize: bytesRead,
+		Duration:     duration,
+		Timings:      timings,
+		Name:         name,
+		Tags:         requestSpec.Tags,
 	})
 	return requestResult{
 		executed: true,
+		name:     name,
 		success:  true,
 		duration: duration,
+		timings:  timings,
+	}
+}
+
+func failAssertion(metricsCollector metrics.MetricsCollector,
+	requestSpec types.RequestSpec,
+	name string,
+	statusCode int,
+	err error,
+	duration time.Duration) requestResult {
+	metricsCollector.PostFailure(metrics.ErrorEvent{
+		Status:   statusCode,
+		ErrMsg:   err.Error(),
+		Duration: duration,
+		Name:     name,
+		Tags:     requestSpec.Tags,
+		Category: metrics.ErrorCategoryAssertion,
+	})
+	return requestResult{
+		executed: true,
+		name:     name,
+		category: metrics.ErrorCategoryAssertion,
+		duration: duration,
 	}
 }
 
+// invalidRequest records a request that could not be built, so it was never sent.
+func invalidRequest(metricsCollector metrics.MetricsCollector,
+	requestSpec types.RequestSpec,
+	name string,
+	err error) requestResult {
+	metricsCollector.PostFailure(metrics.ErrorEvent{
+		ErrMsg:   err.Error(),
+		Name:     name,
+		Tags:     requestSpec.Tags,
+		Category: metrics.ErrorCategoryInvalidRequest,
+	})
+	return requestResult{executed: true, name: name, category: metrics.ErrorCategoryInvalidRequest}
+}
+
 func buildRequestURL(targetBaseURL string, path string, query string) (string, error) {
 	baseURL, err := url.Parse(targetBaseURL)
 	if err != nil {
@@ -193,10 +451,3 @@ func readErrorBody(body io.Reader) string {
 func statusQualifiesAsTimeout(statusCode int) bool {
 	return statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
 }
-
-func errorQualifiesAsTimeout(_ error, duration time.Duration) bool {
-	if duration >= time.Minute {
-		return true
-	}
-	return false
-}
@@ -6,6 +6,7 @@ import (
 	"github.com/PeladoCollado/imager/types"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -16,6 +17,7 @@ type fakeMetrics struct {
 
 	successes int
 	failures  int
+	names     []string
 
 	jobsPicked      int
 	requestsPlanned int
@@ -25,12 +27,14 @@ func (f *fakeMetrics) PostSuccess(event metrics.SuccessEvent) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	f.successes++
+	f.names = append(f.names, event.Name)
 }
 
 func (f *fakeMetrics) PostFailure(event metrics.ErrorEvent) {
 	f.lock.Lock()
 	defer f.lock.Unlock()
 	f.failures++
+	f.names = append(f.names, event.Name)
 }
 
 func (f *fakeMetrics) RecordJobPickedUp(requestCount int) {
@@ -134,6 +138,129 @@ func TestRunJobCounts503And504AsTimeouts(t *testing.T) {
 	}
 }
 
+func TestRunJobBreaksDownResultsByRequestName(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/write" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	job := types.Job{
+		ID: "job-named",
+		Requests: []types.RequestSpec{
+			{Method: "GET", Path: "/read", Name: "read"},
+			{Method: "GET", Path: "/read", Source: "reads"},
+			{Method: "POST", Path: "/write", Name: "write", Tags: map[string]string{"team": "checkout"}},
+			{Method: "GET", Path: "/"},
+		},
+		TargetURLs:     []string{server.URL},
+		RatePerSec:     4,
+		DurationMillis: time.Second.Milliseconds(),
+	}
+	collector := &fakeMetrics{}
+
+	report := RunJob(context.Background(), job, collector)
+	if report.CompletedRequests != 4 {
+		t.Fatalf("expected 4 completed requests, got %d", report.CompletedRequests)
+	}
+	if len(report.Names) != 3 {
+		t.Fatalf("expected results for read, reads and write, got %+v", report.Names)
+	}
+	read := report.Names["read"]
+	if read.CompletedRequests != 1 || read.SuccessCount != 1 || read.Latency.Count != 1 {
+		t.Fatalf("unexpected read results: %+v", read)
+	}
+	if reads := report.Names["reads"]; reads.SuccessCount != 1 {
+		t.Fatalf("expected source to name unnamed mix requests, got %+v", reads)
+	}
+	if write := report.Names["write"]; write.FailureCount != 1 || write.SuccessCount != 0 {
+		t.Fatalf("unexpected write results: %+v", write)
+	}
+
+	slices.Sort(collector.names)
+	if !slices.Equal(collector.names, []string{"", "read", "reads", "write"}) {
+		t.Fatalf("expected metric events to carry request names, got %v", collector.names)
+	}
+}
+
+func TestRunJobPacesRequestsAtRatePerSec(t *testing.T) {
+	var lock sync.Mutex
+	arrivals := make([]time.Time, 0, 5)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		arrivals = append(arrivals, time.Now())
+		lock.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	job := types.Job{
+		ID:             "job-paced",
+		Requests:       make([]types.RequestSpec, 5),
+		TargetURLs:     []string{server.URL},
+		RatePerSec:     20,
+		DurationMillis: time.Second.Milliseconds(),
+	}
+
+	report := RunJob(context.Background(), job, &fakeMetrics{})
+	if report.CompletedRequests != 5 {
+		t.Fatalf("expected completed requests=5, got %d", report.CompletedRequests)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if len(arrivals) != 5 {
+		t.Fatalf("expected 5 upstream calls, got %d", len(arrivals))
+	}
+	slices.SortFunc(arrivals, func(a, b time.Time) int { return a.Compare(b) })
+	if spread := arrivals[4].Sub(arrivals[0]); spread < 180*time.Millisecond {
+		t.Fatalf("expected requests to be spread over ~200ms at 20 rps, got %s", spread)
+	}
+}
+
+func TestRunJobSlowResponsesDoNotDelayLaterSends(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	job := types.Job{
+		ID:             "job-slow",
+		Requests:       make([]types.RequestSpec, 10),
+		TargetURLs:     []string{server.URL},
+		RatePerSec:     10,
+		DurationMillis: time.Second.Milliseconds(),
+	}
+
+	started := time.Now()
+	report := RunJob(context.Background(), job, &fakeMetrics{})
+	elapsed := time.Since(started)
+
+	if report.SuccessCount != 10 {
+		t.Fatalf("expected 10 successful requests, got %+v", report)
+	}
+	if elapsed > 2*time.Second {
+		t.Fatalf("expected concurrent dispatch to finish well under serial time, took %s", elapsed)
+	}
+	if report.MaxSendLagMillis > 100 {
+		t.Fatalf("expected send lag to stay small with concurrent dispatch, got %dms", report.MaxSendLagMillis)
+	}
+	if report.CorrectedLatency.Count != report.Latency.Count {
+		t.Fatalf("expected a corrected latency per service latency, got %d and %d",
+			report.CorrectedLatency.Count, report.Latency.Count)
+	}
+	for _, q := range []float64{0, 0.5, 0.99, 1} {
+		if report.CorrectedLatency.Quantile(q) < report.Latency.Quantile(q) {
+			t.Fatalf("corrected latency %s should never be below service time %s at quantile %.2f",
+				report.CorrectedLatency.Quantile(q), report.Latency.Quantile(q), q)
+		}
+	}
+}
+
 func TestBuildRequestURL(t *testing.T) {
 	url, err := buildRequestURL("http://example.local:8080", "/hello", "a=b")
 	if err != nil {
@@ -1,21 +1,83 @@
 package metrics
 
 import (
+	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
+	"sync"
 	"time"
 )
 
+// DefaultMaxRequestNames bounds how many distinct request names an executor publishes as label values.
+const DefaultMaxRequestNames = 20
+
+// OverflowRequestName is the name label used once the distinct request name limit has been reached.
+const OverflowRequestName = "other"
+
 type SuccessEvent struct {
-	Status        int
-	ResponseSize  int64
-	Duration      time.Duration
-	FirstByteTime time.Duration
+	Status       int
+	ResponseSize int64
+	Duration     time.Duration
+	Timings      RequestTimings
+	// Name and Tags come from the RequestSpec; Name is empty for unnamed requests.
+	Name string
+	Tags map[string]string
+}
+
+// RequestTimings breaks a request down into phases measured with net/http/httptrace. Phases that did not happen,
+// such as DNS and connect on a reused connection, are zero.
+type RequestTimings struct {
+	DNS          time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	// FirstByte is the wait from the request being fully written to the first response byte: server processing
+	// time plus one network round trip.
+	FirstByte time.Duration
+	// Transfer is the time from the first response byte until the body was fully read.
+	Transfer time.Duration
+}
+
+// Error categories classify every failed request.
+const (
+	// ErrorCategoryInvalidRequest marks a request that could not be built, e.g. from a malformed URL.
+	ErrorCategoryInvalidRequest = "invalid_request"
+	// ErrorCategoryDNS marks a target host name that did not resolve.
+	ErrorCategoryDNS = "dns"
+	// ErrorCategoryConnectionRefused marks a target that refused the TCP connection.
+	ErrorCategoryConnectionRefused = "connection_refused"
+	// ErrorCategoryConnectionReset marks a connection the target reset or closed mid-request.
+	ErrorCategoryConnectionReset = "connection_reset"
+	// ErrorCategoryTLS marks a failure of the TLS handshake with the target.
+	ErrorCategoryTLS = "tls"
+	// ErrorCategoryTimeout marks a request that ran past a request, transport or job deadline.
+	ErrorCategoryTimeout = "timeout"
+	// ErrorCategoryClientError and ErrorCategoryServerError mark 4xx and 5xx responses.
+	ErrorCategoryClientError = "client_error"
+	ErrorCategoryServerError = "server_error"
+	// ErrorCategoryUnexpectedStatus marks any other status that was not accepted, such as an unfollowed 3xx.
+	ErrorCategoryUnexpectedStatus = "unexpected_status"
+	// ErrorCategoryAssertion marks a failure where the response arrived but failed its RequestSpec.Expect checks.
+	ErrorCategoryAssertion = "assertion"
+	// ErrorCategoryOther covers failures that fit no other category.
+	ErrorCategoryOther = "other"
+)
+
+// StatusClass groups a status code into its class, such as "5xx", or "none" when no response was received.
+func StatusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "none"
+	}
+	return fmt.Sprintf("%dxx", status/100)
 }
 
 type ErrorEvent struct {
 	Status   int
 	ErrMsg   string
 	Duration time.Duration
+	// Name and Tags come from the RequestSpec; Name is empty for unnamed requests.
+	Name string
+	Tags map[string]string
+	// Category is one of the ErrorCategory constants.
+	Category string
 }
 
 type MetricsCollector interface {
@@ -25,29 +87,53 @@ type MetricsCollector interface {
 }
 
 func NewPrometheusMetricsCollector(r prometheus.Registerer) MetricsCollector {
+	return NewPrometheusMetricsCollectorWithNameLimit(r, DefaultMaxRequestNames)
+}
+
+// NewPrometheusMetricsCollectorWithNameLimit labels request metrics with the request name. After maxNames distinct
+// names have been seen, further names are published as OverflowRequestName to bound series cardinality.
+func NewPrometheusMetricsCollectorWithNameLimit(r prometheus.Registerer, maxNames int) MetricsCollector {
 	c := &PrometheusMetricsCollector{
-		duration: prometheus.NewHistogram(prometheus.HistogramOpts{Name: "duration",
+		names: &nameLimiter{limit: maxNames, seen: make(map[string]struct{})},
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "duration",
 			Namespace: "imager",
 			Help:      "Request duration",
-			Buckets:   timeBuckets()}),
-		successDuration: prometheus.NewHistogram(prometheus.HistogramOpts{Name: "successDuration",
+			Buckets:   timeBuckets()}, []string{"name"}),
+		successDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "successDuration",
 			Namespace: "imager",
 			Help:      "Successful request duration",
-			Buckets:   timeBuckets()}),
-		responseSize: prometheus.NewHistogram(prometheus.HistogramOpts{Name: "responseSize",
+			Buckets:   timeBuckets()}, []string{"name"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "responseSize",
 			Namespace: "imager",
 			Help:      "Response size",
-			Buckets:   sizeBuckets()}),
-		firstByteDuration: prometheus.NewHistogram(prometheus.HistogramOpts{Name: "firstByteDuration",
+			Buckets:   sizeBuckets()}, []string{"name"}),
+		firstByteDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "firstByteDuration",
+			Namespace: "imager",
+			Help:      "Time from the request being written to the first response byte",
+			Buckets:   timeBuckets()}, []string{"name"}),
+		dnsDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "dnsDuration",
+			Namespace: "imager",
+			Help:      "DNS lookup time for requests that resolved the target",
+			Buckets:   timeBuckets()}, []string{"name"}),
+		connectDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "connectDuration",
+			Namespace: "imager",
+			Help:      "TCP connect time for requests that opened a new connection",
+			Buckets:   timeBuckets()}, []string{"name"}),
+		tlsHandshakeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "tlsHandshakeDuration",
 			Namespace: "imager",
-			Help:      "Time to first byte",
-			Buckets:   timeBuckets()}),
-		successCounter: prometheus.NewCounter(prometheus.CounterOpts{Name: "success",
+			Help:      "TLS handshake time for requests that opened a new TLS connection",
+			Buckets:   timeBuckets()}, []string{"name"}),
+		transferDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "transferDuration",
 			Namespace: "imager",
-			Help:      "Number of successful requests served"}),
-		failedCounter: prometheus.NewCounter(prometheus.CounterOpts{Name: "failed",
+			Help:      "Time from the first response byte until the body was read",
+			Buckets:   timeBuckets()}, []string{"name"}),
+		successCounter: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "success",
 			Namespace: "imager",
-			Help:      "Number of failed requests"}),
+			Help:      "Number of successful requests served"}, []string{"name"}),
+		failedCounter: prometheus.NewCounterVec(prometheus.CounterOpts{Name: "failed",
+			Namespace: "imager",
+			Help:      "Number of failed requests by error category and response status class"},
+			[]string{"name", "category", "status_class"}),
 		jobsPickedUp: prometheus.NewCounter(prometheus.CounterOpts{Name: "executor_jobs_picked_up_total",
 			Namespace: "imager",
 			Help:      "Number of jobs picked up by this executor"}),
@@ -60,6 +146,10 @@ func NewPrometheusMetricsCollector(r prometheus.Registerer) MetricsCollector {
 		c.successDuration,
 		c.responseSize,
 		c.firstByteDuration,
+		c.dnsDuration,
+		c.connectDuration,
+		c.tlsHandshakeDuration,
+		c.transferDuration,
 		c.successCounter,
 		c.failedCounter,
 		c.jobsPickedUp,
@@ -107,27 +197,74 @@ func sizeBuckets() []float64 {
 }
 
 type PrometheusMetricsCollector struct {
-	duration          prometheus.Histogram
-	successDuration   prometheus.Histogram
-	responseSize      prometheus.Histogram
-	firstByteDuration prometheus.Histogram
-	successCounter    prometheus.Counter
-	failedCounter     prometheus.Counter
+	names             *nameLimiter
+	duration          *prometheus.HistogramVec
+	successDuration   *prometheus.HistogramVec
+	responseSize      *prometheus.HistogramVec
+	firstByteDuration *prometheus.HistogramVec
+	successCounter    *prometheus.CounterVec
+	failedCounter     *prometheus.CounterVec
 	jobsPickedUp      prometheus.Counter
 	jobRequestCount   prometheus.Counter
+
+	dnsDuration          *prometheus.HistogramVec
+	connectDuration      *prometheus.HistogramVec
+	tlsHandshakeDuration *prometheus.HistogramVec
+	transferDuration     *prometheus.HistogramVec
 }
 
 func (b *PrometheusMetricsCollector) PostSuccess(event SuccessEvent) {
-	b.duration.Observe(float64(event.Duration.Milliseconds()))
-	b.successDuration.Observe(float64(event.Duration.Milliseconds()))
-	b.responseSize.Observe(float64(event.ResponseSize))
-	b.firstByteDuration.Observe(float64(event.FirstByteTime.Milliseconds()))
-	b.successCounter.Inc()
+	name := b.names.label(event.Name)
+	b.duration.WithLabelValues(name).Observe(float64(event.Duration.Milliseconds()))
+	b.successDuration.WithLabelValues(name).Observe(float64(event.Duration.Milliseconds()))
+	b.responseSize.WithLabelValues(name).Observe(float64(event.ResponseSize))
+	b.successCounter.WithLabelValues(name).Inc()
+	observePhase(b.dnsDuration, name, event.Timings.DNS)
+	observePhase(b.connectDuration, name, event.Timings.Connect)
+	observePhase(b.tlsHandshakeDuration, name, event.Timings.TLSHandshake)
+	observePhase(b.firstByteDuration, name, event.Timings.FirstByte)
+	observePhase(b.transferDuration, name, event.Timings.Transfer)
+}
+
+// observePhase records a request phase in milliseconds, skipping phases that did not happen.
+func observePhase(histogram *prometheus.HistogramVec, name string, duration time.Duration) {
+	if duration > 0 {
+		histogram.WithLabelValues(name).Observe(float64(duration) / float64(time.Millisecond))
+	}
 }
 
 func (b *PrometheusMetricsCollector) PostFailure(event ErrorEvent) {
-	b.duration.Observe(float64(event.Duration.Milliseconds()))
-	b.failedCounter.Inc()
+	name := b.names.label(event.Name)
+	b.duration.WithLabelValues(name).Observe(float64(event.Duration.Milliseconds()))
+	category := event.Category
+	if category == "" {
+		category = ErrorCategoryOther
+	}
+	b.failedCounter.WithLabelValues(name, category, StatusClass(event.Status)).Inc()
+}
+
+// nameLimiter admits the first limit distinct request names as label values and folds the rest into
+// OverflowRequestName.
+type nameLimiter struct {
+	lock  sync.Mutex
+	limit int
+	seen  map[string]struct{}
+}
+
+func (n *nameLimiter) label(name string) string {
+	if name == "" {
+		return ""
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if _, ok := n.seen[name]; ok {
+		return name
+	}
+	if len(n.seen) >= n.limit {
+		return OverflowRequestName
+	}
+	n.seen[name] = struct{}{}
+	return name
 }
 
 func (b *PrometheusMetricsCollector) RecordJobPickedUp(requestCount int) {
@@ -14,10 +14,10 @@ func TestExecutorMetricsCollectorPublishesJobAndRequestMetrics(t *testing.T) {
 
 	collector.RecordJobPickedUp(5)
 	collector.PostSuccess(SuccessEvent{
-		Status:        200,
-		ResponseSize:  123,
-		Duration:      10 * time.Millisecond,
-		FirstByteTime: 3 * time.Millisecond,
+		Status:       200,
+		ResponseSize: 123,
+		Duration:     10 * time.Millisecond,
+		Timings:      RequestTimings{Connect: time.Millisecond, FirstByte: 3 * time.Millisecond},
 	})
 
 	families, err := registry.Gather()
@@ -27,6 +27,96 @@ func TestExecutorMetricsCollectorPublishesJobAndRequestMetrics(t *testing.T) {
 	assertMetricValue(t, families, "imager_executor_jobs_picked_up_total", 1)
 	assertMetricValue(t, families, "imager_executor_job_requests_total", 5)
 	assertMetricValue(t, families, "imager_success", 1)
+	assertHistogramCount(t, families, "imager_connectDuration", 1)
+	assertHistogramCount(t, families, "imager_firstByteDuration", 1)
+	assertHistogramCount(t, families, "imager_dnsDuration", 0)
+}
+
+func TestExecutorMetricsCollectorLabelsRequestNames(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	collector := NewPrometheusMetricsCollectorWithNameLimit(registry, 2)
+
+	for _, name := range []string{"read", "write", "delete", "read", ""} {
+		collector.PostSuccess(SuccessEvent{Status: 200, Duration: time.Millisecond, Name: name})
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("unable to gather metrics: %v", err)
+	}
+	counts := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "imager_success" {
+			continue
+		}
+		for _, metric := range family.Metric {
+			for _, label := range metric.Label {
+				if label.GetName() == "name" {
+					counts[label.GetValue()] = metric.Counter.GetValue()
+				}
+			}
+		}
+	}
+	expected := map[string]float64{"": 1, "read": 2, "write": 1, OverflowRequestName: 1}
+	if len(counts) != len(expected) {
+		t.Fatalf("expected name labels %v, got %v", expected, counts)
+	}
+	for name, count := range expected {
+		if counts[name] != count {
+			t.Fatalf("expected %.0f successes for name %q, got %.0f", count, name, counts[name])
+		}
+	}
+}
+
+func TestExecutorMetricsCollectorCountsFailureCategories(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	collector := NewPrometheusMetricsCollector(registry)
+
+	collector.PostFailure(ErrorEvent{Status: 503, ErrMsg: "unavailable", Category: ErrorCategoryServerError})
+	collector.PostFailure(ErrorEvent{Status: 502, ErrMsg: "bad gateway", Category: ErrorCategoryServerError})
+	collector.PostFailure(ErrorEvent{Status: 200, ErrMsg: "missing field", Category: ErrorCategoryAssertion})
+	collector.PostFailure(ErrorEvent{ErrMsg: "connection refused", Category: ErrorCategoryConnectionRefused})
+	collector.PostFailure(ErrorEvent{ErrMsg: "unclassified"})
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("unable to gather metrics: %v", err)
+	}
+	counts := make(map[string]float64)
+	for _, family := range families {
+		if family.GetName() != "imager_failed" {
+			continue
+		}
+		for _, metric := range family.Metric {
+			labels := make(map[string]string)
+			for _, label := range metric.Label {
+				labels[label.GetName()] = label.GetValue()
+			}
+			counts[labels["category"]+"/"+labels["status_class"]] = metric.Counter.GetValue()
+		}
+	}
+	expected := map[string]float64{
+		"server_error/5xx":        2,
+		"assertion/2xx":           1,
+		"connection_refused/none": 1,
+		"other/none":              1,
+	}
+	if len(counts) != len(expected) {
+		t.Fatalf("expected failure labels %v, got %v", expected, counts)
+	}
+	for labels, count := range expected {
+		if counts[labels] != count {
+			t.Fatalf("expected %.0f failures for %s, got %.0f", count, labels, counts[labels])
+		}
+	}
+}
+
+func TestStatusClass(t *testing.T) {
+	for status, expected := range map[int]string{0: "none", 204: "2xx", 302: "3xx", 404: "4xx", 599: "5xx", 600: "none"} {
+		if got := StatusClass(status); got != expected {
+			t.Fatalf("expected status class %q for %d, got %q", expected, status, got)
+		}
+	}
 }
 
 func TestOrchestratorMetricsPublishesRegistryAndPodUsage(t *testing.T) {
@@ -71,3 +161,19 @@ func assertMetricValue(t *testing.T, families []*dto.MetricFamily, name string,
 	}
 	t.Fatalf("metric %s not found", name)
 }
+
+func assertHistogramCount(t *testing.T, families []*dto.MetricFamily, name string, expected uint64) {
+	t.Helper()
+	var got uint64
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.Metric {
+			got += metric.GetHistogram().GetSampleCount()
+		}
+	}
+	if got != expected {
+		t.Fatalf("histogram %s expected %d samples, got %d", name, expected, got)
+	}
+}
@@ -19,19 +19,24 @@ func (h *HttpError) Error() string {
 	return h.err.Error()
 }
 
-func NewHandler(ctx context.Context) http.Handler {
+// NewHandler serves the executor protocol and the run control API. POST /runs answers 501 when creator is nil;
+// existing runs can still be inspected, paused, resumed and stopped.
+func NewHandler(ctx context.Context, creator RunCreator) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/connect", connectHandler)
 	mux.HandleFunc("/heartbeat", heartbeatHandler)
 	mux.HandleFunc("/next", nextHandler(ctx))
 	mux.HandleFunc("/report", reportHandler)
+	mux.HandleFunc("/runs", createRunHandler(ctx, creator))
+	mux.HandleFunc("/runs/{id}", getRunHandler)
+	mux.HandleFunc("/runs/{id}/{action}", runActionHandler)
 	return mux
 }
 
-func Init(p int, c context.Context) error {
+func Init(p int, c context.Context, creator RunCreator) error {
 	server := &http.Server{
 		Addr:    fmt.Sprintf(":%d", p),
-		Handler: NewHandler(c),
+		Handler: NewHandler(c, creator),
 	}
 	return server.ListenAndServe()
 }
@@ -85,6 +90,14 @@ func nextHandler(ctx context.Context) func(w http.ResponseWriter, r *http.Reques
 			return
 		}
 		logger.Logger.Info("Fetching next job for executor", executor.Id)
+		// Executors are released once when the run they joined ends. A nil channel never fires, so executors that
+		// connect between runs simply wait for the next run's work.
+		var runDone <-chan struct{}
+		if run := manager.CurrentRun(); run != nil {
+			if endedAt := run.Status().EndedAt; endedAt == nil || executor.ConnectedAt.Before(*endedAt) {
+				runDone = run.Done()
+			}
+		}
 		select {
 		case jobs := <-executor.WorkChan:
 			logger.Logger.Info("Found jobs for executor", executor.Id, jobs)
@@ -93,6 +106,9 @@ func nextHandler(ctx context.Context) func(w http.ResponseWriter, r *http.Reques
 			if err := encoder.Encode(jobs); err != nil {
 				logger.Logger.Error("Unable to encode jobs response", err)
 			}
+		case <-runDone:
+			logger.Logger.Info("Run complete- releasing executor", executor.Id)
+			w.WriteHeader(http.StatusNoContent)
 		case <-ctx.Done():
 			logger.Logger.Warn("Context canceled- abandoning request", ctx.Err())
 			w.WriteHeader(http.StatusServiceUnavailable)
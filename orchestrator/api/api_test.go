@@ -20,7 +20,7 @@ func TestConnectHeartbeatAndNext(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	handler := NewHandler(ctx)
+	handler := NewHandler(ctx, nil)
 	worker := types.WorkerId{Id: "worker-1", Workers: 2}
 
 	connectReq := httptest.NewRequest(http.MethodPost, "/connect", marshalBody(t, worker))
@@ -66,11 +66,33 @@ func TestConnectHeartbeatAndNext(t *testing.T) {
 	}
 }
 
+func TestNextReturnsNoContentWhenRunCompletes(t *testing.T) {
+	manager.ResetExecutors()
+	manager.ResetRuns()
+	t.Cleanup(manager.ResetExecutors)
+	t.Cleanup(manager.ResetRuns)
+
+	handler := NewHandler(context.Background(), nil)
+	worker := types.WorkerId{Id: "worker-1", Workers: 1}
+	manager.AddExecutor(worker.Id, worker.Workers)
+
+	run := manager.NewRun("run-1", manager.RunLimits{})
+	manager.RegisterRun(run)
+	run.Complete("done")
+
+	nextReq := httptest.NewRequest(http.MethodPost, "/next", marshalBody(t, worker))
+	nextResp := httptest.NewRecorder()
+	handler.ServeHTTP(nextResp, nextReq)
+	if nextResp.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, nextResp.Code)
+	}
+}
+
 func TestReportEndpointAcceptsJobReports(t *testing.T) {
 	manager.ResetRoundReports()
 	t.Cleanup(manager.ResetRoundReports)
 
-	handler := NewHandler(context.Background())
+	handler := NewHandler(context.Background(), nil)
 	manager.RegisterRound("round-1", 10, 1, 2)
 	report := types.JobReport{
 		JobID:             "job-1",
@@ -78,7 +100,6 @@ func TestReportEndpointAcceptsJobReports(t *testing.T) {
 		PlannedRequests:   2,
 		CompletedRequests: 2,
 		SuccessCount:      2,
-		LatencyMillis:     []int64{10, 20},
 	}
 	req := httptest.NewRequest(http.MethodPost, "/report", marshalBody(t, report))
 	resp := httptest.NewRecorder()
@@ -99,7 +120,7 @@ func TestReportEndpointAcceptsJobReports(t *testing.T) {
 }
 
 func TestMethodNotAllowed(t *testing.T) {
-	handler := NewHandler(context.Background())
+	handler := NewHandler(context.Background(), nil)
 	req := httptest.NewRequest(http.MethodGet, "/connect", nil)
 	resp := httptest.NewRecorder()
 	handler.ServeHTTP(resp, req)
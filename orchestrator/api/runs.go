@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/PeladoCollado/imager/orchestrator/logger"
+	"github.com/PeladoCollado/imager/orchestrator/manager"
+	"net/http"
+)
+
+// RunRequest is the body of POST /runs. Every field mirrors the orchestrator flag named in its comment and
+// fields that are not set keep the values the orchestrator was started with. Files on the orchestrator, such as
+// the request source file or the plan, and the settings of the orchestrator process are only chosen at startup.
+// Durations are Go durations such as "10m".
+type RunRequest struct {
+	Target        *RunTarget        `json:"target,omitempty"`
+	RequestSource *RunRequestSource `json:"requestSource,omitempty"`
+	Load          *RunLoad          `json:"load,omitempty"`
+	Arrival       *RunArrival       `json:"arrival,omitempty"`
+	Schedule      *RunSchedule      `json:"schedule,omitempty"`
+	Limits        *RunLimits        `json:"limits,omitempty"`
+	Abort         *RunAbort         `json:"abort,omitempty"`
+}
+
+// RunTarget selects the target of a run: the target-* flags.
+type RunTarget struct {
+	Mode       *string `json:"mode,omitempty"`
+	Namespace  *string `json:"namespace,omitempty"`
+	Deployment *string `json:"deployment,omitempty"`
+	Service    *string `json:"service,omitempty"`
+	URL        *string `json:"url,omitempty"`
+	PortName   *string `json:"portName,omitempty"`
+	Scheme     *string `json:"scheme,omitempty"`
+}
+
+// RunRequestSource selects the request source of a run and its options: the request-source-*, har-*,
+// access-log-format, openapi-* and random-sum-* flags.
+type RunRequestSource struct {
+	Type              *string `json:"type,omitempty"`
+	EOF               *string `json:"eof,omitempty"`
+	Shuffle           *bool   `json:"shuffle,omitempty"`
+	Seed              *int64  `json:"seed,omitempty"`
+	Skip              *int    `json:"skip,omitempty"`
+	HARHosts          *string `json:"harHosts,omitempty"`
+	HARURLPattern     *string `json:"harUrlPattern,omitempty"`
+	HARStripHeaders   *string `json:"harStripHeaders,omitempty"`
+	HARPreserveTiming *bool   `json:"harPreserveTiming,omitempty"`
+	AccessLogFormat   *string `json:"accessLogFormat,omitempty"`
+	OpenAPIInclude    *string `json:"openapiInclude,omitempty"`
+	OpenAPIExclude    *string `json:"openapiExclude,omitempty"`
+	OpenAPIWeights    *string `json:"openapiWeights,omitempty"`
+	RandomSumPath     *string `json:"randomSumPath,omitempty"`
+	RandomSumMin      *int    `json:"randomSumMin,omitempty"`
+	RandomSumMax      *int    `json:"randomSumMax,omitempty"`
+}
+
+// RunLoad selects the load calculator of a run, its profile and the load model: the load-calculator, rps,
+// min-rps, max-rps, step-rps, adaptive-*, replay-speedup, load-model and think-time* flags.
+type RunLoad struct {
+	Calculator            *string  `json:"calculator,omitempty"`
+	RPS                   *int     `json:"rps,omitempty"`
+	MinRPS                *int     `json:"minRps,omitempty"`
+	MaxRPS                *int     `json:"maxRps,omitempty"`
+	StepRPS               *int     `json:"stepRps,omitempty"`
+	AdaptiveMaxLatencyMs  *int64   `json:"adaptiveMaxLatencyMs,omitempty"`
+	AdaptiveLatencyMetric *string  `json:"adaptiveLatencyMetric,omitempty"`
+	ReplaySpeedup         *float64 `json:"replaySpeedup,omitempty"`
+	Model                 *string  `json:"model,omitempty"`
+	ThinkTime             *string  `json:"thinkTime,omitempty"`
+	ThinkTimeMin          *string  `json:"thinkTimeMin,omitempty"`
+	ThinkTimeMax          *string  `json:"thinkTimeMax,omitempty"`
+	ThinkTimeDistribution *string  `json:"thinkTimeDistribution,omitempty"`
+}
+
+// RunArrival shapes open-model send times: the arrival-* flags.
+type RunArrival struct {
+	Process   *string `json:"process,omitempty"`
+	BurstSize *int    `json:"burstSize,omitempty"`
+	Seed      *int64  `json:"seed,omitempty"`
+}
+
+// RunSchedule sets the durations of a run's rounds and requests: the schedule-interval, job-duration and
+// request-timeout flags.
+type RunSchedule struct {
+	Interval       *string `json:"interval,omitempty"`
+	JobDuration    *string `json:"jobDuration,omitempty"`
+	RequestTimeout *string `json:"requestTimeout,omitempty"`
+}
+
+// RunLimits bounds a run: the run-duration and run-max-requests flags.
+type RunLimits struct {
+	Duration    *string `json:"duration,omitempty"`
+	MaxRequests *int    `json:"maxRequests,omitempty"`
+}
+
+// RunAbort sets the conditions that abort a run: the abort-* flags.
+type RunAbort struct {
+	ErrorRatio        *float64 `json:"errorRatio,omitempty"`
+	P99LatencyMs      *int64   `json:"p99LatencyMs,omitempty"`
+	LatencyMetric     *string  `json:"latencyMetric,omitempty"`
+	ConsecutiveRounds *int     `json:"consecutiveRounds,omitempty"`
+	TargetRestarts    *int     `json:"targetRestarts,omitempty"`
+}
+
+// RunCreator builds and starts runs on behalf of POST /runs. Implementations validate the request with the same
+// rules as the orchestrator flags and return manager.ErrRunActive if another run has not yet ended.
+type RunCreator interface {
+	CreateRun(ctx context.Context, request RunRequest) (*manager.Run, error)
+}
+
+func createRunHandler(ctx context.Context, creator RunCreator) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if creator == nil {
+			w.WriteHeader(http.StatusNotImplemented)
+			_, _ = fmt.Fprint(w, "run creation is not enabled")
+			return
+		}
+		request, httpError := parseRunRequest(r)
+		if httpError != nil {
+			w.WriteHeader(httpError.code)
+			_, _ = fmt.Fprint(w, httpError.Error())
+			return
+		}
+		run, err := creator.CreateRun(ctx, *request)
+		if err != nil {
+			logger.Logger.Warn("Unable to create run", err)
+			if errors.Is(err, manager.ErrRunActive) {
+				w.WriteHeader(http.StatusConflict)
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			_, _ = fmt.Fprint(w, err.Error())
+			return
+		}
+		writeRunStatus(w, http.StatusCreated, run)
+	}
+}
+
+func getRunHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	run, httpError := findRun(r)
+	if httpError != nil {
+		w.WriteHeader(httpError.code)
+		_, _ = fmt.Fprint(w, httpError.Error())
+		return
+	}
+	writeRunStatus(w, http.StatusOK, run)
+}
+
+func runActionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	run, httpError := findRun(r)
+	if httpError != nil {
+		w.WriteHeader(httpError.code)
+		_, _ = fmt.Fprint(w, httpError.Error())
+		return
+	}
+
+	var err error
+	switch action := r.PathValue("action"); action {
+	case "pause":
+		err = run.Pause()
+	case "resume":
+		err = run.Resume()
+	case "stop":
+		if run.State().Terminal() {
+			err = fmt.Errorf("run %s has already ended", run.ID())
+		} else {
+			run.Abort("stopped via control API")
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = fmt.Fprintf(w, "unknown run action %s", action)
+		return
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusConflict)
+		_, _ = fmt.Fprint(w, err.Error())
+		return
+	}
+	writeRunStatus(w, http.StatusOK, run)
+}
+
+func findRun(r *http.Request) (*manager.Run, *HttpError) {
+	id := r.PathValue("id")
+	run := manager.GetRun(id)
+	if run == nil {
+		return nil, &HttpError{
+			code: http.StatusNotFound,
+			err:  fmt.Errorf("unable to find run by id %s", id),
+		}
+	}
+	return run, nil
+}
+
+func parseRunRequest(r *http.Request) (*RunRequest, *HttpError) {
+	defer r.Body.Close()
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	request := &RunRequest{}
+	if err := decoder.Decode(request); err != nil {
+		logger.Logger.Error("Unable to parse run request", err)
+		return nil, &HttpError{code: http.StatusBadRequest, err: err}
+	}
+	return request, nil
+}
+
+func writeRunStatus(w http.ResponseWriter, code int, run *manager.Run) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(run.Status()); err != nil {
+		logger.Logger.Error("Unable to encode run status", err)
+	}
+}
@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/PeladoCollado/imager/orchestrator/manager"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeRunCreator struct {
+	requests []RunRequest
+	err      error
+}
+
+func (f *fakeRunCreator) CreateRun(_ context.Context, request RunRequest) (*manager.Run, error) {
+	f.requests = append(f.requests, request)
+	if f.err != nil {
+		return nil, f.err
+	}
+	run := manager.NewRun("run-created", manager.RunLimits{})
+	manager.RegisterRun(run)
+	if err := run.Start(); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func TestCreateRunPassesSettingsToCreator(t *testing.T) {
+	manager.ResetRuns()
+	t.Cleanup(manager.ResetRuns)
+
+	creator := &fakeRunCreator{}
+	handler := NewHandler(context.Background(), creator)
+	body := `{"load":{"calculator":"step","maxRps":200},"limits":{"duration":"10m"}}`
+	req := httptest.NewRequest(http.MethodPost, "/runs", strings.NewReader(body))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, resp.Code, resp.Body.String())
+	}
+	if len(creator.requests) != 1 {
+		t.Fatalf("expected one creator request, got %d", len(creator.requests))
+	}
+	request := creator.requests[0]
+	if request.Load == nil || *request.Load.Calculator != "step" || *request.Load.MaxRPS != 200 ||
+		request.Limits == nil || *request.Limits.Duration != "10m" || request.Target != nil {
+		t.Fatalf("unexpected creator request: %+v", request)
+	}
+	status := decodeRunStatus(t, resp)
+	if status.ID != "run-created" || status.State != manager.RunStateRunning {
+		t.Fatalf("unexpected run status: %+v", status)
+	}
+}
+
+func TestCreateRunRejectsFieldsOutsideTheSchema(t *testing.T) {
+	for _, body := range []string{
+		`{"settings":{"plan-file":"/etc/passwd"}}`,
+		`{"requestSource":{"file":"/etc/passwd"}}`,
+		`{"load":{"maxRps":"lots"}}`,
+	} {
+		creator := &fakeRunCreator{}
+		handler := NewHandler(context.Background(), creator)
+		req := httptest.NewRequest(http.MethodPost, "/runs", strings.NewReader(body))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if resp.Code != http.StatusBadRequest || len(creator.requests) != 0 {
+			t.Fatalf("expected %s to be rejected, got %d", body, resp.Code)
+		}
+	}
+}
+
+func TestCreateRunMapsCreatorErrors(t *testing.T) {
+	tests := []struct {
+		err  error
+		code int
+	}{
+		{err: fmt.Errorf("%w: run-1", manager.ErrRunActive), code: http.StatusConflict},
+		{err: fmt.Errorf("max-rps must be >= min-rps"), code: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		handler := NewHandler(context.Background(), &fakeRunCreator{err: test.err})
+		req := httptest.NewRequest(http.MethodPost, "/runs", marshalBody(t, RunRequest{}))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if resp.Code != test.code {
+			t.Fatalf("expected status %d for %v, got %d", test.code, test.err, resp.Code)
+		}
+	}
+}
+
+func TestCreateRunWithoutCreatorIsNotImplemented(t *testing.T) {
+	handler := NewHandler(context.Background(), nil)
+	req := httptest.NewRequest(http.MethodPost, "/runs", marshalBody(t, RunRequest{}))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNotImplemented {
+		t.Fatalf("expected status %d, got %d", http.StatusNotImplemented, resp.Code)
+	}
+}
+
+func TestRunActionsDriveLifecycle(t *testing.T) {
+	manager.ResetRuns()
+	t.Cleanup(manager.ResetRuns)
+
+	run := manager.NewRun("run-1", manager.RunLimits{})
+	manager.RegisterRun(run)
+	if err := run.Start(); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+	handler := NewHandler(context.Background(), nil)
+
+	steps := []struct {
+		method string
+		path   string
+		code   int
+		state  manager.RunState
+	}{
+		{method: http.MethodPost, path: "/runs/run-1/pause", code: http.StatusOK, state: manager.RunStatePaused},
+		{method: http.MethodPost, path: "/runs/run-1/pause", code: http.StatusConflict, state: manager.RunStatePaused},
+		{method: http.MethodPost, path: "/runs/run-1/resume", code: http.StatusOK, state: manager.RunStateRunning},
+		{method: http.MethodGet, path: "/runs/run-1", code: http.StatusOK, state: manager.RunStateRunning},
+		{method: http.MethodPost, path: "/runs/run-1/stop", code: http.StatusOK, state: manager.RunStateAborted},
+		{method: http.MethodPost, path: "/runs/run-1/stop", code: http.StatusConflict, state: manager.RunStateAborted},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, nil)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if resp.Code != step.code {
+			t.Fatalf("%s %s: expected status %d, got %d", step.method, step.path, step.code, resp.Code)
+		}
+		if run.State() != step.state {
+			t.Fatalf("%s %s: expected state %s, got %s", step.method, step.path, step.state, run.State())
+		}
+	}
+}
+
+func TestRunEndpointsRejectUnknownRunsAndActions(t *testing.T) {
+	manager.ResetRuns()
+	t.Cleanup(manager.ResetRuns)
+	manager.RegisterRun(manager.NewRun("run-1", manager.RunLimits{}))
+	handler := NewHandler(context.Background(), nil)
+
+	for _, path := range []string{"/runs/missing", "/runs/run-1/rewind"} {
+		method := http.MethodPost
+		if path == "/runs/missing" {
+			method = http.MethodGet
+		}
+		req := httptest.NewRequest(method, path, nil)
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		if resp.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected status %d, got %d", path, http.StatusNotFound, resp.Code)
+		}
+	}
+}
+
+func decodeRunStatus(t *testing.T, resp *httptest.ResponseRecorder) manager.RunStatus {
+	t.Helper()
+	var status manager.RunStatus
+	if err := json.Unmarshal(resp.Body.Bytes(), &status); err != nil {
+		t.Fatalf("unable to decode run status: %v", err)
+	}
+	return status
+}
@@ -7,6 +7,9 @@ import (
 	"time"
 
 	"github.com/PeladoCollado/imager/orchestrator/k8s"
+	"github.com/PeladoCollado/imager/orchestrator/manager"
+	"github.com/PeladoCollado/imager/orchestrator/requests"
+	"github.com/PeladoCollado/imager/types"
 )
 
 type Config struct {
@@ -20,21 +23,64 @@ type Config struct {
 	TargetPortName   string
 	TargetScheme     string
 
-	RequestSourceType string
-	RequestSourceFile string
-	RandomSumPath     string
-	RandomSumMin      int
-	RandomSumMax      int
+	RequestSourceType    string
+	RequestSourceFile    string
+	RequestSourceEOF     string
+	RequestSourceShuffle bool
+	RequestSourceSeed    int64
+	RequestSourceSkip    int
+	RequestMixFile       string
+	ScenarioFile         string
+	TemplateFile         string
+	HARFile              string
+	HARHosts             string
+	HARURLPattern        string
+	HARStripHeaders      string
+	HARPreserveTiming    bool
+	AccessLogFile        string
+	AccessLogFormat      string
+	OpenAPIFile          string
+	OpenAPIInclude       string
+	OpenAPIExclude       string
+	OpenAPIWeights       string
+	RandomSumPath        string
+	RandomSumMin         int
+	RandomSumMax         int
 
 	LoadCalculator           string
+	RPS                      int
 	MinRPS                   int
 	MaxRPS                   int
 	StepRPS                  int
 	AdaptiveMaxLatencyMillis int64
+	AdaptiveLatencyMetric    string
+	ReplaySpeedup            float64
+	PlanFile                 string
+
+	LoadModel             string
+	ThinkTime             time.Duration
+	ThinkTimeMin          time.Duration
+	ThinkTimeMax          time.Duration
+	ThinkTimeDistribution string
+
+	ArrivalProcess   string
+	ArrivalBurstSize int
+	ArrivalSeed      int64
 
 	ScheduleInterval    time.Duration
 	JobDuration         time.Duration
 	MetricsPollInterval time.Duration
+	RequestTimeout      time.Duration
+
+	RunDuration    time.Duration
+	RunMaxRequests int
+	StartRun       bool
+
+	AbortErrorRatio        float64
+	AbortP99LatencyMillis  int64
+	AbortLatencyMetric     string
+	AbortConsecutiveRounds int
+	AbortTargetRestarts    int
 
 	InCluster  bool
 	Kubeconfig string
@@ -51,20 +97,37 @@ func DefaultConfig() Config {
 
 		RequestSourceType: "file",
 		RequestSourceFile: "/config/requests.json",
+		RequestSourceEOF:  "loop",
+		HARStripHeaders:   "Cookie,Content-Length",
+		AccessLogFormat:   requests.AccessLogCombined,
 		RandomSumPath:     "/sum",
 		RandomSumMin:      1,
 		RandomSumMax:      100,
 
 		LoadCalculator:           "step",
+		RPS:                      10,
 		MinRPS:                   1,
 		MaxRPS:                   100,
 		StepRPS:                  1,
 		AdaptiveMaxLatencyMillis: 0,
+		AdaptiveLatencyMetric:    string(manager.LatencyMetricService),
+		ReplaySpeedup:            1,
+
+		LoadModel:             types.LoadModelOpen,
+		ThinkTime:             time.Second,
+		ThinkTimeDistribution: types.ThinkTimeConstant,
+
+		ArrivalProcess:   types.ArrivalUniform,
+		ArrivalBurstSize: 10,
 
 		ScheduleInterval:    time.Second,
 		JobDuration:         time.Second,
 		MetricsPollInterval: 5 * time.Second,
 
+		StartRun:               true,
+		AbortLatencyMetric:     string(manager.LatencyMetricService),
+		AbortConsecutiveRounds: 3,
+
 		InCluster: true,
 	}
 }
@@ -80,22 +143,98 @@ func BindFlags(fs *flag.FlagSet, cfg *Config) {
 	fs.StringVar(&cfg.TargetPortName, "target-port-name", cfg.TargetPortName, "Target container port name")
 	fs.StringVar(&cfg.TargetScheme, "target-scheme", cfg.TargetScheme, "Target request URL scheme")
 
-	fs.StringVar(&cfg.RequestSourceType, "request-source-type", cfg.RequestSourceType, "Request source type: file or random-sum")
-	fs.StringVar(&cfg.RequestSourceFile, "request-source-file", cfg.RequestSourceFile, "Path to request source JSON file")
+	fs.StringVar(&cfg.RequestSourceType, "request-source-type", cfg.RequestSourceType, "Request source type: file, random-sum, template, scenario, har, access-log, openapi, or mix")
+	fs.StringVar(&cfg.RequestSourceFile, "request-source-file", cfg.RequestSourceFile,
+		"Path to a request source JSON file, optionally gzip or zstd compressed, or a directory of them")
+	fs.StringVar(&cfg.RequestSourceEOF, "request-source-eof", cfg.RequestSourceEOF,
+		"What the file source does at the end of the file: loop, or stop (which completes the run)")
+	fs.BoolVar(&cfg.RequestSourceShuffle, "request-source-shuffle", cfg.RequestSourceShuffle,
+		"Replay the file source's records in a random order, reshuffled on every pass")
+	fs.Int64Var(&cfg.RequestSourceSeed, "request-source-seed", cfg.RequestSourceSeed,
+		"Seed for request-source-shuffle (0 seeds from the clock)")
+	fs.IntVar(&cfg.RequestSourceSkip, "request-source-skip", cfg.RequestSourceSkip,
+		"Leave out the first N records of the file source")
+	fs.StringVar(&cfg.RequestMixFile, "request-mix-file", cfg.RequestMixFile,
+		"Path to a YAML file of weighted request sources (request-source-type=mix)")
+	fs.StringVar(&cfg.ScenarioFile, "scenario-file", cfg.ScenarioFile,
+		"Path to a YAML file of multi-step scenarios (request-source-type=scenario)")
+	fs.StringVar(&cfg.TemplateFile, "template-file", cfg.TemplateFile,
+		"Path to a YAML request template with data feeders (request-source-type=template)")
+	fs.StringVar(&cfg.HARFile, "har-file", cfg.HARFile, "Path to a HAR file to replay (request-source-type=har)")
+	fs.StringVar(&cfg.HARHosts, "har-hosts", cfg.HARHosts,
+		"Comma-separated hosts whose HAR entries are replayed (empty replays every host)")
+	fs.StringVar(&cfg.HARURLPattern, "har-url-pattern", cfg.HARURLPattern,
+		"Regular expression HAR entry URLs must match to be replayed")
+	fs.StringVar(&cfg.HARStripHeaders, "har-strip-headers", cfg.HARStripHeaders,
+		"Comma-separated request headers dropped from HAR entries")
+	fs.BoolVar(&cfg.HARPreserveTiming, "har-preserve-timing", cfg.HARPreserveTiming,
+		"Keep the recorded gaps between HAR entries, replayed with arrival-process=recorded")
+	fs.StringVar(&cfg.AccessLogFile, "access-log-file", cfg.AccessLogFile,
+		"Path to a web server access log to replay (request-source-type=access-log, load-calculator=replay)")
+	fs.StringVar(&cfg.AccessLogFormat, "access-log-format", cfg.AccessLogFormat, "Access log format: combined or json")
+	fs.StringVar(&cfg.OpenAPIFile, "openapi-file", cfg.OpenAPIFile,
+		"Path to an OpenAPI 3 document to generate requests from (request-source-type=openapi)")
+	fs.StringVar(&cfg.OpenAPIInclude, "openapi-include", cfg.OpenAPIInclude,
+		"Comma-separated tags and operation IDs to generate requests for (empty includes every operation)")
+	fs.StringVar(&cfg.OpenAPIExclude, "openapi-exclude", cfg.OpenAPIExclude,
+		"Comma-separated tags and operation IDs to leave out")
+	fs.StringVar(&cfg.OpenAPIWeights, "openapi-weights", cfg.OpenAPIWeights,
+		"Comma-separated operation=weight pairs, e.g. listPets=5,createPet=1 (other operations have weight 1)")
 	fs.StringVar(&cfg.RandomSumPath, "random-sum-path", cfg.RandomSumPath, "Path to call when using the random-sum request source")
 	fs.IntVar(&cfg.RandomSumMin, "random-sum-min", cfg.RandomSumMin, "Minimum random value used by random-sum request source")
 	fs.IntVar(&cfg.RandomSumMax, "random-sum-max", cfg.RandomSumMax, "Maximum random value used by random-sum request source")
 
-	fs.StringVar(&cfg.LoadCalculator, "load-calculator", cfg.LoadCalculator, "Load calculator: step, exponential, logarithmic, adaptive-exponential")
+	fs.StringVar(&cfg.LoadCalculator, "load-calculator", cfg.LoadCalculator, "Load calculator: constant, step, exponential, logarithmic, adaptive-exponential, replay")
+	fs.IntVar(&cfg.RPS, "rps", cfg.RPS, "Requests per second for the constant calculator")
 	fs.IntVar(&cfg.MinRPS, "min-rps", cfg.MinRPS, "Minimum requests per second")
 	fs.IntVar(&cfg.MaxRPS, "max-rps", cfg.MaxRPS, "Maximum requests per second")
 	fs.IntVar(&cfg.StepRPS, "step-rps", cfg.StepRPS, "Step increase for step load calculator")
 	fs.Int64Var(&cfg.AdaptiveMaxLatencyMillis, "adaptive-max-latency-ms", cfg.AdaptiveMaxLatencyMillis,
 		"Adaptive calculator p99 latency limit in milliseconds (0 switches to timeout-threshold mode)")
+	fs.StringVar(&cfg.AdaptiveLatencyMetric, "adaptive-latency-metric", cfg.AdaptiveLatencyMetric,
+		"Latency the adaptive calculator thresholds on: service (from actual send) or corrected (from scheduled start)")
+	fs.Float64Var(&cfg.ReplaySpeedup, "replay-speedup", cfg.ReplaySpeedup,
+		"How many times faster than recorded the replay calculator plays back access-log-file")
+	fs.StringVar(&cfg.PlanFile, "plan-file", cfg.PlanFile,
+		"Path to a YAML test plan of phases; replaces load-calculator for the run")
+
+	fs.StringVar(&cfg.LoadModel, "load-model", cfg.LoadModel,
+		"Load model: open (load calculator emits RPS) or closed (load calculator emits concurrent virtual users)")
+	fs.DurationVar(&cfg.ThinkTime, "think-time", cfg.ThinkTime, "Mean virtual-user think time (closed model, constant and exponential)")
+	fs.DurationVar(&cfg.ThinkTimeMin, "think-time-min", cfg.ThinkTimeMin, "Minimum virtual-user think time (closed model, uniform)")
+	fs.DurationVar(&cfg.ThinkTimeMax, "think-time-max", cfg.ThinkTimeMax, "Maximum virtual-user think time (closed model, uniform)")
+	fs.StringVar(&cfg.ThinkTimeDistribution, "think-time-distribution", cfg.ThinkTimeDistribution,
+		"Virtual-user think time distribution: constant, uniform, or exponential")
+
+	fs.StringVar(&cfg.ArrivalProcess, "arrival-process", cfg.ArrivalProcess,
+		"Open-model request arrival process: uniform, poisson, burst, or recorded (replays recorded request delays)")
+	fs.IntVar(&cfg.ArrivalBurstSize, "arrival-burst-size", cfg.ArrivalBurstSize,
+		"Requests sent back-to-back per burst when arrival-process=burst")
+	fs.Int64Var(&cfg.ArrivalSeed, "arrival-seed", cfg.ArrivalSeed,
+		"Seed for arrival and think-time randomness (0 seeds from the clock)")
 
 	fs.DurationVar(&cfg.ScheduleInterval, "schedule-interval", cfg.ScheduleInterval, "How often to dispatch jobs")
 	fs.DurationVar(&cfg.JobDuration, "job-duration", cfg.JobDuration, "Duration of each dispatched job")
 	fs.DurationVar(&cfg.MetricsPollInterval, "metrics-poll-interval", cfg.MetricsPollInterval, "How often to poll target pod metrics")
+	fs.DurationVar(&cfg.RequestTimeout, "request-timeout", cfg.RequestTimeout,
+		"Per-request timeout shipped with each job, overriding the executors' default (0 keeps their default)")
+
+	fs.DurationVar(&cfg.RunDuration, "run-duration", cfg.RunDuration, "End the run after this much active time (0 runs until stopped)")
+	fs.IntVar(&cfg.RunMaxRequests, "run-max-requests", cfg.RunMaxRequests,
+		"End the run after dispatching this many requests (0 is unlimited)")
+	fs.BoolVar(&cfg.StartRun, "start-run", cfg.StartRun,
+		"Start a run from these flags at startup (false waits for POST /runs)")
+
+	fs.Float64Var(&cfg.AbortErrorRatio, "abort-error-ratio", cfg.AbortErrorRatio,
+		"Abort the run when a round's failed share (timeouts included) exceeds this ratio (0 disables)")
+	fs.Int64Var(&cfg.AbortP99LatencyMillis, "abort-p99-latency-ms", cfg.AbortP99LatencyMillis,
+		"Abort the run when a round's p99 latency exceeds this many milliseconds (0 disables)")
+	fs.StringVar(&cfg.AbortLatencyMetric, "abort-latency-metric", cfg.AbortLatencyMetric,
+		"Latency the p99 abort condition uses: service or corrected")
+	fs.IntVar(&cfg.AbortConsecutiveRounds, "abort-consecutive-rounds", cfg.AbortConsecutiveRounds,
+		"Consecutive breaching rounds required before the error ratio or latency condition aborts the run")
+	fs.IntVar(&cfg.AbortTargetRestarts, "abort-target-restarts", cfg.AbortTargetRestarts,
+		"Abort the run once target containers restart this many times (0 disables)")
 
 	fs.BoolVar(&cfg.InCluster, "in-cluster", cfg.InCluster, "Use in-cluster Kubernetes config")
 	fs.StringVar(&cfg.Kubeconfig, "kubeconfig", cfg.Kubeconfig, "Kubeconfig path for out-of-cluster mode")
@@ -111,6 +250,57 @@ func ParseConfig(args []string) (Config, error) {
 	return cfg, nil
 }
 
+// ThinkTimeSpec converts the think-time flags into the form shipped to executors.
+func (c Config) ThinkTimeSpec() types.ThinkTime {
+	return types.ThinkTime{
+		Distribution: c.ThinkTimeDistribution,
+		MeanMillis:   c.ThinkTime.Milliseconds(),
+		MinMillis:    c.ThinkTimeMin.Milliseconds(),
+		MaxMillis:    c.ThinkTimeMax.Milliseconds(),
+	}
+}
+
+// ArrivalSpec converts the arrival flags into the form shipped to executors.
+func (c Config) ArrivalSpec() types.ArrivalProcess {
+	return types.ArrivalProcess{
+		Type:      c.ArrivalProcess,
+		BurstSize: c.ArrivalBurstSize,
+		Seed:      c.ArrivalSeed,
+	}
+}
+
+// WithOverrides returns a copy of the config with the named flags set to new values, as if they had been passed on
+// the command line. Callers restrict which flags may be set; the result is not validated.
+func (c Config) WithOverrides(settings map[string]string) (Config, error) {
+	cfg := c
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	BindFlags(fs, &cfg)
+	for name, value := range settings {
+		if fs.Lookup(name) == nil {
+			return Config{}, fmt.Errorf("unknown setting %s", name)
+		}
+		if err := fs.Set(name, value); err != nil {
+			return Config{}, fmt.Errorf("invalid value %q for %s: %w", value, name, err)
+		}
+	}
+	return cfg, nil
+}
+
+// RunLimits converts the run flags into the limits of a manager.Run.
+func (c Config) RunLimits() manager.RunLimits {
+	return manager.RunLimits{
+		MaxDuration: c.RunDuration,
+		MaxRequests: c.RunMaxRequests,
+		Abort: manager.AbortConditions{
+			MaxErrorRatio:       c.AbortErrorRatio,
+			MaxP99LatencyMillis: c.AbortP99LatencyMillis,
+			LatencyMetric:       manager.LatencyMetric(c.AbortLatencyMetric),
+			ConsecutiveRounds:   c.AbortConsecutiveRounds,
+			MaxTargetRestarts:   c.AbortTargetRestarts,
+		},
+	}
+}
+
 func ValidateConfig(cfg Config) error {
 	if cfg.RequestSourceType == "" {
 		return fmt.Errorf("request-source-type is required")
@@ -118,6 +308,16 @@ func ValidateConfig(cfg Config) error {
 	if cfg.LoadCalculator == "" {
 		return fmt.Errorf("load-calculator is required")
 	}
+	if cfg.RequestSourceEOF != "loop" && cfg.RequestSourceEOF != "stop" {
+		return fmt.Errorf("unsupported request-source-eof %q", cfg.RequestSourceEOF)
+	}
+	if cfg.RequestSourceEOF == "stop" && (cfg.PlanFile != "" || cfg.RequestSourceType == "mix") {
+		// Phases and mix sources each read their own source, and the first to run out would end the whole run.
+		return fmt.Errorf("request-source-eof=stop cannot be used with plan-file or request-source-type=mix")
+	}
+	if cfg.RequestSourceSkip < 0 {
+		return fmt.Errorf("request-source-skip must be >= 0")
+	}
 	if cfg.MinRPS < 0 || cfg.MaxRPS < 0 {
 		return fmt.Errorf("min-rps and max-rps must be >= 0")
 	}
@@ -127,15 +327,93 @@ func ValidateConfig(cfg Config) error {
 	if cfg.AdaptiveMaxLatencyMillis < 0 {
 		return fmt.Errorf("adaptive-max-latency-ms must be >= 0")
 	}
+	switch manager.LatencyMetric(cfg.AdaptiveLatencyMetric) {
+	case manager.LatencyMetricService, manager.LatencyMetricCorrected:
+	default:
+		return fmt.Errorf("unsupported adaptive-latency-metric %q", cfg.AdaptiveLatencyMetric)
+	}
+	if cfg.ReplaySpeedup <= 0 {
+		return fmt.Errorf("replay-speedup must be > 0")
+	}
+	switch cfg.LoadModel {
+	case types.LoadModelOpen:
+	case types.LoadModelClosed:
+		if cfg.ThinkTime < 0 || cfg.ThinkTimeMin < 0 || cfg.ThinkTimeMax < 0 {
+			return fmt.Errorf("think-time, think-time-min and think-time-max must be >= 0")
+		}
+		switch cfg.ThinkTimeDistribution {
+		case types.ThinkTimeConstant, types.ThinkTimeExponential:
+		case types.ThinkTimeUniform:
+			if cfg.ThinkTimeMax < cfg.ThinkTimeMin {
+				return fmt.Errorf("think-time-max must be >= think-time-min")
+			}
+		default:
+			return fmt.Errorf("unsupported think-time-distribution %q", cfg.ThinkTimeDistribution)
+		}
+	default:
+		return fmt.Errorf("unsupported load-model %q", cfg.LoadModel)
+	}
+	switch cfg.ArrivalProcess {
+	case types.ArrivalUniform, types.ArrivalPoisson:
+	case types.ArrivalRecorded:
+		// Each round dispatches a schedule-interval of recorded time, which has to fit in its jobs.
+		if cfg.JobDuration < cfg.ScheduleInterval {
+			return fmt.Errorf("job-duration must be >= schedule-interval when arrival-process=recorded")
+		}
+	case types.ArrivalBurst:
+		if cfg.ArrivalBurstSize <= 0 {
+			return fmt.Errorf("arrival-burst-size must be > 0 when arrival-process=burst")
+		}
+	default:
+		return fmt.Errorf("unsupported arrival-process %q", cfg.ArrivalProcess)
+	}
 	if cfg.ScheduleInterval <= 0 {
 		return fmt.Errorf("schedule-interval must be > 0")
 	}
 	if cfg.JobDuration <= 0 {
 		return fmt.Errorf("job-duration must be > 0")
 	}
+	if cfg.LoadCalculator == "replay" && cfg.JobDuration < time.Second {
+		// Jobs send for whole seconds of their duration, so a shorter job sends none of the log's requests.
+		return fmt.Errorf("job-duration must be >= 1s when load-calculator=replay")
+	}
 	if cfg.MetricsPollInterval <= 0 {
 		return fmt.Errorf("metrics-poll-interval must be > 0")
 	}
+	if cfg.RequestTimeout < 0 {
+		return fmt.Errorf("request-timeout must be >= 0")
+	}
+	if cfg.RPS < 0 {
+		return fmt.Errorf("rps must be >= 0")
+	}
+	if cfg.RunDuration < 0 {
+		return fmt.Errorf("run-duration must be >= 0")
+	}
+	if cfg.RunMaxRequests < 0 {
+		return fmt.Errorf("run-max-requests must be >= 0")
+	}
+	if cfg.RunMaxRequests > 0 && cfg.LoadModel == types.LoadModelClosed {
+		// Virtual users cycle through their jobs' requests, so the requests they send are not bounded by the
+		// requests dispatched.
+		return fmt.Errorf("run-max-requests cannot be used with load-model=closed")
+	}
+	if cfg.AbortErrorRatio < 0 || cfg.AbortErrorRatio > 1 {
+		return fmt.Errorf("abort-error-ratio must be between 0 and 1")
+	}
+	if cfg.AbortP99LatencyMillis < 0 {
+		return fmt.Errorf("abort-p99-latency-ms must be >= 0")
+	}
+	switch manager.LatencyMetric(cfg.AbortLatencyMetric) {
+	case manager.LatencyMetricService, manager.LatencyMetricCorrected:
+	default:
+		return fmt.Errorf("unsupported abort-latency-metric %q", cfg.AbortLatencyMetric)
+	}
+	if cfg.AbortConsecutiveRounds < 1 {
+		return fmt.Errorf("abort-consecutive-rounds must be >= 1")
+	}
+	if cfg.AbortTargetRestarts < 0 {
+		return fmt.Errorf("abort-target-restarts must be >= 0")
+	}
 	switch cfg.TargetMode {
 	case string(k8s.TargetModePod):
 		if cfg.TargetNamespace == "" {
@@ -104,3 +104,177 @@ func TestValidateConfigRejectsNegativeAdaptiveLatency(t *testing.T) {
 		t.Fatalf("expected validation error for negative adaptive max latency")
 	}
 }
+
+func TestValidateConfigRejectsUnsupportedAdaptiveLatencyMetric(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TargetDeployment = "target"
+	cfg.AdaptiveLatencyMetric = "wall-clock"
+
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for unsupported adaptive latency metric")
+	}
+}
+
+func TestParseConfigClosedModel(t *testing.T) {
+	cfg, err := ParseConfig([]string{
+		"-load-model=closed",
+		"-think-time-distribution=uniform",
+		"-think-time-min=200ms",
+		"-think-time-max=800ms",
+	})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	cfg.TargetDeployment = "target"
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("expected closed model config to validate, got: %v", err)
+	}
+	thinkTime := cfg.ThinkTimeSpec()
+	if thinkTime.Distribution != "uniform" || thinkTime.MinMillis != 200 || thinkTime.MaxMillis != 800 {
+		t.Fatalf("unexpected think time spec: %+v", thinkTime)
+	}
+}
+
+func TestValidateConfigRejectsInvalidLoadModel(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TargetDeployment = "target"
+	cfg.LoadModel = "hybrid"
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for unsupported load model")
+	}
+
+	cfg.LoadModel = "closed"
+	cfg.ThinkTimeDistribution = "uniform"
+	cfg.ThinkTimeMin = time.Second
+	cfg.ThinkTimeMax = time.Millisecond
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for inverted uniform think time range")
+	}
+}
+
+func TestValidateConfigArrivalProcess(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TargetDeployment = "target"
+	cfg.ArrivalProcess = "poisson"
+	cfg.ArrivalSeed = 99
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("expected poisson arrival to validate, got: %v", err)
+	}
+	if spec := cfg.ArrivalSpec(); spec.Type != "poisson" || spec.Seed != 99 {
+		t.Fatalf("unexpected arrival spec: %+v", spec)
+	}
+
+	cfg.ArrivalProcess = "burst"
+	cfg.ArrivalBurstSize = 0
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for burst arrival without a burst size")
+	}
+
+	cfg.ArrivalProcess = "recorded"
+	cfg.JobDuration = cfg.ScheduleInterval / 2
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for recorded arrival with a job shorter than the schedule interval")
+	}
+	cfg.JobDuration = cfg.ScheduleInterval
+
+	cfg.ArrivalProcess = "fractal"
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for unsupported arrival process")
+	}
+}
+
+func TestValidateConfigRunLimits(t *testing.T) {
+	cfg, err := ParseConfig([]string{"-run-duration=5m", "-run-max-requests=1000"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	cfg.TargetDeployment = "target"
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("expected run limits to validate, got: %v", err)
+	}
+	if limits := cfg.RunLimits(); limits.MaxDuration != 5*time.Minute || limits.MaxRequests != 1000 {
+		t.Fatalf("unexpected run limits: %+v", limits)
+	}
+
+	cfg.RunMaxRequests = -1
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for negative run-max-requests")
+	}
+	cfg.RunMaxRequests = 1000
+	cfg.LoadModel = "closed"
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for run-max-requests with the closed model")
+	}
+}
+
+func TestValidateConfigRequestTimeout(t *testing.T) {
+	cfg, err := ParseConfig([]string{"-request-timeout=2s"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	cfg.TargetDeployment = "target"
+	if cfg.RequestTimeout != 2*time.Second {
+		t.Fatalf("expected request timeout 2s, got %s", cfg.RequestTimeout)
+	}
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("expected request timeout to validate, got: %v", err)
+	}
+
+	cfg.RequestTimeout = -time.Second
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for negative request-timeout")
+	}
+}
+
+func TestConfigWithOverrides(t *testing.T) {
+	base := DefaultConfig()
+	base.TargetDeployment = "target"
+
+	cfg, err := base.WithOverrides(map[string]string{"load-calculator": "step", "run-duration": "10m"})
+	if err != nil {
+		t.Fatalf("unexpected override error: %v", err)
+	}
+	if cfg.LoadCalculator != "step" || cfg.RunDuration != 10*time.Minute {
+		t.Fatalf("expected overrides to apply, got %s / %s", cfg.LoadCalculator, cfg.RunDuration)
+	}
+	if cfg.TargetDeployment != "target" || base.RunDuration != 0 {
+		t.Fatalf("expected overrides to copy the base config without changing it")
+	}
+
+	for name, value := range map[string]string{"no-such-flag": "1", "min-rps": "many"} {
+		if _, err := base.WithOverrides(map[string]string{name: value}); err == nil {
+			t.Fatalf("expected override error for %s=%s", name, value)
+		}
+	}
+}
+
+func TestValidateConfigAbortConditions(t *testing.T) {
+	cfg, err := ParseConfig([]string{
+		"-abort-error-ratio=0.05",
+		"-abort-p99-latency-ms=800",
+		"-abort-latency-metric=corrected",
+		"-abort-target-restarts=1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	cfg.TargetDeployment = "target"
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("expected abort conditions to validate, got: %v", err)
+	}
+	abort := cfg.RunLimits().Abort
+	if abort.MaxErrorRatio != 0.05 || abort.MaxP99LatencyMillis != 800 || abort.ConsecutiveRounds != 3 ||
+		abort.LatencyMetric != "corrected" || abort.MaxTargetRestarts != 1 {
+		t.Fatalf("unexpected abort conditions: %+v", abort)
+	}
+
+	cfg.AbortErrorRatio = 1.5
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for abort-error-ratio above 1")
+	}
+	cfg.AbortErrorRatio = 0
+	cfg.AbortConsecutiveRounds = 0
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for abort-consecutive-rounds below 1")
+	}
+}
@@ -2,6 +2,8 @@ package app
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/PeladoCollado/imager/orchestrator/manager"
 	"github.com/PeladoCollado/imager/orchestrator/requests"
@@ -34,12 +36,40 @@ func NewBuiltInRequestSource(cfg Config) (types.RequestSource, error) {
 		if cfg.RequestSourceFile == "" {
 			return nil, fmt.Errorf("request-source-file is required when request-source-type=file")
 		}
-		return requests.NewFileReader(cfg.RequestSourceFile)
+		return requests.NewStreamReader(cfg.RequestSourceFile, requests.StreamOptions{
+			StopAtEOF: cfg.RequestSourceEOF == "stop",
+			Shuffle:   cfg.RequestSourceShuffle,
+			Seed:      cfg.RequestSourceSeed,
+			Skip:      cfg.RequestSourceSkip,
+		})
 	case "random-sum":
 		if cfg.RandomSumMax < cfg.RandomSumMin {
 			return nil, fmt.Errorf("random-sum-max must be >= random-sum-min")
 		}
 		return requests.NewRandomSumSource(cfg.RandomSumPath, cfg.RandomSumMin, cfg.RandomSumMax)
+	case "har":
+		if cfg.HARFile == "" {
+			return nil, fmt.Errorf("har-file is required when request-source-type=har")
+		}
+		return requests.NewHARSource(cfg.HARFile, requests.HAROptions{
+			Hosts:          splitList(cfg.HARHosts),
+			URLPattern:     cfg.HARURLPattern,
+			StripHeaders:   splitList(cfg.HARStripHeaders),
+			PreserveTiming: cfg.HARPreserveTiming,
+		})
+	case "access-log":
+		if cfg.AccessLogFile == "" {
+			return nil, fmt.Errorf("access-log-file is required when request-source-type=access-log")
+		}
+		return requests.NewAccessLogSource(cfg.AccessLogFile, cfg.AccessLogFormat)
+	case "openapi":
+		return newOpenAPISource(cfg)
+	case "template":
+		return newTemplateSource(cfg)
+	case "scenario":
+		return newScenarioSource(cfg)
+	case "mix":
+		return newRequestMix(cfg, RequestSourceFactoryFunc(NewBuiltInRequestSource))
 	default:
 		return nil, fmt.Errorf("unsupported request-source-type %q", cfg.RequestSourceType)
 	}
@@ -47,6 +77,8 @@ func NewBuiltInRequestSource(cfg Config) (types.RequestSource, error) {
 
 func NewBuiltInLoadCalculator(cfg Config) (manager.LoadCalculator, error) {
 	switch cfg.LoadCalculator {
+	case "constant":
+		return manager.NewConstantLoadCalculator(cfg.RPS), nil
 	case "step":
 		if cfg.StepRPS <= 0 {
 			return nil, fmt.Errorf("step-rps must be > 0 for step calculator")
@@ -57,17 +89,51 @@ func NewBuiltInLoadCalculator(cfg Config) (manager.LoadCalculator, error) {
 	case "logarithmic":
 		return manager.NewLogarithmicLoadCalculator(cfg.MinRPS, cfg.MaxRPS), nil
 	case "adaptive-exponential":
-		return manager.NewAdaptiveExponentialLoadCalculator(cfg.MinRPS, cfg.MaxRPS, cfg.AdaptiveMaxLatencyMillis), nil
+		return manager.NewAdaptiveExponentialLoadCalculatorWithMetric(cfg.MinRPS,
+			cfg.MaxRPS,
+			cfg.AdaptiveMaxLatencyMillis,
+			manager.LatencyMetric(cfg.AdaptiveLatencyMetric)), nil
+	case "replay":
+		if cfg.AccessLogFile == "" {
+			return nil, fmt.Errorf("access-log-file is required when load-calculator=replay")
+		}
+		entries, err := requests.ReadAccessLog(cfg.AccessLogFile, cfg.AccessLogFormat)
+		if err != nil {
+			return nil, err
+		}
+		timestamps := make([]time.Time, len(entries))
+		for i, entry := range entries {
+			timestamps[i] = entry.Time
+		}
+		return manager.NewReplayLoadCalculator(timestamps, cfg.ScheduleInterval, cfg.JobDuration, cfg.ReplaySpeedup), nil
 	default:
 		return nil, fmt.Errorf("unsupported load-calculator %q", cfg.LoadCalculator)
 	}
 }
 
+// splitList splits a comma-separated flag value, dropping blank items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// requestSourceFactoryOrDefault returns the custom factory, or the built-in one if there is none. Request mixes are
+// always assembled here so that their children can come from the custom factory.
 func requestSourceFactoryOrDefault(factory RequestSourceFactory) RequestSourceFactory {
-	if factory != nil {
-		return factory
+	if factory == nil {
+		return RequestSourceFactoryFunc(NewBuiltInRequestSource)
 	}
-	return RequestSourceFactoryFunc(NewBuiltInRequestSource)
+	return RequestSourceFactoryFunc(func(cfg Config) (types.RequestSource, error) {
+		if cfg.RequestSourceType == "mix" {
+			return newRequestMix(cfg, factory)
+		}
+		return factory.NewRequestSource(cfg)
+	})
 }
 
 func loadCalculatorFactoryOrDefault(factory LoadCalculatorFactory) LoadCalculatorFactory {
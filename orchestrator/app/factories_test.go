@@ -1,7 +1,11 @@
 package app
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/PeladoCollado/imager/orchestrator/manager"
 	"github.com/PeladoCollado/imager/types"
@@ -96,3 +100,179 @@ type staticLoadCalculator int
 func (s staticLoadCalculator) Next() int {
 	return int(s)
 }
+
+func TestBuiltInLoadCalculatorSupportsConstant(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.LoadCalculator = "constant"
+	cfg.RPS = 42
+
+	calc, err := NewBuiltInLoadCalculator(cfg)
+	if err != nil {
+		t.Fatalf("unexpected constant calculator error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if got := calc.Next(); got != 42 {
+			t.Fatalf("expected constant 42 rps, got %d", got)
+		}
+	}
+}
+
+func TestBuiltInRequestSourceSupportsHAR(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.har")
+	har := `{"log": {"entries": [
+  {"startedDateTime": "2024-05-01T10:00:00Z", "request": {"method": "GET", "url": "https://api.example.com/a",
+    "headers": [{"name": "Cookie", "value": "sid=1"}, {"name": "X-Trace", "value": "1"}]}},
+  {"startedDateTime": "2024-05-01T10:00:02Z", "request": {"method": "GET", "url": "https://cdn.example.com/b",
+    "headers": []}},
+  {"startedDateTime": "2024-05-01T10:00:03Z", "request": {"method": "GET", "url": "https://api.example.com/c",
+    "headers": [{"name": "X-Trace", "value": "2"}]}}
+]}}`
+	if err := os.WriteFile(path, []byte(har), 0o600); err != nil {
+		t.Fatalf("unable to write HAR file: %v", err)
+	}
+	cfg, err := ParseConfig([]string{
+		"-request-source-type=har",
+		"-har-file=" + path,
+		"-har-hosts=api.example.com, ",
+		"-har-strip-headers=cookie,x-trace",
+		"-har-preserve-timing",
+		"-arrival-process=recorded",
+	})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	cfg.TargetDeployment = "target"
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	source, err := NewBuiltInRequestSource(cfg)
+	if err != nil {
+		t.Fatalf("unexpected HAR source error: %v", err)
+	}
+	first, _ := source.Next()
+	second, _ := source.Next()
+	if first.Path != "/a" || second.Path != "/c" || second.DelayMillis != 3000 {
+		t.Fatalf("expected the api.example.com entries with recorded timing, got %+v and %+v", first, second)
+	}
+	if len(first.Headers) != 0 {
+		t.Fatalf("expected the listed headers to be stripped, got %v", first.Headers)
+	}
+
+	cfg.HARFile = ""
+	if _, err := NewBuiltInRequestSource(cfg); err == nil {
+		t.Fatalf("expected error when har-file is missing")
+	}
+}
+
+func TestBuiltInAccessLogReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	log := `10.0.0.1 - - [01/May/2024:10:00:00 +0000] "GET /a HTTP/1.1" 200 1 "-" "curl/8.0"
+10.0.0.1 - - [01/May/2024:10:00:00 +0000] "GET /b HTTP/1.1" 200 1 "-" "curl/8.0"
+10.0.0.1 - - [01/May/2024:10:00:01 +0000] "GET /c HTTP/1.1" 200 1 "-" "curl/8.0"
+`
+	if err := os.WriteFile(path, []byte(log), 0o600); err != nil {
+		t.Fatalf("unable to write access log: %v", err)
+	}
+	cfg, err := ParseConfig([]string{
+		"-request-source-type=access-log",
+		"-load-calculator=replay",
+		"-access-log-file=" + path,
+		"-replay-speedup=2",
+	})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	cfg.TargetDeployment = "target"
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	source, err := NewBuiltInRequestSource(cfg)
+	if err != nil {
+		t.Fatalf("unexpected access log source error: %v", err)
+	}
+	if next, _ := source.Next(); next.Path != "/a" || next.Headers["User-Agent"][0] != "curl/8.0" {
+		t.Fatalf("expected the first logged request, got %+v", next)
+	}
+
+	calc, err := NewBuiltInLoadCalculator(cfg)
+	if err != nil {
+		t.Fatalf("unexpected replay calculator error: %v", err)
+	}
+	if got := calc.Next(); got != 3 {
+		t.Fatalf("expected one round to replay 2s of the log at 3 rps, got %d", got)
+	}
+	if exhaustible, ok := calc.(manager.ExhaustibleLoadCalculator); !ok || !exhaustible.Exhausted() {
+		t.Fatalf("expected the replay calculator to be exhausted after the log")
+	}
+
+	cfg.AccessLogFile = ""
+	if _, err := NewBuiltInRequestSource(cfg); err == nil {
+		t.Fatalf("expected error when access-log-file is missing for the source")
+	}
+	if _, err := NewBuiltInLoadCalculator(cfg); err == nil {
+		t.Fatalf("expected error when access-log-file is missing for the calculator")
+	}
+	cfg.ReplaySpeedup = 0
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for a non-positive replay speedup")
+	}
+	cfg.ReplaySpeedup = 1
+	cfg.JobDuration = 500 * time.Millisecond
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for a replay job shorter than a second")
+	}
+}
+
+func TestBuiltInRequestSourceStreamOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "requests.json")
+	content := `{"method":"GET","path":"/first"}
+{"method":"GET","path":"/second"}
+{"method":"GET","path":"/third"}
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write request file: %v", err)
+	}
+	cfg, err := ParseConfig([]string{
+		"-request-source-file=" + path,
+		"-request-source-eof=stop",
+		"-request-source-skip=1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	cfg.TargetDeployment = "target"
+	if err := ValidateConfig(cfg); err != nil {
+		t.Fatalf("unexpected validation error: %v", err)
+	}
+
+	source, err := NewBuiltInRequestSource(cfg)
+	if err != nil {
+		t.Fatalf("unexpected file source error: %v", err)
+	}
+	for _, expected := range []string{"/second", "/third"} {
+		if next, err := source.Next(); err != nil || next.Path != expected {
+			t.Fatalf("expected %s, got %+v (%v)", expected, next, err)
+		}
+	}
+	if _, err := source.Next(); !errors.Is(err, types.ErrSourceExhausted) {
+		t.Fatalf("expected the source to stop at the end of the file, got %v", err)
+	}
+
+	cfg.RequestSourceEOF = "rewind"
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for an unsupported request-source-eof")
+	}
+	cfg.RequestSourceEOF = "stop"
+	cfg.PlanFile = "plan.yaml"
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for request-source-eof=stop with a plan")
+	}
+	cfg.PlanFile = ""
+	cfg.RequestSourceEOF = "loop"
+	cfg.RequestSourceSkip = -1
+	if err := ValidateConfig(cfg); err == nil {
+		t.Fatalf("expected validation error for a negative request-source-skip")
+	}
+}
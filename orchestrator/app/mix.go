@@ -0,0 +1,94 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/PeladoCollado/imager/orchestrator/requests"
+	"github.com/PeladoCollado/imager/types"
+)
+
+// MixFile is the YAML request mix loaded from -request-mix-file.
+type MixFile struct {
+	Sources []MixSource `json:"sources"`
+}
+
+// MixSource is one weighted traffic class of a request mix. Settings overrides the request source flags listed in
+// mixSourceFlags; flags that are not set keep the run's values.
+type MixSource struct {
+	Name     string         `json:"name"`
+	Weight   int            `json:"weight"`
+	Settings map[string]any `json:"settings"`
+}
+
+// mixSourceFlags are the flags a mix source may override. request-source-eof is left out: a source that stops
+// would end the whole run, not just its share of the mix.
+var mixSourceFlags = map[string]struct{}{
+	"request-source-type":    {},
+	"request-source-file":    {},
+	"request-source-shuffle": {},
+	"request-source-seed":    {},
+	"request-source-skip":    {},
+	"scenario-file":          {},
+	"template-file":          {},
+	"har-file":               {},
+	"har-hosts":              {},
+	"har-url-pattern":        {},
+	"har-strip-headers":      {},
+	"har-preserve-timing":    {},
+	"access-log-file":        {},
+	"access-log-format":      {},
+	"openapi-file":           {},
+	"openapi-include":        {},
+	"openapi-exclude":        {},
+	"openapi-weights":        {},
+	"random-sum-path":        {},
+	"random-sum-min":         {},
+	"random-sum-max":         {},
+}
+
+func LoadMixFile(path string) (MixFile, error) {
+	mix := MixFile{}
+	if err := decodeYAMLFile(path, &mix); err != nil {
+		return MixFile{}, fmt.Errorf("load request mix file: %w", err)
+	}
+	return mix, nil
+}
+
+// newRequestMix builds a weighted request source whose children are created by factory, so custom request sources
+// can take part in a mix.
+func newRequestMix(cfg Config, factory RequestSourceFactory) (types.RequestSource, error) {
+	if cfg.RequestMixFile == "" {
+		return nil, fmt.Errorf("request-mix-file is required when request-source-type=mix")
+	}
+	mix, err := LoadMixFile(cfg.RequestMixFile)
+	if err != nil {
+		return nil, err
+	}
+	children := make([]requests.WeightedChild, 0, len(mix.Sources))
+	for _, mixSource := range mix.Sources {
+		settings, err := settingsOverrides(mixSource.Settings, mixSourceFlags, "mix source")
+		if err != nil {
+			return nil, fmt.Errorf("mix source %s: %w", mixSource.Name, err)
+		}
+		childCfg, err := cfg.WithOverrides(settings)
+		if err != nil {
+			return nil, fmt.Errorf("mix source %s: %w", mixSource.Name, err)
+		}
+		if childCfg.RequestSourceType == "mix" {
+			return nil, fmt.Errorf("mix source %s cannot itself be a mix", mixSource.Name)
+		}
+		if err := rejectUniqueFeeders(childCfg, "request mix"); err != nil {
+			return nil, fmt.Errorf("mix source %s: %w", mixSource.Name, err)
+		}
+		source, err := factory.NewRequestSource(childCfg)
+		if err != nil {
+			return nil, fmt.Errorf("mix source %s: %w", mixSource.Name, err)
+		}
+		children = append(children, requests.WeightedChild{
+			Name:   mixSource.Name,
+			Weight: mixSource.Weight,
+			Source: source,
+		})
+	}
+	return requests.NewWeightedSource(children)
+}
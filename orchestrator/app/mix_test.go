@@ -0,0 +1,105 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PeladoCollado/imager/types"
+)
+
+func writeMix(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mix.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write request mix: %v", err)
+	}
+	return path
+}
+
+func TestBuiltInRequestSourceSupportsMix(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RequestSourceType = "mix"
+	cfg.RequestMixFile = writeMix(t, `
+sources:
+  - name: sums
+    weight: 3
+    settings:
+      request-source-type: random-sum
+      random-sum-path: /sum
+  - name: totals
+    weight: 1
+    settings:
+      request-source-type: random-sum
+      random-sum-path: /total
+`)
+
+	source, err := NewBuiltInRequestSource(cfg)
+	if err != nil {
+		t.Fatalf("unexpected request mix error: %v", err)
+	}
+	counts := make(map[string]int)
+	for i := 0; i < 8; i++ {
+		req, err := source.Next()
+		if err != nil {
+			t.Fatalf("unexpected request error: %v", err)
+		}
+		if (req.Source == "sums") != (req.Path == "/sum") {
+			t.Fatalf("request tagged %q has path %s", req.Source, req.Path)
+		}
+		counts[req.Source]++
+	}
+	if counts["sums"] != 6 || counts["totals"] != 2 {
+		t.Fatalf("expected a 3:1 mix, got %+v", counts)
+	}
+}
+
+func TestRequestMixUsesCustomFactoryForChildren(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.RequestSourceType = "mix"
+	cfg.RequestMixFile = writeMix(t, `
+sources:
+  - name: custom
+    weight: 1
+    settings:
+      request-source-type: custom
+`)
+	factory := requestSourceFactoryOrDefault(RequestSourceFactoryFunc(func(cfg Config) (types.RequestSource, error) {
+		if cfg.RequestSourceType != "custom" {
+			t.Fatalf("expected child config to carry its settings, got %q", cfg.RequestSourceType)
+		}
+		return &noopSource{}, nil
+	}))
+
+	source, err := factory.NewRequestSource(cfg)
+	if err != nil {
+		t.Fatalf("unexpected request mix error: %v", err)
+	}
+	req, err := source.Next()
+	if err != nil || req.Source != "custom" {
+		t.Fatalf("expected request tagged custom, got %+v (%v)", req, err)
+	}
+}
+
+func TestRequestMixRejectsInvalidSources(t *testing.T) {
+	tests := map[string]string{
+		"nested mix":     "sources:\n  - name: a\n    weight: 1\n    settings:\n      request-source-type: mix\n",
+		"run-level flag": "sources:\n  - name: a\n    weight: 1\n    settings:\n      max-rps: 10\n",
+		"zero weight":    "sources:\n  - name: a\n    settings:\n      request-source-type: random-sum\n",
+		"source eof":     "sources:\n  - name: a\n    weight: 1\n    settings:\n      request-source-eof: stop\n",
+	}
+	for name, content := range tests {
+		cfg := DefaultConfig()
+		cfg.RequestSourceType = "mix"
+		cfg.RequestMixFile = writeMix(t, content)
+		if _, err := NewBuiltInRequestSource(cfg); err == nil {
+			t.Fatalf("%s: expected request mix error", name)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.RequestSourceType = "mix"
+	if _, err := NewBuiltInRequestSource(cfg); err == nil {
+		t.Fatalf("expected error when request-mix-file is missing")
+	}
+}
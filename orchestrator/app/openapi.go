@@ -0,0 +1,45 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/PeladoCollado/imager/orchestrator/requests"
+	"github.com/PeladoCollado/imager/types"
+)
+
+// newOpenAPISource builds a request source from the operations of cfg.OpenAPIFile. Include and exclude items match
+// either a tag or an operation ID.
+func newOpenAPISource(cfg Config) (types.RequestSource, error) {
+	if cfg.OpenAPIFile == "" {
+		return nil, fmt.Errorf("openapi-file is required when request-source-type=openapi")
+	}
+	weights, err := parseWeights(cfg.OpenAPIWeights)
+	if err != nil {
+		return nil, err
+	}
+	include := splitList(cfg.OpenAPIInclude)
+	exclude := splitList(cfg.OpenAPIExclude)
+	return requests.NewOpenAPISource(cfg.OpenAPIFile, requests.OpenAPIOptions{
+		IncludeTags:       include,
+		IncludeOperations: include,
+		ExcludeTags:       exclude,
+		ExcludeOperations: exclude,
+		Weights:           weights,
+	})
+}
+
+// parseWeights parses the operation=weight pairs of -openapi-weights.
+func parseWeights(value string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, pair := range splitList(value) {
+		operation, weightText, ok := strings.Cut(pair, "=")
+		weight, err := strconv.Atoi(strings.TrimSpace(weightText))
+		if !ok || err != nil || strings.TrimSpace(operation) == "" {
+			return nil, fmt.Errorf("openapi-weights must be operation=weight pairs, got %q", pair)
+		}
+		weights[strings.TrimSpace(operation)] = weight
+	}
+	return weights, nil
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltInRequestSourceSupportsOpenAPI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "openapi.yaml")
+	document := `openapi: 3.0.3
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      tags: [pets]
+    post:
+      operationId: createPet
+      tags: [pets]
+      requestBody:
+        content:
+          application/json:
+            example: {name: rex}
+  /health:
+    get:
+      operationId: health
+      tags: [ops]
+`
+	if err := os.WriteFile(path, []byte(document), 0o600); err != nil {
+		t.Fatalf("unable to write OpenAPI document: %v", err)
+	}
+	cfg, err := ParseConfig([]string{
+		"-request-source-type=openapi",
+		"-openapi-file=" + path,
+		"-openapi-exclude=ops",
+		"-openapi-weights=listPets=2, createPet=1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+
+	source, err := NewBuiltInRequestSource(cfg)
+	if err != nil {
+		t.Fatalf("unexpected OpenAPI source error: %v", err)
+	}
+	byName := make(map[string]int)
+	for i := 0; i < 6; i++ {
+		next, err := source.Next()
+		if err != nil {
+			t.Fatalf("unexpected request error: %v", err)
+		}
+		if next.Name == "createPet" && next.Body != `{"name":"rex"}` {
+			t.Fatalf("expected the body example, got %q", next.Body)
+		}
+		byName[next.Name]++
+	}
+	if byName["listPets"] != 4 || byName["createPet"] != 2 || len(byName) != 2 {
+		t.Fatalf("expected a 2:1 mix without the excluded tag, got %v", byName)
+	}
+
+	cfg.OpenAPIWeights = "listPets:2"
+	if _, err := NewBuiltInRequestSource(cfg); err == nil {
+		t.Fatalf("expected error for malformed openapi-weights")
+	}
+	cfg.OpenAPIFile = ""
+	if _, err := NewBuiltInRequestSource(cfg); err == nil {
+		t.Fatalf("expected error when openapi-file is missing")
+	}
+}
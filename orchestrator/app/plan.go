@@ -0,0 +1,178 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/PeladoCollado/imager/orchestrator/manager"
+	"sigs.k8s.io/yaml"
+)
+
+// PlanFile is the YAML test plan loaded from -plan-file.
+type PlanFile struct {
+	Phases []PlanPhase `json:"phases"`
+}
+
+// PlanPhase describes one phase of a test plan. Settings overrides the per-phase flags listed in phaseFlags; flags
+// that are not set keep the run's values.
+type PlanPhase struct {
+	Name         string         `json:"name"`
+	Warmup       bool           `json:"warmup"`
+	Duration     string         `json:"duration"`
+	Rounds       int            `json:"rounds"`
+	UntilSettled bool           `json:"untilSettled"`
+	Settings     map[string]any `json:"settings"`
+}
+
+// phaseFlags are the flags a plan phase may override. Everything else applies to the run as a whole, including
+// request-source-eof, since a phase source that stops would end the run rather than the phase.
+var phaseFlags = map[string]struct{}{
+	"request-source-type":     {},
+	"request-source-file":     {},
+	"request-source-shuffle":  {},
+	"request-source-seed":     {},
+	"request-source-skip":     {},
+	"request-mix-file":        {},
+	"scenario-file":           {},
+	"template-file":           {},
+	"har-file":                {},
+	"har-hosts":               {},
+	"har-url-pattern":         {},
+	"har-strip-headers":       {},
+	"har-preserve-timing":     {},
+	"access-log-file":         {},
+	"access-log-format":       {},
+	"openapi-file":            {},
+	"openapi-include":         {},
+	"openapi-exclude":         {},
+	"openapi-weights":         {},
+	"random-sum-path":         {},
+	"random-sum-min":          {},
+	"random-sum-max":          {},
+	"load-calculator":         {},
+	"rps":                     {},
+	"min-rps":                 {},
+	"max-rps":                 {},
+	"step-rps":                {},
+	"adaptive-max-latency-ms": {},
+	"adaptive-latency-metric": {},
+	"replay-speedup":          {},
+}
+
+func LoadPlanFile(path string) (PlanFile, error) {
+	plan := PlanFile{}
+	if err := decodeYAMLFile(path, &plan); err != nil {
+		return PlanFile{}, fmt.Errorf("load plan file: %w", err)
+	}
+	return plan, nil
+}
+
+// decodeYAMLFile decodes a YAML (or JSON) file into out using its json tags, rejecting unknown fields.
+func decodeYAMLFile(path string, out any) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	jsonContent, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	// Numbers are kept as written so that integer settings such as max-rps are not reformatted as floats.
+	decoder := json.NewDecoder(bytes.NewReader(jsonContent))
+	decoder.UseNumber()
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// settingsOverrides converts YAML setting values to flag values, rejecting flags outside of allowed.
+func settingsOverrides(settings map[string]any, allowed map[string]struct{}, scope string) (map[string]string, error) {
+	overrides := make(map[string]string, len(settings))
+	for flagName, value := range settings {
+		if _, ok := allowed[flagName]; !ok {
+			return nil, fmt.Errorf("%s cannot be set per %s", flagName, scope)
+		}
+		overrides[flagName] = fmt.Sprint(value)
+	}
+	return overrides, nil
+}
+
+// newPhasedLoad builds a phased load calculator from the plan, creating each phase's calculator and request source
+// from the run config with the phase's settings applied.
+func newPhasedLoad(cfg Config,
+	plan PlanFile,
+	sourceFactory RequestSourceFactory,
+	loadFactory LoadCalculatorFactory) (*manager.PhasedLoadCalculator, error) {
+	phases := make([]manager.Phase, 0, len(plan.Phases))
+	for i, planPhase := range plan.Phases {
+		name := planPhase.Name
+		if name == "" {
+			name = fmt.Sprintf("phase-%d", i+1)
+		}
+		phase, err := buildPhase(cfg, name, planPhase, sourceFactory, loadFactory)
+		if err != nil {
+			return nil, fmt.Errorf("phase %s: %w", name, err)
+		}
+		phases = append(phases, phase)
+	}
+	return manager.NewPhasedLoadCalculator(phases)
+}
+
+func buildPhase(cfg Config,
+	name string,
+	planPhase PlanPhase,
+	sourceFactory RequestSourceFactory,
+	loadFactory LoadCalculatorFactory) (manager.Phase, error) {
+	var duration time.Duration
+	if planPhase.Duration != "" {
+		parsed, err := time.ParseDuration(planPhase.Duration)
+		if err != nil {
+			return manager.Phase{}, fmt.Errorf("invalid duration %q: %w", planPhase.Duration, err)
+		}
+		duration = parsed
+	}
+	if duration < 0 || planPhase.Rounds < 0 {
+		return manager.Phase{}, fmt.Errorf("duration and rounds must be >= 0")
+	}
+
+	settings, err := settingsOverrides(planPhase.Settings, phaseFlags, "phase")
+	if err != nil {
+		return manager.Phase{}, err
+	}
+	phaseCfg, err := cfg.WithOverrides(settings)
+	if err != nil {
+		return manager.Phase{}, err
+	}
+	if err := ValidateConfig(phaseCfg); err != nil {
+		return manager.Phase{}, err
+	}
+	if err := rejectUniqueFeeders(phaseCfg, "plan phase"); err != nil {
+		return manager.Phase{}, err
+	}
+
+	source, err := sourceFactory.NewRequestSource(phaseCfg)
+	if err != nil {
+		return manager.Phase{}, fmt.Errorf("initialize request source: %w", err)
+	}
+	calculator, err := loadFactory.NewLoadCalculator(phaseCfg)
+	if err != nil {
+		return manager.Phase{}, fmt.Errorf("initialize load calculator: %w", err)
+	}
+	if _, ok := calculator.(manager.SettlingLoadCalculator); planPhase.UntilSettled && !ok {
+		return manager.Phase{}, fmt.Errorf("load calculator %s cannot be used with untilSettled", phaseCfg.LoadCalculator)
+	}
+	return manager.Phase{
+		Name:         name,
+		Calculator:   calculator,
+		Source:       source,
+		Warmup:       planPhase.Warmup,
+		Duration:     duration,
+		Rounds:       planPhase.Rounds,
+		UntilSettled: planPhase.UntilSettled,
+	}, nil
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/PeladoCollado/imager/orchestrator/manager"
+	"github.com/PeladoCollado/imager/types"
+)
+
+const testPlan = `
+phases:
+  - name: warmup
+    warmup: true
+    duration: 1m
+    settings:
+      load-calculator: constant
+      rps: 20
+  - name: ramp
+    untilSettled: true
+    settings:
+      load-calculator: step
+      min-rps: 20
+      max-rps: 1000000
+      step-rps: 20
+  - name: soak
+    rounds: 600
+    settings:
+      load-calculator: constant
+      rps: 1000000
+`
+
+func writePlan(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write plan: %v", err)
+	}
+	return path
+}
+
+func TestLoadPlanFile(t *testing.T) {
+	plan, err := LoadPlanFile(writePlan(t, testPlan))
+	if err != nil {
+		t.Fatalf("unexpected plan error: %v", err)
+	}
+	if len(plan.Phases) != 3 {
+		t.Fatalf("expected 3 phases, got %d", len(plan.Phases))
+	}
+	warmup := plan.Phases[0]
+	if warmup.Name != "warmup" || !warmup.Warmup || warmup.Duration != "1m" {
+		t.Fatalf("unexpected warmup phase: %+v", warmup)
+	}
+	if plan.Phases[2].Rounds != 600 {
+		t.Fatalf("expected soak rounds 600, got %d", plan.Phases[2].Rounds)
+	}
+
+	if _, err := LoadPlanFile(writePlan(t, "phases:\n  - name: a\n    durationn: 1m\n")); err == nil {
+		t.Fatalf("expected error for unknown plan field")
+	}
+}
+
+func TestNewPhasedLoadAppliesPhaseSettings(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TargetDeployment = "target"
+	cfg.RequestSourceType = "random-sum"
+	plan, err := LoadPlanFile(writePlan(t, testPlan))
+	if err != nil {
+		t.Fatalf("unexpected plan error: %v", err)
+	}
+
+	phased, err := newPhasedLoad(cfg, plan, RequestSourceFactoryFunc(NewBuiltInRequestSource),
+		LoadCalculatorFactoryFunc(NewBuiltInLoadCalculator))
+	if err != nil {
+		t.Fatalf("unexpected phased load error: %v", err)
+	}
+	if got := phased.Next(); got != 20 {
+		t.Fatalf("expected warmup load of 20, got %d", got)
+	}
+	if phase, warmup := phased.CurrentPhase(); phase != "warmup" || !warmup {
+		t.Fatalf("expected warmup phase, got %s (warmup=%t)", phase, warmup)
+	}
+}
+
+func TestNewPhasedLoadRejectsInvalidPhases(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.TargetDeployment = "target"
+	cfg.RequestSourceType = "random-sum"
+	sources := RequestSourceFactoryFunc(NewBuiltInRequestSource)
+	customCalc := LoadCalculatorFactoryFunc(func(cfg Config) (manager.LoadCalculator, error) {
+		return constantLoadCalculator(1), nil
+	})
+
+	tests := map[string]PlanPhase{
+		"run-level flag":      {Rounds: 1, Settings: map[string]any{"job-duration": "2s"}},
+		"source eof":          {Rounds: 1, Settings: map[string]any{"request-source-eof": "stop"}},
+		"invalid value":       {Rounds: 1, Settings: map[string]any{"max-rps": "lots"}},
+		"invalid config":      {Rounds: 1, Settings: map[string]any{"min-rps": "50", "max-rps": "10"}},
+		"invalid duration":    {Duration: "soon"},
+		"unsettled with calc": {UntilSettled: true},
+	}
+	for name, phase := range tests {
+		plan := PlanFile{Phases: []PlanPhase{phase}}
+		if _, err := newPhasedLoad(cfg, plan, sources, customCalc); err == nil {
+			t.Fatalf("%s: expected plan error", name)
+		}
+	}
+}
+
+func TestRunLauncherUsesPlanFile(t *testing.T) {
+	manager.ResetRuns()
+	t.Cleanup(manager.ResetRuns)
+
+	cfg := DefaultConfig()
+	cfg.TargetDeployment = "target"
+	cfg.PlanFile = writePlan(t, testPlan)
+	launcher := &runLauncher{
+		sourceFactory: RequestSourceFactoryFunc(func(cfg Config) (types.RequestSource, error) {
+			return &noopSource{}, nil
+		}),
+		loadFactory: LoadCalculatorFactoryFunc(NewBuiltInLoadCalculator),
+	}
+
+	calculator, source, err := launcher.newLoad(cfg)
+	if err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+	if _, ok := calculator.(*manager.PhasedLoadCalculator); !ok {
+		t.Fatalf("expected phased load calculator, got %T", calculator)
+	}
+	if _, err := source.Next(); err != nil {
+		t.Fatalf("unexpected request source error: %v", err)
+	}
+}
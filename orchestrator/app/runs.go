@@ -0,0 +1,227 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/orchestrator/api"
+	"github.com/PeladoCollado/imager/orchestrator/k8s"
+	"github.com/PeladoCollado/imager/orchestrator/manager"
+	"github.com/PeladoCollado/imager/types"
+)
+
+// runLauncher builds the request source, load calculator and target resolver for a run and schedules it. It
+// serves both the startup run and runs created through the control API.
+type runLauncher struct {
+	lock sync.Mutex
+
+	base          Config
+	sourceFactory RequestSourceFactory
+	loadFactory   LoadCalculatorFactory
+	metrics       *metrics.OrchestratorMetrics
+	kubeClient    *k8s.Client
+}
+
+// CreateRun applies the fields set in the request on top of the startup config and starts a run from the result.
+func (l *runLauncher) CreateRun(ctx context.Context, request api.RunRequest) (*manager.Run, error) {
+	cfg, err := l.base.WithOverrides(runSettings(request))
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return l.start(ctx, cfg)
+}
+
+// runSettings converts the fields set in a run request to the values of the flags they mirror.
+func runSettings(request api.RunRequest) map[string]string {
+	settings := map[string]string{}
+	if target := request.Target; target != nil {
+		setting(settings, "target-mode", target.Mode)
+		setting(settings, "target-namespace", target.Namespace)
+		setting(settings, "target-deployment", target.Deployment)
+		setting(settings, "target-service", target.Service)
+		setting(settings, "target-url", target.URL)
+		setting(settings, "target-port-name", target.PortName)
+		setting(settings, "target-scheme", target.Scheme)
+	}
+	if source := request.RequestSource; source != nil {
+		setting(settings, "request-source-type", source.Type)
+		setting(settings, "request-source-eof", source.EOF)
+		setting(settings, "request-source-shuffle", source.Shuffle)
+		setting(settings, "request-source-seed", source.Seed)
+		setting(settings, "request-source-skip", source.Skip)
+		setting(settings, "har-hosts", source.HARHosts)
+		setting(settings, "har-url-pattern", source.HARURLPattern)
+		setting(settings, "har-strip-headers", source.HARStripHeaders)
+		setting(settings, "har-preserve-timing", source.HARPreserveTiming)
+		setting(settings, "access-log-format", source.AccessLogFormat)
+		setting(settings, "openapi-include", source.OpenAPIInclude)
+		setting(settings, "openapi-exclude", source.OpenAPIExclude)
+		setting(settings, "openapi-weights", source.OpenAPIWeights)
+		setting(settings, "random-sum-path", source.RandomSumPath)
+		setting(settings, "random-sum-min", source.RandomSumMin)
+		setting(settings, "random-sum-max", source.RandomSumMax)
+	}
+	if load := request.Load; load != nil {
+		setting(settings, "load-calculator", load.Calculator)
+		setting(settings, "rps", load.RPS)
+		setting(settings, "min-rps", load.MinRPS)
+		setting(settings, "max-rps", load.MaxRPS)
+		setting(settings, "step-rps", load.StepRPS)
+		setting(settings, "adaptive-max-latency-ms", load.AdaptiveMaxLatencyMs)
+		setting(settings, "adaptive-latency-metric", load.AdaptiveLatencyMetric)
+		setting(settings, "replay-speedup", load.ReplaySpeedup)
+		setting(settings, "load-model", load.Model)
+		setting(settings, "think-time", load.ThinkTime)
+		setting(settings, "think-time-min", load.ThinkTimeMin)
+		setting(settings, "think-time-max", load.ThinkTimeMax)
+		setting(settings, "think-time-distribution", load.ThinkTimeDistribution)
+	}
+	if arrival := request.Arrival; arrival != nil {
+		setting(settings, "arrival-process", arrival.Process)
+		setting(settings, "arrival-burst-size", arrival.BurstSize)
+		setting(settings, "arrival-seed", arrival.Seed)
+	}
+	if schedule := request.Schedule; schedule != nil {
+		setting(settings, "schedule-interval", schedule.Interval)
+		setting(settings, "job-duration", schedule.JobDuration)
+		setting(settings, "request-timeout", schedule.RequestTimeout)
+	}
+	if limits := request.Limits; limits != nil {
+		setting(settings, "run-duration", limits.Duration)
+		setting(settings, "run-max-requests", limits.MaxRequests)
+	}
+	if abort := request.Abort; abort != nil {
+		setting(settings, "abort-error-ratio", abort.ErrorRatio)
+		setting(settings, "abort-p99-latency-ms", abort.P99LatencyMs)
+		setting(settings, "abort-latency-metric", abort.LatencyMetric)
+		setting(settings, "abort-consecutive-rounds", abort.ConsecutiveRounds)
+		setting(settings, "abort-target-restarts", abort.TargetRestarts)
+	}
+	return settings
+}
+
+func setting[T any](settings map[string]string, flagName string, value *T) {
+	if value != nil {
+		settings[flagName] = fmt.Sprint(*value)
+	}
+}
+
+func (l *runLauncher) start(ctx context.Context, cfg Config) (*manager.Run, error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if active := manager.ActiveRun(); active != nil {
+		return nil, fmt.Errorf("%w: %s", manager.ErrRunActive, active.ID())
+	}
+
+	calculator, source, err := l.newLoad(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var kubeClient *k8s.Client
+	if k8s.TargetMode(cfg.TargetMode) != k8s.TargetModeURL {
+		kubeClient, err = l.kubernetesClient()
+		if err != nil {
+			return nil, err
+		}
+	}
+	resolverConfig := k8s.TargetResolverConfig{
+		Mode:       k8s.TargetMode(cfg.TargetMode),
+		Namespace:  cfg.TargetNamespace,
+		Deployment: cfg.TargetDeployment,
+		Service:    cfg.TargetService,
+		URL:        cfg.TargetURL,
+		PortName:   cfg.TargetPortName,
+		Scheme:     cfg.TargetScheme,
+	}
+	targetResolver, err := k8s.NewTargetResolver(kubeClient, resolverConfig)
+	if err != nil {
+		return nil, fmt.Errorf("initialize target resolver: %w", err)
+	}
+
+	run := manager.NewRun(fmt.Sprintf("run-%d", time.Now().UnixNano()), cfg.RunLimits())
+	manager.RegisterRun(run)
+	err = manager.Schedule(
+		ctx,
+		run,
+		calculator,
+		source,
+		targetResolver,
+		l.metrics,
+		manager.ScheduleOptions{
+			Interval:    cfg.ScheduleInterval,
+			JobDuration: cfg.JobDuration,
+			LoadModel:   cfg.LoadModel,
+			ThinkTime:   cfg.ThinkTimeSpec(),
+			Arrival:     cfg.ArrivalSpec(),
+
+			RequestTimeout: cfg.RequestTimeout,
+		},
+	)
+	if err != nil {
+		run.Abort(err.Error())
+		return nil, fmt.Errorf("start run: %w", err)
+	}
+
+	if kubeClient != nil {
+		runCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			defer cancel()
+			select {
+			case <-run.Done():
+			case <-runCtx.Done():
+			}
+		}()
+		go pollPodMetrics(runCtx, targetResolver, kubeClient, cfg.TargetNamespace, l.metrics, l.base.MetricsPollInterval)
+	}
+	return run, nil
+}
+
+// newLoad builds the run's load calculator and request source, either from the plan file or from the flags.
+func (l *runLauncher) newLoad(cfg Config) (manager.LoadCalculator, types.RequestSource, error) {
+	if cfg.PlanFile != "" {
+		plan, err := LoadPlanFile(cfg.PlanFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		phased, err := newPhasedLoad(cfg, plan, l.sourceFactory, l.loadFactory)
+		if err != nil {
+			return nil, nil, fmt.Errorf("initialize plan: %w", err)
+		}
+		return phased, phased.RequestSource(), nil
+	}
+
+	source, err := l.sourceFactory.NewRequestSource(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("initialize request source: %w", err)
+	}
+	calculator, err := l.loadFactory.NewLoadCalculator(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("initialize load calculator: %w", err)
+	}
+	return calculator, source, nil
+}
+
+// kubernetesClient returns the shared Kubernetes client, creating it from the startup config on first use.
+func (l *runLauncher) kubernetesClient() (*k8s.Client, error) {
+	if l.kubeClient != nil {
+		return l.kubeClient, nil
+	}
+	kubeConfig, err := initKubeConfig(l.base)
+	if err != nil {
+		return nil, fmt.Errorf("initialize kubernetes config: %w", err)
+	}
+	client, err := k8s.NewClient(kubeConfig)
+	if err != nil {
+		return nil, fmt.Errorf("initialize kubernetes clients: %w", err)
+	}
+	l.kubeClient = client
+	return client, nil
+}
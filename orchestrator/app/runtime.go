@@ -11,7 +11,6 @@ import (
 	"github.com/PeladoCollado/imager/orchestrator/api"
 	"github.com/PeladoCollado/imager/orchestrator/k8s"
 	"github.com/PeladoCollado/imager/orchestrator/logger"
-	"github.com/PeladoCollado/imager/orchestrator/manager"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	v1 "k8s.io/api/core/v1"
@@ -31,44 +30,6 @@ func Run(ctx context.Context, cfg Config, opts RunOptions) error {
 		return err
 	}
 
-	sourceFactory := requestSourceFactoryOrDefault(opts.RequestSourceFactory)
-	source, err := sourceFactory.NewRequestSource(cfg)
-	if err != nil {
-		return fmt.Errorf("initialize request source: %w", err)
-	}
-
-	loadFactory := loadCalculatorFactoryOrDefault(opts.LoadCalculatorFactory)
-	calculator, err := loadFactory.NewLoadCalculator(cfg)
-	if err != nil {
-		return fmt.Errorf("initialize load calculator: %w", err)
-	}
-
-	var kubeClient *k8s.Client
-	if k8s.TargetMode(cfg.TargetMode) != k8s.TargetModeURL {
-		kubeConfig, cfgErr := initKubeConfig(cfg)
-		if cfgErr != nil {
-			return fmt.Errorf("initialize kubernetes config: %w", cfgErr)
-		}
-		kubeClient, err = k8s.NewClient(kubeConfig)
-		if err != nil {
-			return fmt.Errorf("initialize kubernetes clients: %w", err)
-		}
-	}
-
-	resolverConfig := k8s.TargetResolverConfig{
-		Mode:       k8s.TargetMode(cfg.TargetMode),
-		Namespace:  cfg.TargetNamespace,
-		Deployment: cfg.TargetDeployment,
-		Service:    cfg.TargetService,
-		URL:        cfg.TargetURL,
-		PortName:   cfg.TargetPortName,
-		Scheme:     cfg.TargetScheme,
-	}
-	targetResolver, err := k8s.NewTargetResolver(kubeClient, resolverConfig)
-	if err != nil {
-		return fmt.Errorf("initialize target resolver: %w", err)
-	}
-
 	registerer := opts.Registerer
 	if registerer == nil {
 		registerer = prometheus.DefaultRegisterer
@@ -78,24 +39,25 @@ func Run(ctx context.Context, cfg Config, opts RunOptions) error {
 		gatherer = prometheus.DefaultGatherer
 	}
 
-	orchestratorMetrics := metrics.NewOrchestratorMetrics(registerer)
-	manager.Schedule(
-		ctx,
-		calculator,
-		source,
-		targetResolver,
-		orchestratorMetrics,
-		manager.ScheduleOptions{
-			Interval:    cfg.ScheduleInterval,
-			JobDuration: cfg.JobDuration,
-		},
-	)
-
+	launcher := &runLauncher{
+		base:          cfg,
+		sourceFactory: requestSourceFactoryOrDefault(opts.RequestSourceFactory),
+		loadFactory:   loadCalculatorFactoryOrDefault(opts.LoadCalculatorFactory),
+		metrics:       metrics.NewOrchestratorMetrics(registerer),
+	}
 	if k8s.TargetMode(cfg.TargetMode) != k8s.TargetModeURL {
-		go pollPodMetrics(ctx, targetResolver, kubeClient, cfg.TargetNamespace, orchestratorMetrics, cfg.MetricsPollInterval)
+		if _, err := launcher.kubernetesClient(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.StartRun {
+		if _, err := launcher.start(ctx, cfg); err != nil {
+			return err
+		}
 	}
 
-	baseHandler := api.NewHandler(ctx)
+	baseHandler := api.NewHandler(ctx, launcher)
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
 	mux.Handle("/", baseHandler)
@@ -2,9 +2,11 @@ package app
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/PeladoCollado/imager/orchestrator/api"
 	"github.com/PeladoCollado/imager/orchestrator/manager"
 	"github.com/PeladoCollado/imager/types"
 	"github.com/prometheus/client_golang/prometheus"
@@ -19,6 +21,8 @@ func TestRunURLModeDoesNotRequireKubernetesConfig(t *testing.T) {
 	cfg.TargetDeployment = ""
 	cfg.RequestSourceType = "custom"
 	cfg.LoadCalculator = "custom"
+	manager.ResetRuns()
+	t.Cleanup(manager.ResetRuns)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -50,6 +54,82 @@ func TestRunURLModeDoesNotRequireKubernetesConfig(t *testing.T) {
 	}
 }
 
+func TestRunLauncherCreatesOneActiveRunAtATime(t *testing.T) {
+	manager.ResetRuns()
+	t.Cleanup(manager.ResetRuns)
+
+	base := DefaultConfig()
+	base.TargetMode = "url"
+	base.TargetURL = "https://example.com"
+	base.RequestSourceType = "custom"
+	base.LoadCalculator = "custom"
+	launcher := &runLauncher{
+		base: base,
+		sourceFactory: RequestSourceFactoryFunc(func(cfg Config) (types.RequestSource, error) {
+			return &noopSource{}, nil
+		}),
+		loadFactory: LoadCalculatorFactoryFunc(func(cfg Config) (manager.LoadCalculator, error) {
+			return constantLoadCalculator(cfg.MinRPS), nil
+		}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, err := launcher.CreateRun(ctx, api.RunRequest{Load: &api.RunLoad{MaxRPS: ptr(-1)}}); err == nil {
+		t.Fatalf("expected validation error for invalid settings")
+	}
+	if _, err := launcher.CreateRun(ctx, api.RunRequest{Limits: &api.RunLimits{Duration: ptr("soon")}}); err == nil {
+		t.Fatalf("expected error for an invalid duration")
+	}
+
+	run, err := launcher.CreateRun(ctx, api.RunRequest{
+		Limits: &api.RunLimits{MaxRequests: ptr(50)},
+		Abort: &api.RunAbort{
+			ErrorRatio:    ptr(0.2),
+			P99LatencyMs:  ptr(int64(250)),
+			LatencyMetric: ptr("corrected"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected create error: %v", err)
+	}
+	if manager.CurrentRun() != run || run.State() != manager.RunStateRunning {
+		t.Fatalf("expected created run to be current and running, got %s", run.State())
+	}
+
+	if _, err := launcher.CreateRun(ctx, api.RunRequest{}); !errors.Is(err, manager.ErrRunActive) {
+		t.Fatalf("expected ErrRunActive while a run is active, got %v", err)
+	}
+
+	run.Abort("test finished")
+	if _, err := launcher.CreateRun(ctx, api.RunRequest{}); err != nil {
+		t.Fatalf("expected a new run once the previous run ended, got %v", err)
+	}
+}
+
+func TestRunSettingsMirrorFlags(t *testing.T) {
+	request := api.RunRequest{
+		Target:        &api.RunTarget{Mode: ptr("url"), URL: ptr("https://example.com")},
+		RequestSource: &api.RunRequestSource{Shuffle: ptr(true), Seed: ptr(int64(7))},
+		Load:          &api.RunLoad{Calculator: ptr("step"), ReplaySpeedup: ptr(2.5), ThinkTime: ptr("2s")},
+		Abort:         &api.RunAbort{ErrorRatio: ptr(0.25), P99LatencyMs: ptr(int64(300))},
+	}
+	cfg, err := DefaultConfig().WithOverrides(runSettings(request))
+	if err != nil {
+		t.Fatalf("unexpected override error: %v", err)
+	}
+	if cfg.TargetMode != "url" || cfg.TargetURL != "https://example.com" || !cfg.RequestSourceShuffle ||
+		cfg.RequestSourceSeed != 7 || cfg.LoadCalculator != "step" || cfg.ReplaySpeedup != 2.5 ||
+		cfg.ThinkTime != 2*time.Second || cfg.AbortErrorRatio != 0.25 || cfg.AbortP99LatencyMillis != 300 {
+		t.Fatalf("unexpected config from run request: %+v", cfg)
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
+
 type noopSource struct{}
 
 func (n *noopSource) Next() (types.RequestSpec, error) {
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/PeladoCollado/imager/orchestrator/requests"
+	"github.com/PeladoCollado/imager/types"
+)
+
+// ScenarioFile is the YAML list of scenarios loaded from -scenario-file.
+type ScenarioFile struct {
+	Scenarios []ScenarioDefinition `json:"scenarios"`
+}
+
+// ScenarioDefinition is a named scenario. Its results are reported under Name.
+type ScenarioDefinition struct {
+	Name string `json:"name"`
+	types.Scenario
+}
+
+func LoadScenarioFile(path string) (ScenarioFile, error) {
+	file := ScenarioFile{}
+	if err := decodeYAMLFile(path, &file); err != nil {
+		return ScenarioFile{}, fmt.Errorf("load scenario file: %w", err)
+	}
+	return file, nil
+}
+
+// newScenarioSource builds a request source that cycles through the scenarios of cfg.ScenarioFile.
+func newScenarioSource(cfg Config) (types.RequestSource, error) {
+	if cfg.ScenarioFile == "" {
+		return nil, fmt.Errorf("scenario-file is required when request-source-type=scenario")
+	}
+	file, err := LoadScenarioFile(cfg.ScenarioFile)
+	if err != nil {
+		return nil, err
+	}
+	scenarios := make([]types.RequestSpec, 0, len(file.Scenarios))
+	for _, definition := range file.Scenarios {
+		if definition.Name == "" {
+			return nil, fmt.Errorf("scenarios require a name")
+		}
+		scenario := definition.Scenario
+		scenarios = append(scenarios, types.RequestSpec{Name: definition.Name, Scenario: &scenario})
+	}
+	return requests.NewScenarioSource(scenarios)
+}
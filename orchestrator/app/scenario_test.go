@@ -0,0 +1,92 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltInRequestSourceSupportsScenarios(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scenarios.yaml")
+	content := `
+scenarios:
+  - name: checkout
+    variables:
+      tenant: acme
+    steps:
+      - name: login
+        method: POST
+        path: /login
+        body: '{"user":"demo"}'
+        extract:
+          - variable: token
+            jsonPath: $.token
+          - variable: sid
+            cookie: sid
+      - name: order
+        method: POST
+        path: /orders
+        queryString: tenant=${tenant}
+        headers:
+          Authorization: ["Bearer ${token}"]
+        expect:
+          status: [201]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write scenario file: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.RequestSourceType = "scenario"
+	cfg.ScenarioFile = path
+
+	source, err := NewBuiltInRequestSource(cfg)
+	if err != nil {
+		t.Fatalf("unexpected scenario source error: %v", err)
+	}
+	next, err := source.Next()
+	if err != nil {
+		t.Fatalf("unexpected request error: %v", err)
+	}
+	if next.Name != "checkout" || next.Scenario == nil || len(next.Scenario.Steps) != 2 {
+		t.Fatalf("expected the checkout scenario, got %+v", next)
+	}
+	login, order := next.Scenario.Steps[0], next.Scenario.Steps[1]
+	if login.Method != "POST" || login.Path != "/login" || len(login.Extract) != 2 ||
+		login.Extract[0].JSONPath != "$.token" || login.Extract[1].Cookie != "sid" {
+		t.Fatalf("unexpected login step %+v", login)
+	}
+	if order.Headers["Authorization"][0] != "Bearer ${token}" || order.Expect == nil {
+		t.Fatalf("unexpected order step %+v", order)
+	}
+	if next.Scenario.Variables["tenant"] != "acme" {
+		t.Fatalf("expected scenario variables, got %v", next.Scenario.Variables)
+	}
+}
+
+func TestScenarioSourceRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]string{
+		"missing name":    "scenarios:\n  - steps:\n      - path: /\n",
+		"no steps":        "scenarios:\n  - name: empty\n",
+		"bad extraction":  "scenarios:\n  - name: a\n    steps:\n      - path: /\n        extract:\n          - variable: x\n",
+		"unknown field":   "scenarios:\n  - name: a\n    stepz: []\n",
+		"empty scenarios": "scenarios: []\n",
+	}
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), "scenarios.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write scenario file: %v", err)
+		}
+		cfg := DefaultConfig()
+		cfg.RequestSourceType = "scenario"
+		cfg.ScenarioFile = path
+		if _, err := NewBuiltInRequestSource(cfg); err == nil {
+			t.Fatalf("%s: expected scenario source error", name)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.RequestSourceType = "scenario"
+	if _, err := NewBuiltInRequestSource(cfg); err == nil {
+		t.Fatalf("expected error when scenario-file is missing")
+	}
+}
@@ -0,0 +1,61 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/PeladoCollado/imager/orchestrator/requests"
+	"github.com/PeladoCollado/imager/types"
+)
+
+// TemplateFile is the YAML request template loaded from -template-file: a request spec whose placeholders are
+// filled from the named feeders.
+type TemplateFile struct {
+	types.RequestSpec
+	Feeders map[string]requests.FeederConfig `json:"feeders"`
+}
+
+func LoadTemplateFile(path string) (TemplateFile, error) {
+	file := TemplateFile{}
+	if err := decodeYAMLFile(path, &file); err != nil {
+		return TemplateFile{}, fmt.Errorf("load template file: %w", err)
+	}
+	return file, nil
+}
+
+// newTemplateSource builds a request source from the template and feeders of cfg.TemplateFile.
+func newTemplateSource(cfg Config) (types.RequestSource, error) {
+	if cfg.TemplateFile == "" {
+		return nil, fmt.Errorf("template-file is required when request-source-type=template")
+	}
+	file, err := LoadTemplateFile(cfg.TemplateFile)
+	if err != nil {
+		return nil, err
+	}
+	feeders := make(map[string]requests.Feeder, len(file.Feeders))
+	for name, feederCfg := range file.Feeders {
+		feeder, err := requests.NewFeeder(feederCfg)
+		if err != nil {
+			return nil, fmt.Errorf("feeder %s: %w", name, err)
+		}
+		feeders[name] = feeder
+	}
+	return requests.NewTemplateSource(file.RequestSpec, feeders)
+}
+
+// rejectUniqueFeeders fails if cfg is a template source with a unique csv feeder. Such a source runs out, which
+// completes the whole run, so it cannot be one of several sources such as the phases of a plan or a request mix.
+func rejectUniqueFeeders(cfg Config, scope string) error {
+	if cfg.RequestSourceType != "template" || cfg.TemplateFile == "" {
+		return nil
+	}
+	file, err := LoadTemplateFile(cfg.TemplateFile)
+	if err != nil {
+		return err
+	}
+	for name, feederCfg := range file.Feeders {
+		if feederCfg.Type == requests.FeederCSV && feederCfg.Mode == requests.CSVUnique {
+			return fmt.Errorf("feeder %s: unique csv feeders cannot be used in a %s", name, scope)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuiltInRequestSourceSupportsTemplates(t *testing.T) {
+	dir := t.TempDir()
+	users := filepath.Join(dir, "users.csv")
+	if err := os.WriteFile(users, []byte("id,token\n7,abc\n8,def\n"), 0o600); err != nil {
+		t.Fatalf("unable to write csv file: %v", err)
+	}
+	path := filepath.Join(dir, "template.yaml")
+	content := `
+method: GET
+path: /users/${user.id}/items
+queryString: page=${page}
+headers:
+  Authorization: ["Bearer ${user.token}"]
+name: items
+feeders:
+  user:
+    type: csv
+    file: ` + users + `
+    mode: sequential
+  page:
+    type: counter
+    start: 1
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write template file: %v", err)
+	}
+	cfg := DefaultConfig()
+	cfg.RequestSourceType = "template"
+	cfg.TemplateFile = path
+
+	source, err := NewBuiltInRequestSource(cfg)
+	if err != nil {
+		t.Fatalf("unexpected template source error: %v", err)
+	}
+	for _, expected := range []string{"/users/7/items?page=1 abc", "/users/8/items?page=2 def"} {
+		next, err := source.Next()
+		if err != nil {
+			t.Fatalf("unexpected request error: %v", err)
+		}
+		got := next.Path + "?" + next.QueryString + " " + strings.TrimPrefix(next.Headers["Authorization"][0], "Bearer ")
+		if got != expected || next.Name != "items" {
+			t.Fatalf("expected %q, got %q (%+v)", expected, got, next)
+		}
+	}
+}
+
+func TestTemplateSourceRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]string{
+		"unknown feeder":      "path: /items/${item}\nfeeders: {}\n",
+		"unknown feeder type": "path: /\nfeeders:\n  x:\n    type: faker\n",
+		"unknown field":       "path: /\nfeeders: {}\nfeederz: {}\n",
+	}
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), "template.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("unable to write template file: %v", err)
+		}
+		cfg := DefaultConfig()
+		cfg.RequestSourceType = "template"
+		cfg.TemplateFile = path
+		if _, err := NewBuiltInRequestSource(cfg); err == nil {
+			t.Fatalf("%s: expected template source error", name)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.RequestSourceType = "template"
+	if _, err := NewBuiltInRequestSource(cfg); err == nil {
+		t.Fatalf("expected error when template-file is missing")
+	}
+}
+
+func TestUniqueFeedersAreRejectedInMixesAndPhases(t *testing.T) {
+	dir := t.TempDir()
+	users := filepath.Join(dir, "users.csv")
+	if err := os.WriteFile(users, []byte("id\n7\n"), 0o600); err != nil {
+		t.Fatalf("unable to write csv file: %v", err)
+	}
+	template := filepath.Join(dir, "template.yaml")
+	content := "path: /users/${user.id}\nfeeders:\n  user:\n    type: csv\n    file: " + users + "\n    mode: unique\n"
+	if err := os.WriteFile(template, []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write template file: %v", err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.TargetDeployment = "target"
+	cfg.RequestSourceType = "template"
+	cfg.TemplateFile = template
+	if _, err := NewBuiltInRequestSource(cfg); err != nil {
+		t.Fatalf("expected a unique feeder to be allowed as the run's only source, got %v", err)
+	}
+
+	mixCfg := DefaultConfig()
+	mixCfg.RequestSourceType = "mix"
+	mixCfg.RequestMixFile = writeMix(t, "sources:\n  - name: users\n    weight: 1\n    settings:\n"+
+		"      request-source-type: template\n      template-file: "+template+"\n")
+	if _, err := NewBuiltInRequestSource(mixCfg); err == nil || !strings.Contains(err.Error(), "unique") {
+		t.Fatalf("expected a unique feeder to be rejected in a request mix, got %v", err)
+	}
+
+	plan := PlanFile{Phases: []PlanPhase{{Rounds: 1}}}
+	sources := RequestSourceFactoryFunc(NewBuiltInRequestSource)
+	loads := LoadCalculatorFactoryFunc(NewBuiltInLoadCalculator)
+	if _, err := newPhasedLoad(cfg, plan, sources, loads); err == nil || !strings.Contains(err.Error(), "unique") {
+		t.Fatalf("expected a unique feeder to be rejected in a plan phase, got %v", err)
+	}
+}
@@ -233,6 +233,33 @@ func (r *TargetResolver) CurrentPods(ctx context.Context) ([]v1.Pod, error) {
 	return pods, nil
 }
 
+// TargetRestarts returns the total container restart count across all of the target's pods. URL mode has no pods
+// and always reports zero.
+func (r *TargetResolver) TargetRestarts(ctx context.Context) (int, error) {
+	var pods []v1.Pod
+	var err error
+	switch r.cfg.Mode {
+	case TargetModePod:
+		pods, err = r.client.PodsForDeployment(ctx, r.cfg.Namespace, r.cfg.Deployment)
+	case TargetModeService:
+		pods, err = r.client.PodsForService(ctx, r.cfg.Namespace, r.cfg.Service)
+	case TargetModeURL:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unsupported target mode %q", r.cfg.Mode)
+	}
+	if err != nil {
+		return 0, err
+	}
+	restarts := 0
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts += int(status.RestartCount)
+		}
+	}
+	return restarts, nil
+}
+
 func (r *TargetResolver) resolvePods(ctx context.Context) ([]v1.Pod, error) {
 	switch r.cfg.Mode {
 	case TargetModePod:
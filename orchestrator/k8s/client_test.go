@@ -212,6 +212,44 @@ func TestServiceTargetURL(t *testing.T) {
 	}
 }
 
+func TestTargetResolverTargetRestarts(t *testing.T) {
+	namespace := "default"
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "target", Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "target"}},
+		},
+	}
+	podWithRestarts := func(name string, restarts ...int32) *v1.Pod {
+		statuses := make([]v1.ContainerStatus, 0, len(restarts))
+		for _, count := range restarts {
+			statuses = append(statuses, v1.ContainerStatus{RestartCount: count})
+		}
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app": "target"}},
+			Status:     v1.PodStatus{Phase: v1.PodRunning, PodIP: "10.0.0.1", ContainerStatuses: statuses},
+		}
+	}
+	kubeClient := k8sfake.NewSimpleClientset(deployment, podWithRestarts("pod-a", 1, 2), podWithRestarts("pod-b", 3))
+	client := NewClientWithClients(kubeClient, metricsfake.NewSimpleClientset())
+
+	resolver, err := NewTargetResolver(client, TargetResolverConfig{
+		Mode:       TargetModePod,
+		Namespace:  namespace,
+		Deployment: "target",
+	})
+	if err != nil {
+		t.Fatalf("unexpected resolver init error: %v", err)
+	}
+	restarts, err := resolver.TargetRestarts(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected restart count error: %v", err)
+	}
+	if restarts != 6 {
+		t.Fatalf("expected 6 restarts across all pods, got %d", restarts)
+	}
+}
+
 func TestTargetResolverURLMode(t *testing.T) {
 	resolver, err := NewTargetResolver(nil, TargetResolverConfig{
 		Mode: TargetModeURL,
@@ -0,0 +1,96 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/PeladoCollado/imager/orchestrator/logger"
+)
+
+// AbortConditions stop a run early when the target is clearly failing. Zero values disable a condition.
+type AbortConditions struct {
+	// MaxErrorRatio is the highest tolerated share of failed requests in a round. Timed out requests are counted in
+	// FailureCount, so they are included.
+	MaxErrorRatio float64
+	// MaxP99LatencyMillis is the highest tolerated round p99, measured with LatencyMetric.
+	MaxP99LatencyMillis int64
+	LatencyMetric       LatencyMetric
+	// ConsecutiveRounds is how many rounds in a row must breach the error ratio or latency limit before the run is
+	// aborted. Values below 1 are treated as 1.
+	ConsecutiveRounds int
+	// MaxTargetRestarts aborts the run once target containers have restarted this many times since it started.
+	MaxTargetRestarts int
+}
+
+// RestartCounter is implemented by target resolvers that can report the total container restart count of the
+// target.
+type RestartCounter interface {
+	TargetRestarts(ctx context.Context) (int, error)
+}
+
+// abortMonitor evaluates a run's AbortConditions. It is only used from the run's schedule goroutine.
+type abortMonitor struct {
+	conditions AbortConditions
+
+	errorBreaches   int
+	latencyBreaches int
+
+	restarts         int
+	lastRestartCount int
+	hasRestartCount  bool
+}
+
+// observe records a round of the run and reports why the run should abort, if it should.
+func (m *abortMonitor) observe(observation LoadObservation) (string, bool) {
+	required := max(m.conditions.ConsecutiveRounds, 1)
+
+	// Rounds that completed nothing carry no error signal; they neither extend nor reset a streak.
+	if m.conditions.MaxErrorRatio > 0 && observation.CompletedRequests > 0 {
+		ratio := float64(observation.FailureCount) / float64(observation.CompletedRequests)
+		if ratio > m.conditions.MaxErrorRatio {
+			m.errorBreaches++
+		} else {
+			m.errorBreaches = 0
+		}
+		if m.errorBreaches >= required {
+			return fmt.Sprintf("error ratio %.3f exceeded %.3f for %d consecutive rounds",
+				ratio, m.conditions.MaxErrorRatio, m.errorBreaches), true
+		}
+	}
+
+	if m.conditions.MaxP99LatencyMillis > 0 && observation.CompletedRequests > 0 {
+		p99 := observation.P99For(m.conditions.LatencyMetric)
+		if p99 > m.conditions.MaxP99LatencyMillis {
+			m.latencyBreaches++
+		} else {
+			m.latencyBreaches = 0
+		}
+		if m.latencyBreaches >= required {
+			return fmt.Sprintf("p99 latency %dms exceeded %dms for %d consecutive rounds",
+				p99, m.conditions.MaxP99LatencyMillis, m.latencyBreaches), true
+		}
+	}
+	return "", false
+}
+
+// checkRestarts polls the target's restart count and reports why the run should abort, if it should. Only
+// increases are counted, so pods that are replaced do not hide restarts that already happened.
+func (m *abortMonitor) checkRestarts(ctx context.Context, counter RestartCounter) (string, bool) {
+	if m.conditions.MaxTargetRestarts <= 0 || counter == nil {
+		return "", false
+	}
+	count, err := counter.TargetRestarts(ctx)
+	if err != nil {
+		logger.Logger.Warn("Unable to read target restart count", err)
+		return "", false
+	}
+	if m.hasRestartCount && count > m.lastRestartCount {
+		m.restarts += count - m.lastRestartCount
+	}
+	m.lastRestartCount = count
+	m.hasRestartCount = true
+	if m.restarts >= m.conditions.MaxTargetRestarts {
+		return fmt.Sprintf("target containers restarted %d times", m.restarts), true
+	}
+	return "", false
+}
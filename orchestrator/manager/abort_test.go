@@ -0,0 +1,156 @@
+package manager
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PeladoCollado/imager/types"
+)
+
+type fakeRestartCounter struct {
+	counts []int
+}
+
+func (f *fakeRestartCounter) TargetRestarts(context.Context) (int, error) {
+	count := f.counts[0]
+	if len(f.counts) > 1 {
+		f.counts = f.counts[1:]
+	}
+	return count, nil
+}
+
+func TestAbortMonitorRequiresConsecutiveErrorRounds(t *testing.T) {
+	monitor := &abortMonitor{conditions: AbortConditions{MaxErrorRatio: 0.1, ConsecutiveRounds: 2}}
+	failing := LoadObservation{CompletedRequests: 10, SuccessCount: 7, FailureCount: 3, TimeoutCount: 1}
+	healthy := LoadObservation{CompletedRequests: 10, SuccessCount: 10}
+
+	if _, breached := monitor.observe(failing); breached {
+		t.Fatalf("expected a single failing round not to abort")
+	}
+	if _, breached := monitor.observe(healthy); breached {
+		t.Fatalf("expected healthy round not to abort")
+	}
+	if _, breached := monitor.observe(failing); breached {
+		t.Fatalf("expected healthy round to reset the breach streak")
+	}
+	if _, breached := monitor.observe(LoadObservation{}); breached {
+		t.Fatalf("expected empty round not to abort")
+	}
+	if reason, breached := monitor.observe(failing); !breached || reason == "" {
+		t.Fatalf("expected second consecutive failing round to abort")
+	}
+}
+
+func TestAbortMonitorCountsTimeoutsOnce(t *testing.T) {
+	monitor := &abortMonitor{conditions: AbortConditions{MaxErrorRatio: 0.5}}
+	// Timed out requests are already part of FailureCount.
+	someTimeouts := LoadObservation{CompletedRequests: 10, SuccessCount: 7, FailureCount: 3, TimeoutCount: 3}
+	if _, breached := monitor.observe(someTimeouts); breached {
+		t.Fatalf("expected 30%% timeouts not to breach a 0.5 error ratio")
+	}
+	allTimeouts := LoadObservation{CompletedRequests: 10, FailureCount: 10, TimeoutCount: 10}
+	reason, breached := monitor.observe(allTimeouts)
+	if !breached || !strings.Contains(reason, "error ratio 1.000") {
+		t.Fatalf("expected an all-timeout round to breach with a ratio of 1, got %q", reason)
+	}
+}
+
+func TestAbortMonitorUsesConfiguredLatencyMetric(t *testing.T) {
+	monitor := &abortMonitor{conditions: AbortConditions{
+		MaxP99LatencyMillis: 500,
+		LatencyMetric:       LatencyMetricCorrected,
+	}}
+	observation := LoadObservation{CompletedRequests: 10, P99LatencyMillis: 100, P99CorrectedLatencyMillis: 900}
+	if _, breached := monitor.observe(observation); !breached {
+		t.Fatalf("expected corrected p99 above the limit to abort")
+	}
+}
+
+func TestAbortMonitorCountsRestartIncreases(t *testing.T) {
+	monitor := &abortMonitor{conditions: AbortConditions{MaxTargetRestarts: 2}}
+	// The baseline of 4 predates the run, the drop to 0 is a replaced pod, and only the later increases count.
+	counter := &fakeRestartCounter{counts: []int{4, 5, 0, 0, 1}}
+
+	for i := 0; i < 4; i++ {
+		if _, breached := monitor.checkRestarts(context.Background(), counter); breached {
+			t.Fatalf("expected no abort on poll %d", i)
+		}
+	}
+	if _, breached := monitor.checkRestarts(context.Background(), counter); !breached {
+		t.Fatalf("expected abort after two restarts")
+	}
+}
+
+type restartingResolver struct {
+	fakeResolver
+	fakeRestartCounter
+}
+
+func TestDispatchTickAbortsRunOnTargetRestarts(t *testing.T) {
+	ResetExecutors()
+	ResetRoundReports()
+	t.Cleanup(ResetExecutors)
+	t.Cleanup(ResetRoundReports)
+
+	AddExecutor("executor-1", 1)
+	exec := GetExecutor("executor-1")
+	exec.WorkChan = make(chan []types.Job, 2)
+
+	run := startedRun(t, RunLimits{Abort: AbortConditions{MaxTargetRestarts: 1}})
+	resolver := &restartingResolver{
+		fakeResolver:       fakeResolver{targets: []string{"http://10.0.0.1:8080"}},
+		fakeRestartCounter: fakeRestartCounter{counts: []int{0, 1}},
+	}
+	opts := ScheduleOptions{JobDuration: time.Second}
+
+	dispatchTick(context.Background(), run, &staticCalc{value: 1}, &fakeSource{}, resolver, nil, opts)
+	dispatchTick(context.Background(), run, &staticCalc{value: 1}, &fakeSource{}, resolver, nil, opts)
+
+	if run.State() != RunStateAborted {
+		t.Fatalf("expected run to abort after a target restart, got %s", run.State())
+	}
+	if len(exec.WorkChan) != 1 {
+		t.Fatalf("expected only the round before the restart to be dispatched, got %d", len(exec.WorkChan))
+	}
+}
+
+func TestDispatchTickAbortsRunOnErrorRatio(t *testing.T) {
+	ResetExecutors()
+	ResetRoundReports()
+	ResetRuns()
+	t.Cleanup(ResetExecutors)
+	t.Cleanup(ResetRoundReports)
+	t.Cleanup(ResetRuns)
+
+	AddExecutor("executor-1", 1)
+	exec := GetExecutor("executor-1")
+	exec.WorkChan = make(chan []types.Job, 1)
+
+	run := startedRun(t, RunLimits{Abort: AbortConditions{MaxErrorRatio: 0.5, ConsecutiveRounds: 1}})
+	resolver := &fakeResolver{targets: []string{"http://10.0.0.1:8080"}}
+	opts := ScheduleOptions{JobDuration: time.Second}
+
+	dispatchTick(context.Background(), run, &staticCalc{value: 2}, &fakeSource{}, resolver, nil, opts)
+	jobs := <-exec.WorkChan
+	if err := RecordJobReport(types.JobReport{
+		JobID:             jobs[0].ID,
+		RoundID:           jobs[0].RoundID,
+		PlannedRequests:   2,
+		CompletedRequests: 2,
+		FailureCount:      2,
+	}); err != nil {
+		t.Fatalf("unexpected report error: %v", err)
+	}
+
+	dispatchTick(context.Background(), run, &staticCalc{value: 2}, &fakeSource{}, resolver, nil, opts)
+
+	status := run.Status()
+	if status.State != RunStateAborted || status.Reason == "" {
+		t.Fatalf("expected run to abort with a reason, got %s (%q)", status.State, status.Reason)
+	}
+	if len(exec.WorkChan) != 0 {
+		t.Fatalf("expected no work dispatched after the abort")
+	}
+}
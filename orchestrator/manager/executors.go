@@ -23,6 +23,7 @@ var lock sync.Mutex
 type Executor struct {
 	Id            string
 	HeartbeatTime time.Time
+	ConnectedAt   time.Time
 	Workers       int
 	WorkChan      chan []types.Job
 }
@@ -67,6 +68,7 @@ func AddExecutor(id string, workerCount int) {
 	if _, ok := executorMap[id]; !ok {
 		executorMap[id] = &Executor{Id: id,
 			HeartbeatTime: time.Now(),
+			ConnectedAt:   time.Now(),
 			Workers:       workerCount,
 			WorkChan:      make(chan []types.Job)}
 	}
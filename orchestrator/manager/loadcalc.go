@@ -11,18 +11,114 @@ type FeedbackLoadCalculator interface {
 	Observe(observation LoadObservation)
 }
 
+// ExhaustibleLoadCalculator is implemented by calculators with a finite load profile. Once Exhausted reports
+// true the run completes.
+type ExhaustibleLoadCalculator interface {
+	LoadCalculator
+	Exhausted() bool
+}
+
+// SettlingLoadCalculator is implemented by calculators that converge on a load level: step and exponential
+// calculators once they reach their maximum, and the adaptive calculator once its search settles.
+type SettlingLoadCalculator interface {
+	LoadCalculator
+	Settled() bool
+}
+
+// LoadObservation summarizes a completed round. TotalRPS is the load level the round was dispatched at:
+// requests per second in the open model, or concurrent virtual users in the closed model. AchievedRPS is the
+// completion rate the executors actually measured.
 type LoadObservation struct {
-	RoundID           string
-	TotalRPS          int
-	PlannedRequests   int
-	CompletedRequests int
-	SuccessCount      int
-	FailureCount      int
-	TimeoutCount      int
-	P99LatencyMillis  int64
+	RoundID                   string  `json:"roundId"`
+	TotalRPS                  int     `json:"totalRps"`
+	PlannedRequests           int     `json:"plannedRequests"`
+	CompletedRequests         int     `json:"completedRequests"`
+	SuccessCount              int     `json:"successCount"`
+	FailureCount              int     `json:"failureCount"`
+	TimeoutCount              int     `json:"timeoutCount"`
+	AchievedRPS               float64 `json:"achievedRps"`
+	P99LatencyMillis          int64   `json:"p99LatencyMillis"`
+	P99CorrectedLatencyMillis int64   `json:"p99CorrectedLatencyMillis"`
+	// AssertionFailureCount is the share of FailureCount whose responses failed their expectations.
+	AssertionFailureCount int `json:"assertionFailureCount,omitempty"`
+	// Errors breaks FailureCount down by error category, such as "connection_refused" or "server_error".
+	Errors map[string]int `json:"errors,omitempty"`
+
+	// Latency and CorrectedLatency hold the round's service and corrected latency distributions. Their P99Millis
+	// match P99LatencyMillis and P99CorrectedLatencyMillis.
+	Latency          LatencyPercentiles `json:"latency"`
+	CorrectedLatency LatencyPercentiles `json:"correctedLatency"`
+	// Phases shows whether time went to connection setup or to the server.
+	Phases PhaseBreakdown `json:"phases"`
+
+	// Phase and Warmup identify the plan phase the round was dispatched in, if the run follows a plan.
+	Phase  string `json:"phase,omitempty"`
+	Warmup bool   `json:"warmup,omitempty"`
+
+	// Names breaks the round down by request name.
+	Names map[string]NamedObservation `json:"names,omitempty"`
+	// Steps breaks the round's scenarios down by step, keyed "<scenario>/<step>".
+	Steps map[string]NamedObservation `json:"steps,omitempty"`
+}
+
+// NamedObservation summarizes the requests of a round that share a name.
+type NamedObservation struct {
+	CompletedRequests int                `json:"completedRequests"`
+	SuccessCount      int                `json:"successCount"`
+	FailureCount      int                `json:"failureCount"`
+	TimeoutCount      int                `json:"timeoutCount"`
+	Latency           LatencyPercentiles `json:"latency"`
+
+	AssertionFailureCount int `json:"assertionFailureCount,omitempty"`
+}
+
+// PhaseBreakdown summarizes the request phases of a round. DNS, Connect and TLSHandshake only cover requests that
+// opened a new connection; NewConnections counts them.
+type PhaseBreakdown struct {
+	NewConnections int64              `json:"newConnections"`
+	DNS            LatencyPercentiles `json:"dns"`
+	Connect        LatencyPercentiles `json:"connect"`
+	TLSHandshake   LatencyPercentiles `json:"tlsHandshake"`
+	FirstByte      LatencyPercentiles `json:"firstByte"`
+	Transfer       LatencyPercentiles `json:"transfer"`
+}
+
+// LatencyPercentiles summarizes a latency distribution.
+type LatencyPercentiles struct {
+	P50Millis  int64 `json:"p50Millis"`
+	P90Millis  int64 `json:"p90Millis"`
+	P99Millis  int64 `json:"p99Millis"`
+	P999Millis int64 `json:"p999Millis"`
+}
+
+// LatencyMetric selects which latency measurement a calculator thresholds on.
+type LatencyMetric string
+
+const (
+	// LatencyMetricService measures latency from when each request was actually sent.
+	LatencyMetricService LatencyMetric = "service"
+	// LatencyMetricCorrected measures latency from each request's scheduled start time, which corrects for
+	// coordinated omission when the target stalls.
+	LatencyMetricCorrected LatencyMetric = "corrected"
+)
+
+func (l LoadObservation) P99For(metric LatencyMetric) int64 {
+	if metric == LatencyMetricCorrected {
+		return l.P99CorrectedLatencyMillis
+	}
+	return l.P99LatencyMillis
 }
 
 func (l LoadObservation) TimeoutRatio() float64 {
+	return l.ratio(l.TimeoutCount)
+}
+
+// AssertionFailureRatio is the share of requests whose responses arrived but failed their expectations.
+func (l LoadObservation) AssertionFailureRatio() float64 {
+	return l.ratio(l.AssertionFailureCount)
+}
+
+func (l LoadObservation) ratio(count int) float64 {
 	denominator := l.CompletedRequests
 	if denominator <= 0 {
 		denominator = l.PlannedRequests
@@ -30,7 +126,11 @@ func (l LoadObservation) TimeoutRatio() float64 {
 	if denominator <= 0 {
 		return 0
 	}
-	return float64(l.TimeoutCount) / float64(denominator)
+	return float64(count) / float64(denominator)
+}
+
+func NewConstantLoadCalculator(rps int) LoadCalculator {
+	return &ConstantLoadCalculator{rps: max(rps, 0)}
 }
 
 func NewStepFunctionLoadCalculator(minRps int, maxRps int, stepSize int) LoadCalculator {
@@ -46,6 +146,16 @@ func NewLogarithmicLoadCalculator(minRps int, maxRps int) LoadCalculator {
 }
 
 func NewAdaptiveExponentialLoadCalculator(minRps int, maxRps int, maxLatencyMillis int64) LoadCalculator {
+	return NewAdaptiveExponentialLoadCalculatorWithMetric(minRps, maxRps, maxLatencyMillis, LatencyMetricService)
+}
+
+func NewAdaptiveExponentialLoadCalculatorWithMetric(minRps int,
+	maxRps int,
+	maxLatencyMillis int64,
+	latencyMetric LatencyMetric) LoadCalculator {
+	if latencyMetric == "" {
+		latencyMetric = LatencyMetricService
+	}
 	if minRps < 0 {
 		minRps = 0
 	}
@@ -63,6 +173,7 @@ func NewAdaptiveExponentialLoadCalculator(minRps int, maxRps int, maxLatencyMill
 		minRps:                 minRps,
 		maxRps:                 maxRps,
 		maxLatencyMillis:       maxLatencyMillis,
+		latencyMetric:          latencyMetric,
 		recoveryRps:            recoveryRps,
 		minBinaryGranularity:   10,
 		phase:                  adaptivePhaseRamp,
@@ -73,16 +184,35 @@ func NewAdaptiveExponentialLoadCalculator(minRps int, maxRps int, maxLatencyMill
 	}
 }
 
+type ConstantLoadCalculator struct {
+	rps int
+}
+
+func (c *ConstantLoadCalculator) Next() int {
+	return c.rps
+}
+
+// Settled is always true; a constant load has nothing to converge on.
+func (c *ConstantLoadCalculator) Settled() bool {
+	return true
+}
+
 type StepFunctionLoadCalculator struct {
 	minRps   int
 	maxRps   int
 	stepSize int
 
 	currRps int
+	settled bool
+}
+
+func (s *StepFunctionLoadCalculator) Settled() bool {
+	return s.settled
 }
 
 func (s *StepFunctionLoadCalculator) Next() int {
 	n := s.currRps
+	s.settled = n >= s.maxRps
 	if s.currRps+s.stepSize > s.maxRps {
 		s.currRps = s.maxRps
 	} else {
@@ -96,10 +226,16 @@ type ExponentialFunctionLoadCalculator struct {
 	maxRps  int
 	factor  int
 	currRps int
+	settled bool
+}
+
+func (e *ExponentialFunctionLoadCalculator) Settled() bool {
+	return e.settled
 }
 
 func (e *ExponentialFunctionLoadCalculator) Next() int {
 	n := e.currRps
+	e.settled = n >= e.maxRps
 	if e.currRps*e.factor > e.maxRps {
 		e.currRps = e.maxRps
 	} else {
@@ -120,6 +256,7 @@ type AdaptiveExponentialLoadCalculator struct {
 	minRps           int
 	maxRps           int
 	maxLatencyMillis int64
+	latencyMetric    LatencyMetric
 	recoveryRps      int
 
 	minBinaryGranularity int
@@ -137,6 +274,11 @@ func (a *AdaptiveExponentialLoadCalculator) Next() int {
 	return a.nextRps
 }
 
+// Settled reports whether the calculator has found the highest sustainable load and is holding it.
+func (a *AdaptiveExponentialLoadCalculator) Settled() bool {
+	return a.phase == adaptivePhaseSteady
+}
+
 func (a *AdaptiveExponentialLoadCalculator) Observe(observation LoadObservation) {
 	failed := a.thresholdExceeded(observation)
 
@@ -202,10 +344,12 @@ func (a *AdaptiveExponentialLoadCalculator) Observe(observation LoadObservation)
 }
 
 func (a *AdaptiveExponentialLoadCalculator) thresholdExceeded(observation LoadObservation) bool {
-	if observation.TimeoutRatio() >= 0.5 {
+	// A response that fails its expectations is as unusable as no response at all, e.g. a 200 carrying an
+	// error payload from an overloaded target.
+	if observation.TimeoutRatio()+observation.AssertionFailureRatio() >= 0.5 {
 		return true
 	}
-	return a.maxLatencyMillis > 0 && observation.P99LatencyMillis > a.maxLatencyMillis
+	return a.maxLatencyMillis > 0 && observation.P99For(a.latencyMetric) > a.maxLatencyMillis
 }
 
 func (a *AdaptiveExponentialLoadCalculator) nextRampRps(previous int) int {
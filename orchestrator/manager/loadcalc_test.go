@@ -109,3 +109,48 @@ func TestAdaptiveExponentialCalculatorTimeoutMode(t *testing.T) {
 		t.Fatalf("expected recovery at 1 due to 50%% timeout threshold, got %d", got)
 	}
 }
+
+func TestAdaptiveExponentialCalculatorCountsAssertionFailures(t *testing.T) {
+	calc := NewAdaptiveExponentialLoadCalculator(10, 500, 0).(FeedbackLoadCalculator)
+	if got := calc.Next(); got != 10 {
+		t.Fatalf("expected first rate 10, got %d", got)
+	}
+
+	calc.Observe(LoadObservation{TotalRPS: 10, CompletedRequests: 10, FailureCount: 2, AssertionFailureCount: 2})
+	if got := calc.Next(); got != 20 {
+		t.Fatalf("expected ramp to 20, got %d", got)
+	}
+
+	calc.Observe(LoadObservation{
+		TotalRPS:              20,
+		CompletedRequests:     20,
+		FailureCount:          10,
+		TimeoutCount:          4,
+		AssertionFailureCount: 6,
+	})
+	if got := calc.Next(); got != 1 {
+		t.Fatalf("expected recovery at 1 once timeouts and assertion failures reach 50%%, got %d", got)
+	}
+}
+
+func TestAdaptiveExponentialCalculatorThresholdsOnCorrectedLatency(t *testing.T) {
+	service := NewAdaptiveExponentialLoadCalculator(10, 500, 200).(FeedbackLoadCalculator)
+	corrected := NewAdaptiveExponentialLoadCalculatorWithMetric(10, 500, 200, LatencyMetricCorrected).(FeedbackLoadCalculator)
+
+	observation := LoadObservation{
+		TotalRPS:                  10,
+		CompletedRequests:         10,
+		SuccessCount:              10,
+		P99LatencyMillis:          50,
+		P99CorrectedLatencyMillis: 800,
+	}
+	service.Observe(observation)
+	corrected.Observe(observation)
+
+	if got := service.Next(); got != 20 {
+		t.Fatalf("expected service-latency calculator to ramp to 20, got %d", got)
+	}
+	if got := corrected.Next(); got != 1 {
+		t.Fatalf("expected corrected-latency calculator to enter recovery at 1, got %d", got)
+	}
+}
@@ -0,0 +1,162 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/PeladoCollado/imager/orchestrator/logger"
+	"github.com/PeladoCollado/imager/types"
+)
+
+// Phase is one stage of a PhasedLoadCalculator. A phase ends once any of its exit conditions is met, or when its
+// calculator is exhausted. A phase without exit conditions runs until the run itself ends.
+type Phase struct {
+	Name       string
+	Calculator LoadCalculator
+	Source     types.RequestSource
+	// Warmup rounds are reported but excluded from the run's summary totals.
+	Warmup bool
+
+	// Duration is the wall-clock time the phase runs for, measured from its first round.
+	Duration time.Duration
+	// Rounds is the number of rounds the phase dispatches.
+	Rounds int
+	// UntilSettled ends the phase once its calculator reports that it has settled.
+	UntilSettled bool
+}
+
+func (p Phase) hasExitCondition() bool {
+	return p.Duration > 0 || p.Rounds > 0 || p.UntilSettled
+}
+
+// PhaseTracker is implemented by load calculators that dispatch in named phases.
+type PhaseTracker interface {
+	// CurrentPhase returns the phase that the most recent Next call belonged to.
+	CurrentPhase() (name string, warmup bool)
+}
+
+// PhasedLoadCalculator runs an ordered list of phases, each with its own calculator and request source, and is
+// exhausted after the last phase ends. It is only used from the run's schedule goroutine.
+type PhasedLoadCalculator struct {
+	phases []Phase
+
+	current      int
+	phaseStarted time.Time
+	phaseRounds  int
+}
+
+func NewPhasedLoadCalculator(phases []Phase) (*PhasedLoadCalculator, error) {
+	if len(phases) == 0 {
+		return nil, fmt.Errorf("at least one phase is required")
+	}
+	names := make(map[string]struct{}, len(phases))
+	for i, phase := range phases {
+		if phase.Name == "" {
+			return nil, fmt.Errorf("phase %d requires a name", i)
+		}
+		if _, ok := names[phase.Name]; ok {
+			return nil, fmt.Errorf("duplicate phase name %s", phase.Name)
+		}
+		names[phase.Name] = struct{}{}
+		if phase.Calculator == nil || phase.Source == nil {
+			return nil, fmt.Errorf("phase %s requires a load calculator and a request source", phase.Name)
+		}
+		if i < len(phases)-1 && !phase.hasExitCondition() {
+			return nil, fmt.Errorf("phase %s requires a duration, rounds or untilSettled since it is not the last phase",
+				phase.Name)
+		}
+	}
+	return &PhasedLoadCalculator{phases: phases}, nil
+}
+
+func (p *PhasedLoadCalculator) Next() int {
+	p.advance()
+	if p.current >= len(p.phases) {
+		return 0
+	}
+	if p.phaseRounds == 0 {
+		p.phaseStarted = time.Now()
+		logger.Logger.Info("Starting load phase", p.phases[p.current].Name)
+	}
+	p.phaseRounds++
+	return p.phases[p.current].Calculator.Next()
+}
+
+// Observe forwards a round to the calculator of the phase that dispatched it.
+func (p *PhasedLoadCalculator) Observe(observation LoadObservation) {
+	for _, phase := range p.phases {
+		if phase.Name != observation.Phase {
+			continue
+		}
+		if feedbackCalculator, ok := phase.Calculator.(FeedbackLoadCalculator); ok {
+			feedbackCalculator.Observe(observation)
+		}
+		return
+	}
+}
+
+func (p *PhasedLoadCalculator) Exhausted() bool {
+	p.advance()
+	return p.current >= len(p.phases)
+}
+
+func (p *PhasedLoadCalculator) CurrentPhase() (string, bool) {
+	if p.current >= len(p.phases) {
+		return "", false
+	}
+	phase := p.phases[p.current]
+	return phase.Name, phase.Warmup
+}
+
+// RequestSource returns a source that draws from the current phase's request source.
+func (p *PhasedLoadCalculator) RequestSource() types.RequestSource {
+	return &phasedRequestSource{plan: p}
+}
+
+// advance skips past every phase whose exit condition has been met.
+func (p *PhasedLoadCalculator) advance() {
+	for p.current < len(p.phases) && p.phaseFinished(p.phases[p.current]) {
+		p.current++
+		p.phaseRounds = 0
+	}
+}
+
+func (p *PhasedLoadCalculator) phaseFinished(phase Phase) bool {
+	if exhaustible, ok := phase.Calculator.(ExhaustibleLoadCalculator); ok && exhaustible.Exhausted() {
+		return true
+	}
+	if p.phaseRounds == 0 {
+		return false
+	}
+	if phase.Rounds > 0 && p.phaseRounds >= phase.Rounds {
+		return true
+	}
+	if phase.Duration > 0 && time.Since(p.phaseStarted) >= phase.Duration {
+		return true
+	}
+	if settling, ok := phase.Calculator.(SettlingLoadCalculator); ok && phase.UntilSettled && settling.Settled() {
+		return true
+	}
+	return false
+}
+
+type phasedRequestSource struct {
+	plan *PhasedLoadCalculator
+}
+
+func (s *phasedRequestSource) Next() (types.RequestSpec, error) {
+	plan := s.plan
+	if plan.current >= len(plan.phases) {
+		return types.RequestSpec{}, fmt.Errorf("all phases have ended")
+	}
+	return plan.phases[plan.current].Source.Next()
+}
+
+func (s *phasedRequestSource) Reset() error {
+	for _, phase := range s.plan.phases {
+		if err := phase.Source.Reset(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/PeladoCollado/imager/types"
+)
+
+type namedSource struct {
+	path string
+}
+
+func (n *namedSource) Next() (types.RequestSpec, error) {
+	return types.RequestSpec{Method: "GET", Path: n.path}, nil
+}
+
+func (n *namedSource) Reset() error {
+	return nil
+}
+
+func TestPhasedLoadCalculatorAdvancesThroughPhases(t *testing.T) {
+	warmupCalc := &feedbackCalc{value: 5}
+	plan, err := NewPhasedLoadCalculator([]Phase{
+		{Name: "warmup", Calculator: warmupCalc, Source: &namedSource{path: "/warmup"}, Warmup: true, Rounds: 2},
+		{Name: "ramp", Calculator: NewStepFunctionLoadCalculator(10, 30, 10), Source: &namedSource{path: "/ramp"},
+			UntilSettled: true},
+		{Name: "soak", Calculator: NewConstantLoadCalculator(30), Source: &namedSource{path: "/soak"}, Rounds: 1},
+	})
+	if err != nil {
+		t.Fatalf("unexpected plan error: %v", err)
+	}
+	source := plan.RequestSource()
+
+	expected := []struct {
+		load   int
+		phase  string
+		warmup bool
+		path   string
+	}{
+		{load: 5, phase: "warmup", warmup: true, path: "/warmup"},
+		{load: 5, phase: "warmup", warmup: true, path: "/warmup"},
+		{load: 10, phase: "ramp", path: "/ramp"},
+		{load: 20, phase: "ramp", path: "/ramp"},
+		{load: 30, phase: "ramp", path: "/ramp"},
+		{load: 30, phase: "soak", path: "/soak"},
+	}
+	for i, step := range expected {
+		if plan.Exhausted() {
+			t.Fatalf("round %d: expected plan not to be exhausted", i)
+		}
+		if got := plan.Next(); got != step.load {
+			t.Fatalf("round %d: expected load %d, got %d", i, step.load, got)
+		}
+		phase, warmup := plan.CurrentPhase()
+		if phase != step.phase || warmup != step.warmup {
+			t.Fatalf("round %d: expected phase %s (warmup=%t), got %s (warmup=%t)", i, step.phase, step.warmup,
+				phase, warmup)
+		}
+		request, err := source.Next()
+		if err != nil || request.Path != step.path {
+			t.Fatalf("round %d: expected request for %s, got %+v (%v)", i, step.path, request, err)
+		}
+	}
+	if !plan.Exhausted() {
+		t.Fatalf("expected plan to be exhausted after the last phase")
+	}
+
+	plan.Observe(LoadObservation{RoundID: "round-1", Phase: "warmup"})
+	plan.Observe(LoadObservation{RoundID: "round-2", Phase: "soak"})
+	if len(warmupCalc.observations) != 1 || warmupCalc.observations[0].RoundID != "round-1" {
+		t.Fatalf("expected only the warmup observation to reach the warmup calculator, got %+v",
+			warmupCalc.observations)
+	}
+}
+
+func TestPhasedLoadCalculatorEndsPhaseAfterDuration(t *testing.T) {
+	plan, err := NewPhasedLoadCalculator([]Phase{
+		{Name: "short", Calculator: NewConstantLoadCalculator(1), Source: &namedSource{}, Duration: 20 * time.Millisecond},
+		{Name: "last", Calculator: NewConstantLoadCalculator(2), Source: &namedSource{}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected plan error: %v", err)
+	}
+	if got := plan.Next(); got != 1 {
+		t.Fatalf("expected first phase load 1, got %d", got)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if got := plan.Next(); got != 2 {
+		t.Fatalf("expected second phase load 2 after the duration elapsed, got %d", got)
+	}
+	if plan.Exhausted() {
+		t.Fatalf("expected a last phase without exit conditions to run until the run ends")
+	}
+}
+
+func TestNewPhasedLoadCalculatorValidatesPhases(t *testing.T) {
+	calc := NewConstantLoadCalculator(1)
+	source := &namedSource{}
+	tests := map[string][]Phase{
+		"no phases":      nil,
+		"missing name":   {{Calculator: calc, Source: source}},
+		"duplicate name": {{Name: "a", Calculator: calc, Source: source, Rounds: 1}, {Name: "a", Calculator: calc, Source: source}},
+		"missing exit":   {{Name: "a", Calculator: calc, Source: source}, {Name: "b", Calculator: calc, Source: source}},
+		"missing source": {{Name: "a", Calculator: calc}},
+	}
+	for name, phases := range tests {
+		if _, err := NewPhasedLoadCalculator(phases); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
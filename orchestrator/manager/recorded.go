@@ -0,0 +1,83 @@
+package manager
+
+import (
+	"errors"
+	"github.com/PeladoCollado/imager/orchestrator/logger"
+	"github.com/PeladoCollado/imager/types"
+	"time"
+)
+
+// recordedTimeline lays the requests of a source out on one continuous timeline for the recorded arrival process.
+// Each request is placed its DelayMillis after the previous one, or one request interval at the round's rate after
+// it when it has no delay. Every round takes the requests that fall inside its interval, and a request that falls
+// past the end waits for the round it falls in, so recorded gaps are kept across rounds and jobs.
+type recordedTimeline struct {
+	types.RequestSource
+
+	// pending is the next request, already taken from the source, at pendingAt from the start of the current
+	// round. last is the position of the previously dispatched request in the same frame.
+	pending   *types.RequestSpec
+	pendingAt time.Duration
+	last      time.Duration
+	started   bool
+}
+
+func newRecordedTimeline(source types.RequestSource) *recordedTimeline {
+	return &recordedTimeline{RequestSource: source}
+}
+
+// take returns the requests of the next round of the given interval and their offsets from the round start, up
+// to limit requests unless limit is negative. The error is the source's, with malformed records skipped; the
+// requests taken before it are still returned.
+func (r *recordedTimeline) take(interval time.Duration, rate int, limit int) ([]types.RequestSpec,
+	[]time.Duration,
+	error) {
+	var requests []types.RequestSpec
+	var offsets []time.Duration
+	defer r.advance(interval)
+	for limit < 0 || len(requests) < limit {
+		if r.pending == nil {
+			next, err := r.Next()
+			var recordErr *types.RecordError
+			if errors.As(err, &recordErr) {
+				logger.Logger.Warn("Skipping malformed request record", err)
+				continue
+			}
+			if err != nil {
+				return requests, offsets, err
+			}
+			gap := time.Duration(next.DelayMillis) * time.Millisecond
+			if next.DelayMillis <= 0 {
+				if rate <= 0 {
+					// Without a rate there is no spacing for the request; it waits for a round with one.
+					gap = interval
+				} else {
+					gap = time.Second / time.Duration(rate)
+				}
+			}
+			r.pending = &next
+			r.pendingAt = r.last + gap
+			if !r.started || r.pendingAt < 0 {
+				// The first request starts the timeline, and one held back past its round goes out first.
+				r.pendingAt = 0
+				r.started = true
+			}
+		}
+		if r.pendingAt >= interval {
+			break
+		}
+		requests = append(requests, *r.pending)
+		offsets = append(offsets, r.pendingAt)
+		r.last = r.pendingAt
+		r.pending = nil
+	}
+	return requests, offsets, nil
+}
+
+// advance moves the timeline's frame to the start of the next round.
+func (r *recordedTimeline) advance(interval time.Duration) {
+	r.last -= interval
+	if r.pending != nil {
+		r.pendingAt -= interval
+	}
+}
@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"context"
+	"github.com/PeladoCollado/imager/types"
+	"slices"
+	"testing"
+	"time"
+)
+
+// delaySource replays requests with the given recorded delays, then reports that it is exhausted.
+type delaySource struct {
+	delays []int64
+	next   int
+}
+
+func (d *delaySource) Next() (types.RequestSpec, error) {
+	if d.next >= len(d.delays) {
+		return types.RequestSpec{}, types.ErrSourceExhausted
+	}
+	d.next++
+	return types.RequestSpec{Method: "GET", Path: "/resource", DelayMillis: d.delays[d.next-1]}, nil
+}
+
+func (d *delaySource) Reset() error {
+	d.next = 0
+	return nil
+}
+
+func TestRecordedTimelineKeepsGapsAcrossRounds(t *testing.T) {
+	timeline := newRecordedTimeline(&delaySource{delays: []int64{0, 600, 300, 1500, 0, 200}})
+
+	offsetsOf := func(limit int) ([]time.Duration, error) {
+		_, offsets, err := timeline.take(time.Second, 4, limit)
+		return offsets, err
+	}
+	// Requests at 0, 600ms, 900ms, 2.4s, then 2.65s (no delay, spaced at 4 rps) and 2.85s unless held back.
+	offsets, err := offsetsOf(-1)
+	if err != nil || !slices.Equal(offsets, []time.Duration{0, 600 * time.Millisecond, 900 * time.Millisecond}) {
+		t.Fatalf("unexpected first round offsets %v (%v)", offsets, err)
+	}
+	if offsets, err = offsetsOf(-1); err != nil || len(offsets) != 0 {
+		t.Fatalf("expected a round without requests in the recorded gap, got %v (%v)", offsets, err)
+	}
+	if offsets, err = offsetsOf(1); err != nil || !slices.Equal(offsets, []time.Duration{400 * time.Millisecond}) {
+		t.Fatalf("expected the delayed request 400ms into the third round, got %v (%v)", offsets, err)
+	}
+	offsets, err = offsetsOf(-1)
+	if !slices.Equal(offsets, []time.Duration{0, 200 * time.Millisecond}) {
+		t.Fatalf("expected the request held back by the limit to go first, got %v", offsets)
+	}
+	if err != types.ErrSourceExhausted {
+		t.Fatalf("expected the source to be exhausted, got %v", err)
+	}
+}
+
+func TestDispatchTickDealsRecordedRequestsWithOffsets(t *testing.T) {
+	ResetExecutors()
+	ResetRoundReports()
+	t.Cleanup(ResetExecutors)
+	t.Cleanup(ResetRoundReports)
+
+	AddExecutor("executor-1", 2)
+	exec := GetExecutor("executor-1")
+	exec.WorkChan = make(chan []types.Job, 1)
+
+	source := newRecordedTimeline(&delaySource{delays: []int64{0, 100, 100, 100, 2000}})
+	opts := ScheduleOptions{
+		Interval:    time.Second,
+		JobDuration: time.Second,
+		Arrival:     types.ArrivalProcess{Type: types.ArrivalRecorded},
+	}
+	dispatchTick(context.Background(), startedRun(t, RunLimits{}), &staticCalc{value: 1}, source,
+		&fakeResolver{targets: []string{"http://10.0.0.1:8080"}}, nil, opts)
+
+	jobs := <-exec.WorkChan
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+	if !slices.Equal(jobs[0].OffsetsMillis, []int64{0, 200}) || !slices.Equal(jobs[1].OffsetsMillis, []int64{100, 300}) {
+		t.Fatalf("expected the round's requests dealt out in turn, got %v and %v",
+			jobs[0].OffsetsMillis, jobs[1].OffsetsMillis)
+	}
+	if len(jobs[0].Requests) != 2 || len(jobs[1].Requests) != 2 {
+		t.Fatalf("expected 2 requests per job, got %d and %d", len(jobs[0].Requests), len(jobs[1].Requests))
+	}
+}
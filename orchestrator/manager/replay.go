@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"slices"
+	"time"
+)
+
+// ReplayLoadCalculator reproduces the request rate of a recorded traffic log. Each round covers the next
+// interval*speedup of log time, so a speedup of 2 replays an hour of traffic in half an hour at twice the rate. The
+// rate is the one that sends the requests logged in it over a job, which sends for the whole seconds of
+// jobDuration. Fractional rates carry over to the next round and the last round rounds up, so the run sends at
+// least as many requests as were logged. The calculator is exhausted once the end of the log has been dispatched.
+type ReplayLoadCalculator struct {
+	timestamps []time.Time
+	jobSeconds int
+	window     time.Duration
+
+	round int
+	next  int
+	carry float64
+}
+
+// NewReplayLoadCalculator replays the rate of requests logged at timestamps, in rounds of the given interval that
+// dispatch jobs of jobDuration.
+func NewReplayLoadCalculator(timestamps []time.Time,
+	interval time.Duration,
+	jobDuration time.Duration,
+	speedup float64) *ReplayLoadCalculator {
+	sorted := slices.SortedFunc(slices.Values(timestamps), time.Time.Compare)
+	return &ReplayLoadCalculator{
+		timestamps: sorted,
+		jobSeconds: max(int(jobDuration.Seconds()), 1),
+		window:     time.Duration(float64(interval) * speedup),
+	}
+}
+
+func (r *ReplayLoadCalculator) Next() int {
+	if r.Exhausted() {
+		return 0
+	}
+	r.round++
+	end := r.timestamps[0].Add(time.Duration(r.round) * r.window)
+	count := 0
+	for r.next < len(r.timestamps) && r.timestamps[r.next].Before(end) {
+		r.next++
+		count++
+	}
+	rate := float64(count)/float64(r.jobSeconds) + r.carry
+	rps := int(rate)
+	r.carry = rate - float64(rps)
+	if r.Exhausted() && r.carry > 0 {
+		// Nothing is left to carry into, so the last requests go out in this round.
+		rps++
+		r.carry = 0
+	}
+	return rps
+}
+
+// Exhausted reports whether every logged request has been dispatched.
+func (r *ReplayLoadCalculator) Exhausted() bool {
+	return r.next >= len(r.timestamps)
+}
@@ -0,0 +1,98 @@
+package manager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplayLoadCalculator(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var timestamps []time.Time
+	// 3 requests in the first second, none in the second and 6 in the third, logged out of order.
+	for _, offset := range []time.Duration{2500, 0, 2000, 100, 2100, 900, 2200, 2300, 2400} {
+		timestamps = append(timestamps, start.Add(offset*time.Millisecond))
+	}
+
+	calc := NewReplayLoadCalculator(timestamps, time.Second, time.Second, 1)
+	for i, expected := range []int{3, 0, 6} {
+		if calc.Exhausted() {
+			t.Fatalf("expected round %d before exhaustion", i)
+		}
+		if got := calc.Next(); got != expected {
+			t.Fatalf("expected round %d at %d rps, got %d", i, expected, got)
+		}
+	}
+	if !calc.Exhausted() {
+		t.Fatalf("expected the calculator to be exhausted after the log")
+	}
+}
+
+func TestReplayLoadCalculatorSpeedup(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var timestamps []time.Time
+	for i := 0; i < 10; i++ {
+		timestamps = append(timestamps, start.Add(time.Duration(i)*400*time.Millisecond))
+	}
+
+	// Each 1s round covers 2s of the log, replaying 5 logged requests.
+	calc := NewReplayLoadCalculator(timestamps, time.Second, time.Second, 2)
+	if got := calc.Next(); got != 5 {
+		t.Fatalf("expected 5 rps, got %d", got)
+	}
+	if got := calc.Next(); got != 5 {
+		t.Fatalf("expected 5 rps, got %d", got)
+	}
+	if !calc.Exhausted() {
+		t.Fatalf("expected the calculator to be exhausted")
+	}
+
+	// Half-second rounds at 2.5x cover 1.25s of the log, sending 4 requests in each 1s job.
+	calc = NewReplayLoadCalculator(timestamps, 500*time.Millisecond, time.Second, 2.5)
+	if got := calc.Next(); got != 4 {
+		t.Fatalf("expected 4 rps, got %d", got)
+	}
+}
+
+func TestReplayLoadCalculatorCarriesFractionalRates(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	timestamps := []time.Time{start, start.Add(1500 * time.Millisecond), start.Add(2500 * time.Millisecond),
+		start.Add(4500 * time.Millisecond)}
+
+	// Every 2s round dispatches for 2s, so one request a round is half a request per second.
+	calc := NewReplayLoadCalculator(timestamps, 2*time.Second, 2*time.Second, 1)
+	for i, expected := range []int{1, 0, 1} {
+		if got := calc.Next(); got != expected {
+			t.Fatalf("expected round %d at %d rps, got %d", i, expected, got)
+		}
+	}
+}
+
+func TestReplayLoadCalculatorRateCoversJobDuration(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var timestamps []time.Time
+	for i := 0; i < 20; i++ {
+		timestamps = append(timestamps, start.Add(time.Duration(i)*100*time.Millisecond))
+	}
+
+	// A 1s round logs 10 requests, which a 5s job sends at 2 rps.
+	calc := NewReplayLoadCalculator(timestamps, time.Second, 5*time.Second, 1)
+	if got := calc.Next(); got != 2 {
+		t.Fatalf("expected 10 requests over a 5s job to be 2 rps, got %d", got)
+	}
+}
+
+func TestReplayLoadCalculatorSendsTheLastCarry(t *testing.T) {
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	timestamps := []time.Time{start, start.Add(500 * time.Millisecond), start.Add(1500 * time.Millisecond)}
+
+	calc := NewReplayLoadCalculator(timestamps, time.Second, 2*time.Second, 1)
+	if got := calc.Next(); got != 1 {
+		t.Fatalf("expected 2 requests over a 2s job to be 1 rps, got %d", got)
+	}
+	if got := calc.Next(); got != 1 {
+		t.Fatalf("expected the last half request per second to be rounded up, got %d", got)
+	}
+	if !calc.Exhausted() {
+		t.Fatalf("expected the calculator to be exhausted")
+	}
+}
@@ -2,10 +2,10 @@ package manager
 
 import (
 	"fmt"
-	"slices"
 	"sync"
 	"time"
 
+	"github.com/PeladoCollado/imager/metrics"
 	"github.com/PeladoCollado/imager/types"
 )
 
@@ -21,12 +21,36 @@ type roundAggregate struct {
 	FailureCount      int
 	TimeoutCount      int
 	CompletedRequests int
-	LatencyMillis     []int64
+	ThroughputRPS     float64
+
+	AssertionFailureCount int
+	Errors                map[string]int
+
+	Latency          types.LatencyHistogram
+	CorrectedLatency types.LatencyHistogram
+	Phases           types.PhaseLatencies
+
+	Names map[string]*namedAggregate
+	Steps map[string]*namedAggregate
 
 	ReceivedJobIDs map[string]struct{}
 	CreatedAt      time.Time
 }
 
+// maxRoundNames bounds the per-name and per-step breakdowns of a round; further names are folded into
+// metrics.OverflowRequestName.
+const maxRoundNames = 100
+
+type namedAggregate struct {
+	CompletedRequests int
+	SuccessCount      int
+	FailureCount      int
+	TimeoutCount      int
+	Latency           types.LatencyHistogram
+
+	AssertionFailureCount int
+}
+
 type roundTracker struct {
 	lock   sync.Mutex
 	rounds map[string]*roundAggregate
@@ -49,7 +73,6 @@ func RegisterRound(roundID string, totalRPS int, expectedReports int, plannedReq
 			RoundID:         roundID,
 			ReceivedJobIDs:  make(map[string]struct{}),
 			CreatedAt:       time.Now(),
-			LatencyMillis:   make([]int64, 0),
 			ExpectedReports: expectedReports,
 		}
 		reportsTracker.rounds[roundID] = aggregate
@@ -81,7 +104,6 @@ func RecordJobReport(report types.JobReport) error {
 			RoundID:        report.RoundID,
 			ReceivedJobIDs: make(map[string]struct{}),
 			CreatedAt:      time.Now(),
-			LatencyMillis:  make([]int64, 0),
 		}
 		reportsTracker.rounds[report.RoundID] = aggregate
 		reportsTracker.order = append(reportsTracker.order, report.RoundID)
@@ -96,14 +118,52 @@ func RecordJobReport(report types.JobReport) error {
 	aggregate.SuccessCount += report.SuccessCount
 	aggregate.FailureCount += report.FailureCount
 	aggregate.TimeoutCount += report.TimeoutCount
+	aggregate.AssertionFailureCount += report.AssertionFailureCount
+	aggregate.Errors = addErrorCounts(aggregate.Errors, report.Errors)
 	aggregate.CompletedRequests += report.CompletedRequests
+	aggregate.ThroughputRPS += report.ThroughputRPS
 	if !aggregate.HasRoundPlan {
 		aggregate.PlannedRequests += max(report.PlannedRequests, 0)
 	}
-	aggregate.LatencyMillis = append(aggregate.LatencyMillis, report.LatencyMillis...)
+	aggregate.Latency.Merge(report.Latency)
+	aggregate.CorrectedLatency.Merge(report.CorrectedLatency)
+	aggregate.Phases.Merge(report.Phases)
+	for name, results := range report.Names {
+		aggregate.Names = addNamedResults(aggregate.Names, name, results)
+	}
+	for step, results := range report.Steps {
+		aggregate.Steps = addNamedResults(aggregate.Steps, step, results)
+	}
 	return nil
 }
 
+// addNamedResults adds results to the aggregate for name, allocating aggregates on first use.
+func addNamedResults(aggregates map[string]*namedAggregate,
+	name string,
+	results types.NamedResults) map[string]*namedAggregate {
+	if aggregates == nil {
+		aggregates = make(map[string]*namedAggregate)
+	}
+	named, ok := aggregates[name]
+	if !ok {
+		if len(aggregates) >= maxRoundNames {
+			name = metrics.OverflowRequestName
+			named, ok = aggregates[name]
+		}
+		if !ok {
+			named = &namedAggregate{}
+			aggregates[name] = named
+		}
+	}
+	named.CompletedRequests += results.CompletedRequests
+	named.SuccessCount += results.SuccessCount
+	named.FailureCount += results.FailureCount
+	named.TimeoutCount += results.TimeoutCount
+	named.AssertionFailureCount += results.AssertionFailureCount
+	named.Latency.Merge(results.Latency)
+	return aggregates
+}
+
 func DrainReadyObservations(staleAfter time.Duration) []LoadObservation {
 	if staleAfter <= 0 {
 		staleAfter = 2 * time.Second
@@ -145,43 +205,90 @@ func ResetRoundReports() {
 }
 
 func loadObservationFromAggregate(aggregate *roundAggregate) LoadObservation {
-	latencies := append([]int64(nil), aggregate.LatencyMillis...)
-	slices.Sort(latencies)
-
 	completed := aggregate.CompletedRequests
 	success := aggregate.SuccessCount
 	failures := aggregate.FailureCount
 	timeouts := aggregate.TimeoutCount
+	errorCounts := aggregate.Errors
 	if aggregate.ReceivedReports == 0 && aggregate.PlannedRequests > 0 {
 		// A full round with zero reports is treated as complete timeout failure.
 		completed = aggregate.PlannedRequests
 		success = 0
 		failures = aggregate.PlannedRequests
 		timeouts = aggregate.PlannedRequests
+		errorCounts = map[string]int{metrics.ErrorCategoryTimeout: aggregate.PlannedRequests}
 	}
 
 	return LoadObservation{
-		RoundID:           aggregate.RoundID,
-		TotalRPS:          aggregate.TotalRPS,
-		PlannedRequests:   aggregate.PlannedRequests,
-		CompletedRequests: completed,
-		SuccessCount:      success,
-		FailureCount:      failures,
-		TimeoutCount:      timeouts,
-		P99LatencyMillis:  p99Latency(latencies),
+		RoundID:                   aggregate.RoundID,
+		TotalRPS:                  aggregate.TotalRPS,
+		PlannedRequests:           aggregate.PlannedRequests,
+		CompletedRequests:         completed,
+		SuccessCount:              success,
+		FailureCount:              failures,
+		TimeoutCount:              timeouts,
+		AchievedRPS:               aggregate.ThroughputRPS,
+		P99LatencyMillis:          aggregate.Latency.QuantileMillis(0.99),
+		Latency:                   latencyPercentiles(aggregate.Latency),
+		P99CorrectedLatencyMillis: aggregate.CorrectedLatency.QuantileMillis(0.99),
+		CorrectedLatency:          latencyPercentiles(aggregate.CorrectedLatency),
+		AssertionFailureCount:     aggregate.AssertionFailureCount,
+		Errors:                    errorCounts,
+		Phases:                    phaseBreakdown(aggregate.Phases),
+		Names:                     namedObservations(aggregate.Names),
+		Steps:                     namedObservations(aggregate.Steps),
+	}
+}
+
+func namedObservations(aggregates map[string]*namedAggregate) map[string]NamedObservation {
+	if len(aggregates) == 0 {
+		return nil
+	}
+	observations := make(map[string]NamedObservation, len(aggregates))
+	for name, named := range aggregates {
+		observations[name] = NamedObservation{
+			CompletedRequests: named.CompletedRequests,
+			SuccessCount:      named.SuccessCount,
+			FailureCount:      named.FailureCount,
+			TimeoutCount:      named.TimeoutCount,
+			Latency:           latencyPercentiles(named.Latency),
+
+			AssertionFailureCount: named.AssertionFailureCount,
+		}
 	}
+	return observations
 }
 
-func p99Latency(sortedLatencies []int64) int64 {
-	if len(sortedLatencies) == 0 {
-		return 0
+// addErrorCounts adds per-category error counts into total, allocating it on first use.
+func addErrorCounts(total map[string]int, counts map[string]int) map[string]int {
+	if len(counts) == 0 {
+		return total
 	}
-	index := (len(sortedLatencies)*99 + 99) / 100
-	if index <= 0 {
-		index = 1
+	if total == nil {
+		total = make(map[string]int, len(counts))
 	}
-	if index > len(sortedLatencies) {
-		index = len(sortedLatencies)
+	for category, count := range counts {
+		total[category] += count
+	}
+	return total
+}
+
+func phaseBreakdown(phases types.PhaseLatencies) PhaseBreakdown {
+	return PhaseBreakdown{
+		NewConnections: phases.Connect.Count,
+		DNS:            latencyPercentiles(phases.DNS),
+		Connect:        latencyPercentiles(phases.Connect),
+		TLSHandshake:   latencyPercentiles(phases.TLSHandshake),
+		FirstByte:      latencyPercentiles(phases.FirstByte),
+		Transfer:       latencyPercentiles(phases.Transfer),
+	}
+}
+
+func latencyPercentiles(histogram types.LatencyHistogram) LatencyPercentiles {
+	return LatencyPercentiles{
+		P50Millis:  histogram.QuantileMillis(0.50),
+		P90Millis:  histogram.QuantileMillis(0.90),
+		P99Millis:  histogram.QuantileMillis(0.99),
+		P999Millis: histogram.QuantileMillis(0.999),
 	}
-	return sortedLatencies[index-1]
 }
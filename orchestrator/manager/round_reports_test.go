@@ -1,9 +1,12 @@
 package manager
 
 import (
+	"fmt"
+	"maps"
 	"testing"
 	"time"
 
+	"github.com/PeladoCollado/imager/metrics"
 	"github.com/PeladoCollado/imager/types"
 )
 
@@ -19,7 +22,7 @@ func TestRoundReportsAggregateAndDrain(t *testing.T) {
 		PlannedRequests:   10,
 		CompletedRequests: 10,
 		SuccessCount:      10,
-		LatencyMillis:     []int64{10, 20, 30, 40, 50},
+		Latency:           latencyHistogram(10, 20, 30, 40, 50),
 	}); err != nil {
 		t.Fatalf("unexpected report error: %v", err)
 	}
@@ -31,7 +34,7 @@ func TestRoundReportsAggregateAndDrain(t *testing.T) {
 		SuccessCount:      9,
 		FailureCount:      1,
 		TimeoutCount:      1,
-		LatencyMillis:     []int64{60, 70, 80, 90, 100},
+		Latency:           latencyHistogram(60, 70, 80, 90, 100),
 	}); err != nil {
 		t.Fatalf("unexpected report error: %v", err)
 	}
@@ -53,6 +56,9 @@ func TestRoundReportsAggregateAndDrain(t *testing.T) {
 	if observation.P99LatencyMillis != 100 {
 		t.Fatalf("expected p99 latency 100ms, got %d", observation.P99LatencyMillis)
 	}
+	if observation.Latency.P50Millis != 50 || observation.Latency.P90Millis != 90 || observation.Latency.P99Millis != 100 {
+		t.Fatalf("unexpected latency percentiles %+v", observation.Latency)
+	}
 }
 
 func TestRoundReportsTreatStaleNoReportRoundAsTimeouts(t *testing.T) {
@@ -73,6 +79,9 @@ func TestRoundReportsTreatStaleNoReportRoundAsTimeouts(t *testing.T) {
 	if observation.CompletedRequests != 25 {
 		t.Fatalf("expected completed requests 25, got %d", observation.CompletedRequests)
 	}
+	if observation.Errors[metrics.ErrorCategoryTimeout] != 25 {
+		t.Fatalf("expected 25 timeout errors, got %v", observation.Errors)
+	}
 }
 
 func TestRoundReportsIgnoreDuplicateJobReports(t *testing.T) {
@@ -86,7 +95,7 @@ func TestRoundReportsIgnoreDuplicateJobReports(t *testing.T) {
 		PlannedRequests:   10,
 		CompletedRequests: 10,
 		SuccessCount:      10,
-		LatencyMillis:     []int64{15, 20},
+		Latency:           latencyHistogram(15, 20),
 	}
 	if err := RecordJobReport(report); err != nil {
 		t.Fatalf("unexpected report error: %v", err)
@@ -103,3 +112,233 @@ func TestRoundReportsIgnoreDuplicateJobReports(t *testing.T) {
 		t.Fatalf("expected completed requests 10, got %d", observations[0].CompletedRequests)
 	}
 }
+
+func TestRoundReportsAggregateCorrectedLatency(t *testing.T) {
+	ResetRoundReports()
+	t.Cleanup(ResetRoundReports)
+
+	RegisterRound("round-corrected", 10, 1, 3)
+	if err := RecordJobReport(types.JobReport{
+		JobID:             "job-1",
+		RoundID:           "round-corrected",
+		PlannedRequests:   3,
+		CompletedRequests: 3,
+		SuccessCount:      3,
+		Latency:           latencyHistogram(10, 10, 10),
+		CorrectedLatency:  latencyHistogram(10, 400, 900),
+	}); err != nil {
+		t.Fatalf("unexpected report error: %v", err)
+	}
+
+	observations := DrainReadyObservations(time.Millisecond)
+	if len(observations) != 1 {
+		t.Fatalf("expected one observation, got %d", len(observations))
+	}
+	if observations[0].P99LatencyMillis != 10 {
+		t.Fatalf("expected service p99 10ms, got %d", observations[0].P99LatencyMillis)
+	}
+	if observations[0].P99CorrectedLatencyMillis != 900 {
+		t.Fatalf("expected corrected p99 900ms, got %d", observations[0].P99CorrectedLatencyMillis)
+	}
+}
+
+func TestRoundReportsBreakDownByName(t *testing.T) {
+	ResetRoundReports()
+	t.Cleanup(ResetRoundReports)
+
+	RegisterRound("round-named", 10, 2, 4)
+	reports := []types.JobReport{
+		{
+			JobID: "job-1", RoundID: "round-named", CompletedRequests: 2, SuccessCount: 2,
+			Names: map[string]types.NamedResults{
+				"read":  {CompletedRequests: 1, SuccessCount: 1, Latency: latencyHistogram(5)},
+				"write": {CompletedRequests: 1, SuccessCount: 1, Latency: latencyHistogram(300)},
+			},
+		},
+		{
+			JobID: "job-2", RoundID: "round-named", CompletedRequests: 2, SuccessCount: 1, FailureCount: 1,
+			Names: map[string]types.NamedResults{
+				"read": {CompletedRequests: 2, SuccessCount: 1, FailureCount: 1, Latency: latencyHistogram(7, 9)},
+			},
+		},
+	}
+	for _, report := range reports {
+		if err := RecordJobReport(report); err != nil {
+			t.Fatalf("unexpected report error: %v", err)
+		}
+	}
+
+	observations := DrainReadyObservations(time.Minute)
+	if len(observations) != 1 {
+		t.Fatalf("expected one observation, got %d", len(observations))
+	}
+	read := observations[0].Names["read"]
+	if read.CompletedRequests != 3 || read.SuccessCount != 2 || read.FailureCount != 1 || read.Latency.P99Millis != 9 {
+		t.Fatalf("unexpected read breakdown: %+v", read)
+	}
+	if write := observations[0].Names["write"]; write.Latency.P99Millis != 300 {
+		t.Fatalf("unexpected write breakdown: %+v", write)
+	}
+}
+
+func TestRoundReportsFoldExcessNamesIntoOther(t *testing.T) {
+	var names map[string]*namedAggregate
+	for i := 0; i < maxRoundNames+5; i++ {
+		names = addNamedResults(names, fmt.Sprintf("name-%d", i), types.NamedResults{CompletedRequests: 1})
+	}
+	if len(names) != maxRoundNames+1 {
+		t.Fatalf("expected %d names including other, got %d", maxRoundNames+1, len(names))
+	}
+	if other := names[metrics.OverflowRequestName]; other == nil || other.CompletedRequests != 5 {
+		t.Fatalf("expected 5 requests folded into other, got %+v", other)
+	}
+}
+
+func latencyHistogram(millis ...int64) types.LatencyHistogram {
+	var histogram types.LatencyHistogram
+	for _, value := range millis {
+		histogram.Record(time.Duration(value) * time.Millisecond)
+	}
+	return histogram
+}
+
+func TestRoundReportsCountAssertionFailures(t *testing.T) {
+	ResetRoundReports()
+	t.Cleanup(ResetRoundReports)
+
+	RegisterRound("round-assert", 10, 2, 10)
+	reports := []types.JobReport{
+		{JobID: "job-1", RoundID: "round-assert", CompletedRequests: 5, SuccessCount: 3, FailureCount: 2,
+			AssertionFailureCount: 2,
+			Names: map[string]types.NamedResults{
+				"checkout": {CompletedRequests: 5, SuccessCount: 3, FailureCount: 2, AssertionFailureCount: 2},
+			}},
+		{JobID: "job-2", RoundID: "round-assert", CompletedRequests: 5, SuccessCount: 4, FailureCount: 1,
+			AssertionFailureCount: 1},
+	}
+	for _, report := range reports {
+		if err := RecordJobReport(report); err != nil {
+			t.Fatalf("unexpected report error: %v", err)
+		}
+	}
+
+	observations := DrainReadyObservations(time.Minute)
+	if len(observations) != 1 {
+		t.Fatalf("expected one observation, got %d", len(observations))
+	}
+	if observations[0].AssertionFailureCount != 3 || observations[0].FailureCount != 3 {
+		t.Fatalf("expected 3 assertion failures within 3 failures, got %+v", observations[0])
+	}
+	if checkout := observations[0].Names["checkout"]; checkout.AssertionFailureCount != 2 {
+		t.Fatalf("expected 2 checkout assertion failures, got %+v", checkout)
+	}
+}
+
+func TestRoundReportsSummarizeRequestPhases(t *testing.T) {
+	ResetRoundReports()
+	t.Cleanup(ResetRoundReports)
+
+	RegisterRound("round-phases", 10, 2, 4)
+	reports := []types.JobReport{
+		{JobID: "job-1", RoundID: "round-phases", CompletedRequests: 2, SuccessCount: 2,
+			Phases: types.PhaseLatencies{
+				Connect:   latencyHistogram(3),
+				FirstByte: latencyHistogram(40, 60),
+				Transfer:  latencyHistogram(1, 2),
+			}},
+		{JobID: "job-2", RoundID: "round-phases", CompletedRequests: 2, SuccessCount: 2,
+			Phases: types.PhaseLatencies{
+				Connect:      latencyHistogram(5),
+				TLSHandshake: latencyHistogram(12),
+				FirstByte:    latencyHistogram(50, 400),
+				Transfer:     latencyHistogram(1, 1),
+			}},
+	}
+	for _, report := range reports {
+		if err := RecordJobReport(report); err != nil {
+			t.Fatalf("unexpected report error: %v", err)
+		}
+	}
+
+	observations := DrainReadyObservations(time.Minute)
+	if len(observations) != 1 {
+		t.Fatalf("expected one observation, got %d", len(observations))
+	}
+	phases := observations[0].Phases
+	if phases.NewConnections != 2 || phases.Connect.P99Millis != 5 || phases.TLSHandshake.P99Millis != 12 {
+		t.Fatalf("unexpected connection phases %+v", phases)
+	}
+	if phases.FirstByte.P50Millis != 50 || phases.FirstByte.P99Millis != 400 || phases.Transfer.P99Millis != 2 {
+		t.Fatalf("unexpected server phases %+v", phases)
+	}
+}
+
+func TestRoundReportsBreakDownErrors(t *testing.T) {
+	ResetRoundReports()
+	t.Cleanup(ResetRoundReports)
+
+	RegisterRound("round-errors", 10, 2, 10)
+	reports := []types.JobReport{
+		{JobID: "job-1", RoundID: "round-errors", CompletedRequests: 5, SuccessCount: 2, FailureCount: 3,
+			Errors: map[string]int{metrics.ErrorCategoryServerError: 2, metrics.ErrorCategoryTimeout: 1}},
+		{JobID: "job-2", RoundID: "round-errors", CompletedRequests: 5, SuccessCount: 3, FailureCount: 2,
+			Errors: map[string]int{metrics.ErrorCategoryServerError: 1, metrics.ErrorCategoryConnectionReset: 1}},
+	}
+	for _, report := range reports {
+		if err := RecordJobReport(report); err != nil {
+			t.Fatalf("unexpected report error: %v", err)
+		}
+	}
+
+	observations := DrainReadyObservations(time.Minute)
+	if len(observations) != 1 {
+		t.Fatalf("expected one observation, got %d", len(observations))
+	}
+	expected := map[string]int{
+		metrics.ErrorCategoryServerError:     3,
+		metrics.ErrorCategoryTimeout:         1,
+		metrics.ErrorCategoryConnectionReset: 1,
+	}
+	if !maps.Equal(observations[0].Errors, expected) {
+		t.Fatalf("expected errors %v, got %v", expected, observations[0].Errors)
+	}
+}
+
+func TestRoundReportsBreakDownScenarioSteps(t *testing.T) {
+	ResetRoundReports()
+	t.Cleanup(ResetRoundReports)
+
+	RegisterRound("round-steps", 10, 2, 4)
+	reports := []types.JobReport{
+		{JobID: "job-1", RoundID: "round-steps", CompletedRequests: 2, SuccessCount: 1, FailureCount: 1,
+			Steps: map[string]types.NamedResults{
+				"checkout/login": {CompletedRequests: 2, SuccessCount: 2, Latency: latencyHistogram(10, 20)},
+				"checkout/pay":   {CompletedRequests: 2, SuccessCount: 1, FailureCount: 1, Latency: latencyHistogram(80, 90)},
+			}},
+		{JobID: "job-2", RoundID: "round-steps", CompletedRequests: 2, SuccessCount: 2,
+			Steps: map[string]types.NamedResults{
+				"checkout/login": {CompletedRequests: 2, SuccessCount: 2, Latency: latencyHistogram(30, 40)},
+				"checkout/pay":   {CompletedRequests: 2, SuccessCount: 2, Latency: latencyHistogram(100, 110)},
+			}},
+	}
+	for _, report := range reports {
+		if err := RecordJobReport(report); err != nil {
+			t.Fatalf("unexpected report error: %v", err)
+		}
+	}
+
+	observations := DrainReadyObservations(time.Minute)
+	if len(observations) != 1 {
+		t.Fatalf("expected one observation, got %d", len(observations))
+	}
+	steps := observations[0].Steps
+	if login := steps["checkout/login"]; login.CompletedRequests != 4 || login.Latency.P99Millis != 40 {
+		t.Fatalf("unexpected login step %+v", login)
+	}
+	if pay := steps["checkout/pay"]; pay.FailureCount != 1 || pay.SuccessCount != 3 {
+		t.Fatalf("unexpected pay step %+v", pay)
+	}
+	if observations[0].CompletedRequests != 4 {
+		t.Fatalf("expected scenarios to count once in the totals, got %d", observations[0].CompletedRequests)
+	}
+}
@@ -0,0 +1,357 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrRunActive is returned when a run is requested while another run has not yet ended.
+var ErrRunActive = errors.New("a run is already active")
+
+// maxRecentRounds bounds how many round observations a run keeps for status reporting.
+const maxRecentRounds = 20
+
+// RunState is the lifecycle state of a Run.
+type RunState string
+
+const (
+	RunStatePending   RunState = "pending"
+	RunStateRunning   RunState = "running"
+	RunStatePaused    RunState = "paused"
+	RunStateCompleted RunState = "completed"
+	RunStateAborted   RunState = "aborted"
+)
+
+// Terminal reports whether a run in this state will never dispatch work again.
+func (s RunState) Terminal() bool {
+	return s == RunStateCompleted || s == RunStateAborted
+}
+
+// RunLimits bounds a Run. Zero values mean unlimited.
+type RunLimits struct {
+	// MaxDuration is the active (unpaused) time the run may dispatch for.
+	MaxDuration time.Duration
+	// MaxRequests caps the total number of requests dispatched across all rounds.
+	MaxRequests int
+	// Abort ends the run early when the target breaches them.
+	Abort AbortConditions
+}
+
+// RunTotals sums the observed outcome of every completed round of a run, excluding warmup rounds.
+type RunTotals struct {
+	CompletedRequests int `json:"completedRequests"`
+	SuccessCount      int `json:"successCount"`
+	FailureCount      int `json:"failureCount"`
+	TimeoutCount      int `json:"timeoutCount"`
+
+	AssertionFailureCount int `json:"assertionFailureCount"`
+	// Errors counts the failed requests by error category.
+	Errors map[string]int `json:"errors,omitempty"`
+}
+
+// RunStatus is a point-in-time snapshot of a Run.
+type RunStatus struct {
+	ID                 string     `json:"id"`
+	State              RunState   `json:"state"`
+	Reason             string     `json:"reason,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	StartedAt          *time.Time `json:"startedAt,omitempty"`
+	EndedAt            *time.Time `json:"endedAt,omitempty"`
+	ActiveMillis       int64      `json:"activeMillis"`
+	Rounds             int        `json:"rounds"`
+	DispatchedRequests int        `json:"dispatchedRequests"`
+	// Phase is the plan phase of the most recently dispatched round, if the run follows a plan.
+	Phase string `json:"phase,omitempty"`
+
+	Totals RunTotals `json:"totals"`
+	// RecentRounds holds the most recently observed rounds, oldest first.
+	RecentRounds []LoadObservation `json:"recentRounds"`
+}
+
+// Run is a single finite load test. It starts pending, dispatches while running, and ends completed (a limit
+// was reached or the load profile was exhausted) or aborted (stopped early).
+type Run struct {
+	lock sync.Mutex
+
+	id     string
+	limits RunLimits
+	state  RunState
+	reason string
+
+	createdAt time.Time
+	startedAt time.Time
+	endedAt   time.Time
+	pausedAt  time.Time
+	pausedFor time.Duration
+
+	rounds             int
+	dispatchedRequests int
+	phase              string
+	totals             RunTotals
+	recentRounds       []LoadObservation
+
+	monitor *abortMonitor
+
+	done chan struct{}
+}
+
+func NewRun(id string, limits RunLimits) *Run {
+	return &Run{
+		id:        id,
+		limits:    limits,
+		state:     RunStatePending,
+		createdAt: time.Now(),
+		monitor:   &abortMonitor{conditions: limits.Abort},
+		done:      make(chan struct{}),
+	}
+}
+
+func (r *Run) ID() string {
+	return r.id
+}
+
+func (r *Run) State() RunState {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.state
+}
+
+// Done returns a channel that is closed once the run reaches a terminal state.
+func (r *Run) Done() <-chan struct{} {
+	return r.done
+}
+
+func (r *Run) Start() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.state != RunStatePending {
+		return fmt.Errorf("run %s cannot start from state %s", r.id, r.state)
+	}
+	r.state = RunStateRunning
+	r.startedAt = time.Now()
+	return nil
+}
+
+func (r *Run) Pause() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.state != RunStateRunning {
+		return fmt.Errorf("run %s cannot pause from state %s", r.id, r.state)
+	}
+	r.state = RunStatePaused
+	r.pausedAt = time.Now()
+	return nil
+}
+
+func (r *Run) Resume() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.state != RunStatePaused {
+		return fmt.Errorf("run %s cannot resume from state %s", r.id, r.state)
+	}
+	r.state = RunStateRunning
+	r.pausedFor += time.Since(r.pausedAt)
+	return nil
+}
+
+// Complete ends the run successfully. It is a no-op if the run has already ended.
+func (r *Run) Complete(reason string) {
+	r.finish(RunStateCompleted, reason)
+}
+
+// Abort ends the run early. It is a no-op if the run has already ended.
+func (r *Run) Abort(reason string) {
+	r.finish(RunStateAborted, reason)
+}
+
+func (r *Run) finish(state RunState, reason string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.state.Terminal() {
+		return
+	}
+	if r.state == RunStatePaused {
+		r.pausedFor += time.Since(r.pausedAt)
+	}
+	r.state = state
+	r.reason = reason
+	r.endedAt = time.Now()
+	close(r.done)
+}
+
+func (r *Run) Status() RunStatus {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	status := RunStatus{
+		ID:                 r.id,
+		State:              r.state,
+		Reason:             r.reason,
+		CreatedAt:          r.createdAt,
+		ActiveMillis:       r.activeDurationLocked().Milliseconds(),
+		Rounds:             r.rounds,
+		DispatchedRequests: r.dispatchedRequests,
+		Phase:              r.phase,
+		Totals:             r.totals,
+		RecentRounds:       slices.Clone(r.recentRounds),
+	}
+	status.Totals.Errors = maps.Clone(r.totals.Errors)
+	if !r.startedAt.IsZero() {
+		startedAt := r.startedAt
+		status.StartedAt = &startedAt
+	}
+	if !r.endedAt.IsZero() {
+		endedAt := r.endedAt
+		status.EndedAt = &endedAt
+	}
+	return status
+}
+
+// roundCount returns how many rounds the run has dispatched.
+func (r *Run) roundCount() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.rounds
+}
+
+// remainingRequests returns how many more requests may be dispatched, or -1 if the run has no request limit.
+func (r *Run) remainingRequests() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.limits.MaxRequests <= 0 {
+		return -1
+	}
+	return max(r.limits.MaxRequests-r.dispatchedRequests, 0)
+}
+
+// recordRound counts a dispatched round. phase and warmup tag the round when the run follows a plan.
+func (r *Run) recordRound(roundID string, plannedRequests int, phase string, warmup bool) {
+	runsLock.Lock()
+	roundOwners[roundID] = roundOwner{run: r, phase: phase, warmup: warmup}
+	runsLock.Unlock()
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.rounds++
+	r.dispatchedRequests += plannedRequests
+	r.phase = phase
+}
+
+func (r *Run) recordObservation(observation LoadObservation) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.recentRounds = append(r.recentRounds, observation)
+	if len(r.recentRounds) > maxRecentRounds {
+		r.recentRounds = slices.Delete(r.recentRounds, 0, len(r.recentRounds)-maxRecentRounds)
+	}
+	if observation.Warmup {
+		return
+	}
+	r.totals.CompletedRequests += observation.CompletedRequests
+	r.totals.SuccessCount += observation.SuccessCount
+	r.totals.FailureCount += observation.FailureCount
+	r.totals.TimeoutCount += observation.TimeoutCount
+	r.totals.AssertionFailureCount += observation.AssertionFailureCount
+	r.totals.Errors = addErrorCounts(r.totals.Errors, observation.Errors)
+}
+
+// limitReached reports whether a configured limit has ended the run, and which one.
+func (r *Run) limitReached() (string, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.limits.MaxRequests > 0 && r.dispatchedRequests >= r.limits.MaxRequests {
+		return fmt.Sprintf("dispatched request limit of %d reached", r.limits.MaxRequests), true
+	}
+	if r.limits.MaxDuration > 0 && r.activeDurationLocked() >= r.limits.MaxDuration {
+		return fmt.Sprintf("run duration of %s reached", r.limits.MaxDuration), true
+	}
+	return "", false
+}
+
+func (r *Run) activeDurationLocked() time.Duration {
+	if r.startedAt.IsZero() {
+		return 0
+	}
+	end := time.Now()
+	if !r.endedAt.IsZero() {
+		end = r.endedAt
+	}
+	paused := r.pausedFor
+	if r.state == RunStatePaused {
+		paused += end.Sub(r.pausedAt)
+	}
+	return end.Sub(r.startedAt) - paused
+}
+
+var runs = make(map[string]*Run)
+var currentRun *Run
+
+type roundOwner struct {
+	run    *Run
+	phase  string
+	warmup bool
+}
+
+// roundOwners maps each dispatched round to its run until the round's observation has been recorded.
+var roundOwners = make(map[string]roundOwner)
+var runsLock sync.Mutex
+
+// RegisterRun tracks a run by its id and makes it the current run that executors receive work for.
+func RegisterRun(run *Run) {
+	runsLock.Lock()
+	defer runsLock.Unlock()
+	runs[run.ID()] = run
+	currentRun = run
+}
+
+// GetRun returns a run by its id, if it exists. nil is returned otherwise.
+func GetRun(id string) *Run {
+	runsLock.Lock()
+	defer runsLock.Unlock()
+	return runs[id]
+}
+
+// CurrentRun returns the most recently registered run, or nil if no run has been registered.
+func CurrentRun() *Run {
+	runsLock.Lock()
+	defer runsLock.Unlock()
+	return currentRun
+}
+
+// ActiveRun returns the current run if it has not yet ended, or nil otherwise.
+func ActiveRun() *Run {
+	run := CurrentRun()
+	if run == nil || run.State().Terminal() {
+		return nil
+	}
+	return run
+}
+
+// recordRoundObservation tags an observation with the phase its round was dispatched in and credits it to the run
+// that dispatched the round. The owning run is returned, or nil if the round does not belong to a run.
+func recordRoundObservation(observation LoadObservation) (*Run, LoadObservation) {
+	runsLock.Lock()
+	owner, ok := roundOwners[observation.RoundID]
+	delete(roundOwners, observation.RoundID)
+	runsLock.Unlock()
+
+	if !ok {
+		return nil, observation
+	}
+	observation.Phase = owner.phase
+	observation.Warmup = owner.warmup
+	owner.run.recordObservation(observation)
+	return owner.run, observation
+}
+
+// ResetRuns clears the in-memory run registry.
+func ResetRuns() {
+	runsLock.Lock()
+	defer runsLock.Unlock()
+	runs = make(map[string]*Run)
+	currentRun = nil
+	roundOwners = make(map[string]roundOwner)
+}
@@ -0,0 +1,163 @@
+package manager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunLifecycleTransitions(t *testing.T) {
+	run := NewRun("run-1", RunLimits{})
+	if run.State() != RunStatePending {
+		t.Fatalf("expected pending run, got %s", run.State())
+	}
+	if err := run.Pause(); err == nil {
+		t.Fatalf("expected error pausing a pending run")
+	}
+	if err := run.Start(); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+	if err := run.Start(); err == nil {
+		t.Fatalf("expected error starting a running run")
+	}
+	if err := run.Pause(); err != nil {
+		t.Fatalf("unexpected pause error: %v", err)
+	}
+	if err := run.Resume(); err != nil {
+		t.Fatalf("unexpected resume error: %v", err)
+	}
+
+	run.Abort("stopped by operator")
+	run.Complete("ignored")
+	select {
+	case <-run.Done():
+	default:
+		t.Fatalf("expected done channel to be closed")
+	}
+	status := run.Status()
+	if status.State != RunStateAborted || status.Reason != "stopped by operator" {
+		t.Fatalf("expected first terminal state to stick, got %s (%s)", status.State, status.Reason)
+	}
+	if status.StartedAt == nil || status.EndedAt == nil {
+		t.Fatalf("expected start and end times on status, got %+v", status)
+	}
+	if err := run.Resume(); err == nil {
+		t.Fatalf("expected error resuming an aborted run")
+	}
+}
+
+func TestRunLimitReachedByRequests(t *testing.T) {
+	run := NewRun("run-1", RunLimits{MaxRequests: 10})
+	if err := run.Start(); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+	if got := run.remainingRequests(); got != 10 {
+		t.Fatalf("expected 10 remaining requests, got %d", got)
+	}
+	run.recordRound("round-1", 6, "", false)
+	if _, reached := run.limitReached(); reached {
+		t.Fatalf("expected limit not reached after 6 requests")
+	}
+	run.recordRound("round-2", 4, "", false)
+	if got := run.remainingRequests(); got != 0 {
+		t.Fatalf("expected 0 remaining requests, got %d", got)
+	}
+	if _, reached := run.limitReached(); !reached {
+		t.Fatalf("expected request limit to be reached")
+	}
+}
+
+func TestRunDurationExcludesPausedTime(t *testing.T) {
+	run := NewRun("run-1", RunLimits{MaxDuration: 40 * time.Millisecond})
+	if err := run.Start(); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+	if err := run.Pause(); err != nil {
+		t.Fatalf("unexpected pause error: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, reached := run.limitReached(); reached {
+		t.Fatalf("expected paused time not to count toward the run duration")
+	}
+	if err := run.Resume(); err != nil {
+		t.Fatalf("unexpected resume error: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if _, reached := run.limitReached(); !reached {
+		t.Fatalf("expected run duration to be reached")
+	}
+}
+
+func TestRegisterRunTracksCurrentRun(t *testing.T) {
+	ResetRuns()
+	t.Cleanup(ResetRuns)
+
+	if CurrentRun() != nil {
+		t.Fatalf("expected no current run")
+	}
+	first := NewRun("run-1", RunLimits{})
+	second := NewRun("run-2", RunLimits{})
+	RegisterRun(first)
+	RegisterRun(second)
+	if CurrentRun() != second {
+		t.Fatalf("expected most recent run to be current")
+	}
+	if GetRun("run-1") != first {
+		t.Fatalf("expected earlier run to remain retrievable")
+	}
+}
+
+func TestObservationsAreCreditedToTheRunThatDispatchedTheRound(t *testing.T) {
+	ResetRuns()
+	t.Cleanup(ResetRuns)
+
+	first := NewRun("run-1", RunLimits{})
+	second := NewRun("run-2", RunLimits{})
+	first.recordRound("round-1", 5, "", false)
+	second.recordRound("round-2", 3, "", false)
+
+	if owner, _ := recordRoundObservation(LoadObservation{RoundID: "round-1", CompletedRequests: 5, SuccessCount: 4,
+		FailureCount: 1}); owner != first {
+		t.Fatalf("expected round-1 to belong to run-1")
+	}
+	if owner, _ := recordRoundObservation(LoadObservation{RoundID: "round-unknown"}); owner != nil {
+		t.Fatalf("expected unknown round to have no owner")
+	}
+
+	status := first.Status()
+	if status.Totals.CompletedRequests != 5 || status.Totals.SuccessCount != 4 || status.Totals.FailureCount != 1 {
+		t.Fatalf("unexpected run totals: %+v", status.Totals)
+	}
+	if len(status.RecentRounds) != 1 || status.RecentRounds[0].RoundID != "round-1" {
+		t.Fatalf("unexpected recent rounds: %+v", status.RecentRounds)
+	}
+	if len(second.Status().RecentRounds) != 0 {
+		t.Fatalf("expected run-2 to have no observed rounds")
+	}
+}
+
+func TestWarmupRoundsAreExcludedFromRunTotals(t *testing.T) {
+	ResetRuns()
+	t.Cleanup(ResetRuns)
+
+	run := NewRun("run-1", RunLimits{})
+	run.recordRound("round-1", 5, "warmup", true)
+	run.recordRound("round-2", 5, "soak", false)
+
+	_, warmup := recordRoundObservation(LoadObservation{RoundID: "round-1", CompletedRequests: 5, SuccessCount: 5})
+	if warmup.Phase != "warmup" || !warmup.Warmup {
+		t.Fatalf("expected observation to be tagged with its warmup phase, got %+v", warmup)
+	}
+	recordRoundObservation(LoadObservation{RoundID: "round-2", CompletedRequests: 4, SuccessCount: 3, FailureCount: 1,
+		Errors: map[string]int{"server_error": 1}})
+
+	status := run.Status()
+	if status.Totals.CompletedRequests != 4 || status.Totals.Errors["server_error"] != 1 {
+		t.Fatalf("expected only the soak round in totals, got %+v", status.Totals)
+	}
+	if len(status.RecentRounds) != 2 {
+		t.Fatalf("expected warmup round to still be reported, got %d rounds", len(status.RecentRounds))
+	}
+	if status.Phase != "soak" {
+		t.Fatalf("expected current phase soak, got %q", status.Phase)
+	}
+}
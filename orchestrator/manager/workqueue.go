@@ -2,6 +2,7 @@ package manager
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/PeladoCollado/imager/orchestrator/logger"
 	"github.com/PeladoCollado/imager/types"
@@ -25,57 +26,158 @@ type ScheduleMetrics interface {
 type ScheduleOptions struct {
 	Interval    time.Duration
 	JobDuration time.Duration
+
+	// LoadModel is types.LoadModelOpen (calculators emit RPS) or types.LoadModelClosed (calculators emit a
+	// number of concurrent virtual users).
+	LoadModel string
+	ThinkTime types.ThinkTime
+	// Arrival shapes open-model send times. A non-zero Arrival.Seed makes every job's seed reproducible.
+	Arrival types.ArrivalProcess
+	// RequestTimeout bounds each request. Zero leaves it to the executors' own default.
+	RequestTimeout time.Duration
+}
+
+func (o ScheduleOptions) withDefaults() ScheduleOptions {
+	if o.Interval <= 0 {
+		o.Interval = DefaultScheduleInterval
+	}
+	if o.JobDuration <= 0 {
+		o.JobDuration = DefaultJobDuration
+	}
+	if o.LoadModel == "" {
+		o.LoadModel = types.LoadModelOpen
+	}
+	if o.Arrival.Type == "" {
+		o.Arrival.Type = types.ArrivalUniform
+	}
+	return o
+}
+
+// jobArrival hands the job at jobIndex of a run's round a distinct seed derived from the run's seed, so that a
+// seeded run generates the same gaps every time it is started.
+func jobArrival(base types.ArrivalProcess, round int, jobIndex int) *types.ArrivalProcess {
+	arrival := base
+	if arrival.Seed != 0 {
+		arrival.Seed += int64(round)<<20 + int64(jobIndex)
+	}
+	return &arrival
 }
 
+// Schedule starts the run and dispatches its load in the background until the run ends or ctx is canceled.
 func Schedule(ctx context.Context,
+	run *Run,
 	calc LoadCalculator,
 	source types.RequestSource,
 	resolver TargetResolver,
 	metrics ScheduleMetrics,
-	opts ScheduleOptions) {
-	go runSchedule(ctx, calc, source, resolver, metrics, opts)
+	opts ScheduleOptions) error {
+	if err := run.Start(); err != nil {
+		return err
+	}
+	go runSchedule(ctx, run, calc, source, resolver, metrics, opts)
+	return nil
 }
 
 func runSchedule(ctx context.Context,
+	run *Run,
 	calc LoadCalculator,
 	source types.RequestSource,
 	resolver TargetResolver,
 	metrics ScheduleMetrics,
 	opts ScheduleOptions) {
-	interval := opts.Interval
-	if interval <= 0 {
-		interval = DefaultScheduleInterval
-	}
-	jobDuration := opts.JobDuration
-	if jobDuration <= 0 {
-		jobDuration = DefaultJobDuration
+	opts = opts.withDefaults()
+	if opts.Arrival.Type == types.ArrivalRecorded && opts.LoadModel != types.LoadModelClosed {
+		source = newRecordedTimeline(source)
 	}
 
-	ticker := time.NewTicker(interval)
+	ticker := time.NewTicker(opts.Interval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Logger.Error("Context canceled- canceling all future work", ctx.Err())
+			run.Abort("orchestrator shutting down")
+			return
+		case <-run.Done():
+			status := run.Status()
+			logger.Logger.Info("Run finished", status.ID, status.State, status.Reason)
+			finishRounds(ctx, run, calc, staleRoundCutoff(opts.JobDuration))
 			return
 		case <-ticker.C:
-			dispatchTick(ctx, calc, source, resolver, metrics, jobDuration)
+			dispatchTick(ctx, run, calc, source, resolver, metrics, opts)
 		}
 	}
 }
 
+// staleRoundCutoff is how long after it is registered a round stops waiting for missing reports. Executors give
+// in-flight requests two job durations from the start of the job, and the third leaves room for handing the job to
+// the executor and posting its report back.
+func staleRoundCutoff(jobDuration time.Duration) time.Duration {
+	return 3 * jobDuration
+}
+
+// observeRounds credits completed rounds to the runs that dispatched them, feeds this run's rounds to the
+// calculator and aborts the run if a round breaches its abort conditions.
+func observeRounds(run *Run, calc LoadCalculator, staleAfter time.Duration) {
+	feedbackCalculator, isFeedback := calc.(FeedbackLoadCalculator)
+	for _, drained := range DrainReadyObservations(staleAfter) {
+		owner, observation := recordRoundObservation(drained)
+		if owner != run {
+			continue
+		}
+		if isFeedback {
+			feedbackCalculator.Observe(observation)
+		}
+		if reason, breached := run.monitor.observe(observation); breached {
+			logger.Logger.Warn("Abort condition breached", run.ID(), reason)
+			run.Abort(reason)
+		}
+	}
+}
+
+// finishRounds waits for the reports of a finished run's last rounds so that they appear in its status.
+func finishRounds(ctx context.Context, run *Run, calc LoadCalculator, staleAfter time.Duration) {
+	timer := time.NewTimer(staleAfter)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+	observeRounds(run, calc, staleAfter)
+}
+
 func dispatchTick(ctx context.Context,
+	run *Run,
 	calc LoadCalculator,
 	source types.RequestSource,
 	resolver TargetResolver,
 	metrics ScheduleMetrics,
-	jobDuration time.Duration) {
-	if feedbackCalculator, ok := calc.(FeedbackLoadCalculator); ok {
-		for _, observation := range DrainReadyObservations(2 * jobDuration) {
-			feedbackCalculator.Observe(observation)
+	opts ScheduleOptions) {
+	opts = opts.withDefaults()
+	jobDuration := opts.JobDuration
+	closedModel := opts.LoadModel == types.LoadModelClosed
+
+	observeRounds(run, calc, staleRoundCutoff(jobDuration))
+
+	if run.State() != RunStateRunning {
+		return
+	}
+	if counter, ok := resolver.(RestartCounter); ok {
+		if reason, breached := run.monitor.checkRestarts(ctx, counter); breached {
+			logger.Logger.Warn("Abort condition breached", run.ID(), reason)
+			run.Abort(reason)
+			return
 		}
 	}
+	if reason, reached := run.limitReached(); reached {
+		run.Complete(reason)
+		return
+	}
+	if exhaustible, ok := calc.(ExhaustibleLoadCalculator); ok && exhaustible.Exhausted() {
+		run.Complete("load profile exhausted")
+		return
+	}
 
 	executors := EligibleExecutors()
 	if metrics != nil {
@@ -105,34 +207,90 @@ func dispatchTick(ctx context.Context,
 	}
 
 	roundID := fmt.Sprintf("round-%d", time.Now().UnixNano())
-	totalRps := calc.Next()
-	if totalRps < 0 {
-		totalRps = 0
+	// totalLoad is requests per second in the open model and concurrent virtual users in the closed model.
+	totalLoad := calc.Next()
+	if totalLoad < 0 {
+		totalLoad = 0
+	}
+	var phase string
+	var warmup bool
+	if tracker, ok := calc.(PhaseTracker); ok {
+		phase, warmup = tracker.CurrentPhase()
 	}
 
-	baseRps := totalRps / totalWorkers
-	remainder := totalRps % totalWorkers
+	round := run.roundCount()
+	baseLoad := totalLoad / totalWorkers
+	remainder := totalLoad % totalWorkers
+	requestBudget := run.remainingRequests()
+	sourceExhausted := false
 	var globalWorkerIndex int
+
+	// The recorded arrival process takes the round's requests by their place on the recorded timeline rather than
+	// by the rate, and deals them out to the workers in turn with their send offsets.
+	timeline, recordedArrivals := source.(*recordedTimeline)
+	recordedArrivals = recordedArrivals && !closedModel
+	var recordedRequests [][]types.RequestSpec
+	var recordedOffsets [][]int64
+	if recordedArrivals {
+		roundRequests, offsets, err := timeline.take(opts.Interval, totalLoad, requestBudget)
+		if errors.Is(err, types.ErrSourceExhausted) {
+			sourceExhausted = true
+		} else if err != nil {
+			logger.Logger.Error("Unable to retrieve request from source", err)
+		}
+		recordedRequests = make([][]types.RequestSpec, totalWorkers)
+		recordedOffsets = make([][]int64, totalWorkers)
+		for i, request := range roundRequests {
+			recordedRequests[i%totalWorkers] = append(recordedRequests[i%totalWorkers], request)
+			recordedOffsets[i%totalWorkers] = append(recordedOffsets[i%totalWorkers], offsets[i].Milliseconds())
+		}
+	}
 	expectedReports := 0
 	plannedRequests := 0
 
 	for _, executor := range executors {
 		jobs := make([]types.Job, 0, executor.Workers)
 		for i := 0; i < executor.Workers; i++ {
-			workerRps := baseRps
+			workerIndex := globalWorkerIndex
+			workerLoad := baseLoad
 			if globalWorkerIndex < remainder {
-				workerRps++
+				workerLoad++
 			}
 			globalWorkerIndex++
 
-			requestCount := int(jobDuration.Seconds()) * workerRps
+			requestCount := int(jobDuration.Seconds()) * workerLoad
+			if closedModel {
+				requestCount = workerLoad * requestsPerVirtualUser(jobDuration, opts.ThinkTime)
+			}
 			if requestCount < 0 {
 				requestCount = 0
 			}
+			if requestBudget >= 0 {
+				requestCount = min(requestCount, requestBudget)
+				requestBudget -= requestCount
+			}
+
+			if sourceExhausted || recordedArrivals {
+				requestCount = 0
+			}
 
 			requests := make([]types.RequestSpec, 0, requestCount)
-			for reqIdx := 0; reqIdx < requestCount; reqIdx++ {
+			var offsetsMillis []int64
+			if recordedArrivals {
+				requests = recordedRequests[workerIndex]
+				offsetsMillis = recordedOffsets[workerIndex]
+			}
+			for len(requests) < requestCount {
 				nextRequest, nextErr := source.Next()
+				var recordErr *types.RecordError
+				if errors.As(nextErr, &recordErr) {
+					logger.Logger.Warn("Skipping malformed request record", nextErr)
+					continue
+				}
+				if errors.Is(nextErr, types.ErrSourceExhausted) {
+					sourceExhausted = true
+					break
+				}
 				if nextErr != nil {
 					logger.Logger.Error("Unable to retrieve request from source", nextErr)
 					break
@@ -145,8 +303,19 @@ func dispatchTick(ctx context.Context,
 				RoundID:        roundID,
 				Requests:       requests,
 				TargetURLs:     targetURLs,
-				RatePerSec:     workerRps,
+				RatePerSec:     workerLoad,
 				DurationMillis: jobDuration.Milliseconds(),
+				LoadModel:      opts.LoadModel,
+				Arrival:        jobArrival(opts.Arrival, round, globalWorkerIndex),
+				OffsetsMillis:  offsetsMillis,
+
+				RequestTimeoutMillis: opts.RequestTimeout.Milliseconds(),
+			}
+			if closedModel {
+				thinkTime := opts.ThinkTime
+				job.RatePerSec = 0
+				job.VirtualUsers = workerLoad
+				job.ThinkTime = &thinkTime
 			}
 			jobs = append(jobs, job)
 			expectedReports++
@@ -157,9 +326,39 @@ func dispatchTick(ctx context.Context,
 		}
 
 		if len(jobs) > 0 {
-			executor.WorkChan <- jobs
+			select {
+			case executor.WorkChan <- jobs:
+			case <-run.Done():
+				return
+			case <-ctx.Done():
+				return
+			}
 		}
 	}
 
-	RegisterRound(roundID, totalRps, expectedReports, plannedRequests)
+	RegisterRound(roundID, totalLoad, expectedReports, plannedRequests)
+	run.recordRound(roundID, plannedRequests, phase, warmup)
+	if reason, reached := run.limitReached(); reached {
+		run.Complete(reason)
+	} else if sourceExhausted {
+		run.Complete("request source exhausted")
+	}
+}
+
+// minPoolThinkTime bounds how many requests are shipped per virtual user when think times are very short.
+// Virtual users cycle through the job's request pool, so the pool only needs enough variety, not one
+// request per iteration.
+const minPoolThinkTime = 100 * time.Millisecond
+
+// requestsPerVirtualUser estimates how many iterations a virtual user completes in one job.
+func requestsPerVirtualUser(jobDuration time.Duration, thinkTime types.ThinkTime) int {
+	mean := thinkTime.Mean()
+	if mean < minPoolThinkTime {
+		mean = minPoolThinkTime
+	}
+	count := int((jobDuration + mean - 1) / mean)
+	if count < 1 {
+		count = 1
+	}
+	return count
 }
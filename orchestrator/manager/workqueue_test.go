@@ -2,7 +2,9 @@ package manager
 
 import (
 	"context"
+	"errors"
 	"github.com/PeladoCollado/imager/types"
+	"slices"
 	"testing"
 	"time"
 )
@@ -45,6 +47,26 @@ func (f *fakeSource) Reset() error {
 	return nil
 }
 
+// scriptedSource replays a fixed list of requests and errors, then reports that it is exhausted.
+type scriptedSource struct {
+	results []error
+	next    int
+}
+
+func (s *scriptedSource) Next() (types.RequestSpec, error) {
+	if s.next >= len(s.results) {
+		return types.RequestSpec{}, types.ErrSourceExhausted
+	}
+	err := s.results[s.next]
+	s.next++
+	return types.RequestSpec{Method: "GET", Path: "/resource"}, err
+}
+
+func (s *scriptedSource) Reset() error {
+	s.next = 0
+	return nil
+}
+
 type fakeResolver struct {
 	targets []string
 }
@@ -66,6 +88,15 @@ func (f *fakeScheduleMetrics) RecordJobDispatched(requestCount int) {
 	f.dispatched += requestCount
 }
 
+func startedRun(t *testing.T, limits RunLimits) *Run {
+	t.Helper()
+	run := NewRun("run-test", limits)
+	if err := run.Start(); err != nil {
+		t.Fatalf("unexpected start error: %v", err)
+	}
+	return run
+}
+
 func TestDispatchTickBuildsJobsAndDistributesRequests(t *testing.T) {
 	ResetExecutors()
 	ResetRoundReports()
@@ -83,11 +114,12 @@ func TestDispatchTickBuildsJobsAndDistributesRequests(t *testing.T) {
 
 	dispatchTick(
 		context.Background(),
+		startedRun(t, RunLimits{}),
 		calc,
 		source,
 		resolver,
 		metrics,
-		time.Second,
+		ScheduleOptions{JobDuration: time.Second},
 	)
 
 	select {
@@ -128,9 +160,10 @@ func TestDispatchTickFeedsRoundObservationsToFeedbackCalculator(t *testing.T) {
 
 	calc := &feedbackCalc{value: 10}
 	source := &fakeSource{}
+	run := startedRun(t, RunLimits{})
 	resolver := &fakeResolver{targets: []string{"http://10.0.0.1:8080"}}
 
-	dispatchTick(context.Background(), calc, source, resolver, nil, time.Second)
+	dispatchTick(context.Background(), run, calc, source, resolver, nil, ScheduleOptions{JobDuration: time.Second})
 	jobs := <-exec.WorkChan
 	if len(jobs) != 1 {
 		t.Fatalf("expected one job, got %d", len(jobs))
@@ -142,12 +175,12 @@ func TestDispatchTickFeedsRoundObservationsToFeedbackCalculator(t *testing.T) {
 		PlannedRequests:   len(jobs[0].Requests),
 		CompletedRequests: len(jobs[0].Requests),
 		SuccessCount:      len(jobs[0].Requests),
-		LatencyMillis:     []int64{10, 20, 30},
+		Latency:           latencyHistogram(10, 20, 30),
 	}); err != nil {
 		t.Fatalf("unexpected report error: %v", err)
 	}
 
-	dispatchTick(context.Background(), calc, source, resolver, nil, time.Second)
+	dispatchTick(context.Background(), run, calc, source, resolver, nil, ScheduleOptions{JobDuration: time.Second})
 
 	if len(calc.observations) != 1 {
 		t.Fatalf("expected one observation callback, got %d", len(calc.observations))
@@ -156,3 +189,191 @@ func TestDispatchTickFeedsRoundObservationsToFeedbackCalculator(t *testing.T) {
 		t.Fatalf("expected observed round id %s, got %s", jobs[0].RoundID, calc.observations[0].RoundID)
 	}
 }
+
+func TestDispatchTickClosedModelSplitsVirtualUsers(t *testing.T) {
+	ResetExecutors()
+	ResetRoundReports()
+	t.Cleanup(ResetExecutors)
+	t.Cleanup(ResetRoundReports)
+
+	AddExecutor("executor-1", 2)
+	exec := GetExecutor("executor-1")
+	exec.WorkChan = make(chan []types.Job, 1)
+
+	opts := ScheduleOptions{
+		JobDuration: time.Second,
+		LoadModel:   types.LoadModelClosed,
+		ThinkTime:   types.ThinkTime{Distribution: types.ThinkTimeConstant, MeanMillis: 500},
+	}
+	dispatchTick(context.Background(), startedRun(t, RunLimits{}), &staticCalc{value: 5}, &fakeSource{},
+		&fakeResolver{targets: []string{"http://10.0.0.1:8080"}}, nil, opts)
+
+	jobs := <-exec.WorkChan
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+	if jobs[0].VirtualUsers != 3 || jobs[1].VirtualUsers != 2 {
+		t.Fatalf("expected virtual users split 3/2, got %d/%d", jobs[0].VirtualUsers, jobs[1].VirtualUsers)
+	}
+	for _, job := range jobs {
+		if job.LoadModel != types.LoadModelClosed {
+			t.Fatalf("expected closed load model on job, got %q", job.LoadModel)
+		}
+		if job.RatePerSec != 0 {
+			t.Fatalf("expected no arrival rate in closed model, got %d", job.RatePerSec)
+		}
+		if job.ThinkTime == nil || job.ThinkTime.MeanMillis != 500 {
+			t.Fatalf("expected think time to be shipped with job, got %+v", job.ThinkTime)
+		}
+		// A 1s job with 500ms think time needs a pool of two requests per user.
+		if got := len(job.Requests); got != job.VirtualUsers*2 {
+			t.Fatalf("expected request pool of %d, got %d", job.VirtualUsers*2, got)
+		}
+	}
+}
+
+func TestDispatchTickGivesSeededJobsDistinctSeeds(t *testing.T) {
+	ResetExecutors()
+	ResetRoundReports()
+	t.Cleanup(ResetExecutors)
+	t.Cleanup(ResetRoundReports)
+
+	AddExecutor("executor-1", 2)
+	exec := GetExecutor("executor-1")
+	exec.WorkChan = make(chan []types.Job, 1)
+
+	opts := ScheduleOptions{
+		JobDuration:    time.Second,
+		Arrival:        types.ArrivalProcess{Type: types.ArrivalPoisson, Seed: 7},
+		RequestTimeout: 750 * time.Millisecond,
+	}
+	seeds := func(run *Run) []int64 {
+		dispatchTick(context.Background(), run, &staticCalc{value: 4}, &fakeSource{},
+			&fakeResolver{targets: []string{"http://10.0.0.1:8080"}}, nil, opts)
+		var seeds []int64
+		for _, job := range <-exec.WorkChan {
+			seeds = append(seeds, job.Arrival.Seed)
+		}
+		return seeds
+	}
+	run := startedRun(t, RunLimits{})
+	firstRound, secondRound := seeds(run), seeds(run)
+	if slices.Contains(secondRound, firstRound[0]) || slices.Contains(secondRound, firstRound[1]) {
+		t.Fatalf("expected every round to get new seeds, got %v and %v", firstRound, secondRound)
+	}
+	rerun := startedRun(t, RunLimits{})
+	expected := slices.Concat(firstRound, secondRound)
+	if again := slices.Concat(seeds(rerun), seeds(rerun)); !slices.Equal(again, expected) {
+		t.Fatalf("expected a rerun with the same seed to repeat the seeds %v, got %v", expected, again)
+	}
+
+	dispatchTick(context.Background(), startedRun(t, RunLimits{}), &staticCalc{value: 4}, &fakeSource{},
+		&fakeResolver{targets: []string{"http://10.0.0.1:8080"}}, nil, opts)
+	jobs := <-exec.WorkChan
+	if len(jobs) != 2 {
+		t.Fatalf("expected 2 jobs, got %d", len(jobs))
+	}
+	for _, job := range jobs {
+		if job.Arrival == nil || job.Arrival.Type != types.ArrivalPoisson {
+			t.Fatalf("expected poisson arrival on job, got %+v", job.Arrival)
+		}
+		if job.RequestTimeoutMillis != 750 {
+			t.Fatalf("expected request timeout 750ms on job, got %d", job.RequestTimeoutMillis)
+		}
+	}
+	if jobs[0].Arrival.Seed == jobs[1].Arrival.Seed {
+		t.Fatalf("expected distinct per-job seeds, both were %d", jobs[0].Arrival.Seed)
+	}
+}
+
+func TestDispatchTickCapsRoundAtRequestLimitAndCompletesRun(t *testing.T) {
+	ResetExecutors()
+	ResetRoundReports()
+	t.Cleanup(ResetExecutors)
+	t.Cleanup(ResetRoundReports)
+
+	AddExecutor("executor-1", 1)
+	exec := GetExecutor("executor-1")
+	exec.WorkChan = make(chan []types.Job, 2)
+
+	run := startedRun(t, RunLimits{MaxRequests: 5})
+	calc := &staticCalc{value: 4}
+	resolver := &fakeResolver{targets: []string{"http://10.0.0.1:8080"}}
+	opts := ScheduleOptions{JobDuration: time.Second}
+
+	dispatchTick(context.Background(), run, calc, &fakeSource{}, resolver, nil, opts)
+	dispatchTick(context.Background(), run, calc, &fakeSource{}, resolver, nil, opts)
+
+	first := <-exec.WorkChan
+	second := <-exec.WorkChan
+	if got := len(first[0].Requests); got != 4 {
+		t.Fatalf("expected first round of 4 requests, got %d", got)
+	}
+	if got := len(second[0].Requests); got != 1 {
+		t.Fatalf("expected second round capped at 1 request, got %d", got)
+	}
+	if run.State() != RunStateCompleted {
+		t.Fatalf("expected run to complete at its request limit, got %s", run.State())
+	}
+
+	dispatchTick(context.Background(), run, calc, &fakeSource{}, resolver, nil, opts)
+	select {
+	case jobs := <-exec.WorkChan:
+		t.Fatalf("expected no work after run completed, got %d jobs", len(jobs))
+	default:
+	}
+}
+
+func TestDispatchTickSkipsPausedRun(t *testing.T) {
+	ResetExecutors()
+	ResetRoundReports()
+	t.Cleanup(ResetExecutors)
+	t.Cleanup(ResetRoundReports)
+
+	AddExecutor("executor-1", 1)
+	exec := GetExecutor("executor-1")
+	exec.WorkChan = make(chan []types.Job, 1)
+
+	run := startedRun(t, RunLimits{})
+	if err := run.Pause(); err != nil {
+		t.Fatalf("unexpected pause error: %v", err)
+	}
+	dispatchTick(context.Background(), run, &staticCalc{value: 2}, &fakeSource{},
+		&fakeResolver{targets: []string{"http://10.0.0.1:8080"}}, nil, ScheduleOptions{JobDuration: time.Second})
+
+	select {
+	case jobs := <-exec.WorkChan:
+		t.Fatalf("expected no work while paused, got %d jobs", len(jobs))
+	default:
+	}
+}
+
+func TestDispatchTickSkipsMalformedRecordsAndCompletesExhaustedRun(t *testing.T) {
+	ResetExecutors()
+	ResetRoundReports()
+	t.Cleanup(ResetExecutors)
+	t.Cleanup(ResetRoundReports)
+
+	AddExecutor("executor-1", 2)
+	exec := GetExecutor("executor-1")
+	exec.WorkChan = make(chan []types.Job, 1)
+
+	run := startedRun(t, RunLimits{})
+	malformed := &types.RecordError{Line: 2, Err: errors.New("invalid character")}
+	source := &scriptedSource{results: []error{nil, malformed, nil, nil}}
+	resolver := &fakeResolver{targets: []string{"http://10.0.0.1:8080"}}
+
+	dispatchTick(context.Background(), run, &staticCalc{value: 4}, source, resolver, nil,
+		ScheduleOptions{JobDuration: time.Second})
+
+	jobs := <-exec.WorkChan
+	if got := len(jobs[0].Requests); got != 2 {
+		t.Fatalf("expected the malformed record to be skipped without shortening the job, got %d requests", got)
+	}
+	if got := len(jobs[1].Requests); got != 1 {
+		t.Fatalf("expected the second job to end with the source, got %d requests", got)
+	}
+	if run.State() != RunStateCompleted || run.Status().Reason != "request source exhausted" {
+		t.Fatalf("expected the run to complete with its source, got %s (%s)", run.State(), run.Status().Reason)
+	}
+}
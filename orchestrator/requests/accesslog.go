@@ -0,0 +1,223 @@
+package requests
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/PeladoCollado/imager/types"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// AccessLogCombined reads the NCSA combined log format shared by nginx and Apache, and its common subset
+	// without the referer and user agent.
+	AccessLogCombined = "combined"
+	// AccessLogJSON reads one JSON object per line, as written by an nginx or Apache JSON log_format.
+	AccessLogJSON = "json"
+)
+
+// combinedLogLine matches a common or combined log line: host, ident, user, [time], "request", status, bytes and,
+// for the combined format, "referer" "user agent".
+var combinedLogLine = regexp.MustCompile(
+	`^\S+ \S+ \S+ \[([^\]]+)\] "((?:[^"\\]|\\.)*)" \S+ \S+(?: "((?:[^"\\]|\\.)*)" "((?:[^"\\]|\\.)*)")?`)
+
+// clfTimeLayout is the timestamp layout of common and combined logs, e.g. 10/Oct/2000:13:55:36 -0700.
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogEntry is one request read from an access log.
+type AccessLogEntry struct {
+	Time    time.Time
+	Request types.RequestSpec
+}
+
+// AccessLogSource is an instance of RequestSource that replays the requests of a web server access log in file
+// order. It loops at the end of the log.
+type AccessLogSource struct {
+	lock     sync.Mutex
+	requests []types.RequestSpec
+	next     int
+}
+
+func NewAccessLogSource(file string, format string) (types.RequestSource, error) {
+	entries, err := ReadAccessLog(file, format)
+	if err != nil {
+		return nil, err
+	}
+	requests := make([]types.RequestSpec, len(entries))
+	for i, entry := range entries {
+		requests[i] = entry.Request
+	}
+	return &AccessLogSource{requests: requests}, nil
+}
+
+func (a *AccessLogSource) Next() (types.RequestSpec, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	next := a.requests[a.next]
+	a.next = (a.next + 1) % len(a.requests)
+	return next, nil
+}
+
+func (a *AccessLogSource) Reset() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.next = 0
+	return nil
+}
+
+// ReadAccessLog parses an access log in the given format. Lines whose request cannot be replayed, such as the "-"
+// nginx logs for connections closed before a request arrived, are skipped; lines that do not match the format at
+// all are an error.
+func ReadAccessLog(file string, format string) ([]AccessLogEntry, error) {
+	var parse func(line []byte) (AccessLogEntry, bool, error)
+	switch format {
+	case "", AccessLogCombined:
+		parse = parseCombinedLogLine
+	case AccessLogJSON:
+		parse = parseJSONLogLine
+	default:
+		return nil, fmt.Errorf("unsupported access log format %q", format)
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	var entries []AccessLogEntry
+	scanner := bufio.NewScanner(fh)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		entry, ok, err := parse(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", file, lineNumber, err)
+		}
+		if ok {
+			entries = append(entries, entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", file, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s has no replayable requests", file)
+	}
+	return entries, nil
+}
+
+func parseCombinedLogLine(line []byte) (AccessLogEntry, bool, error) {
+	match := combinedLogLine.FindSubmatch(line)
+	if match == nil {
+		return AccessLogEntry{}, false, fmt.Errorf("not a common or combined log line")
+	}
+	at, err := time.Parse(clfTimeLayout, string(match[1]))
+	if err != nil {
+		return AccessLogEntry{}, false, err
+	}
+	request, ok := parseRequestLine(string(match[2]))
+	if !ok {
+		return AccessLogEntry{}, false, nil
+	}
+	addLoggedHeader(&request, "Referer", string(match[3]))
+	addLoggedHeader(&request, "User-Agent", string(match[4]))
+	return AccessLogEntry{Time: at, Request: request}, true, nil
+}
+
+// parseJSONLogLine reads the request from a "request" line, or from "method" with "request_uri", "uri" or "path"
+// and an optional "args" or "query_string". The time comes from "time_iso8601", "time_local", "time", "timestamp"
+// or "@timestamp", either formatted or as Unix seconds.
+func parseJSONLogLine(line []byte) (AccessLogEntry, bool, error) {
+	fields := map[string]any{}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return AccessLogEntry{}, false, err
+	}
+	text := func(names ...string) string {
+		for _, name := range names {
+			if value, ok := fields[name]; ok && value != nil {
+				return fmt.Sprint(value)
+			}
+		}
+		return ""
+	}
+
+	at, err := parseLoggedTime(text("time_iso8601", "time_local", "time", "timestamp", "@timestamp"))
+	if err != nil {
+		return AccessLogEntry{}, false, err
+	}
+	var request types.RequestSpec
+	ok := false
+	if requestLine := text("request"); requestLine != "" {
+		request, ok = parseRequestLine(requestLine)
+	} else if method, uri := text("method", "request_method"), text("request_uri", "uri", "path"); method != "" &&
+		strings.HasPrefix(uri, "/") {
+		request, ok = parseRequestLine(method + " " + uri)
+		if query := text("args", "query_string"); ok && request.QueryString == "" && query != "" {
+			request.QueryString = strings.TrimPrefix(query, "?")
+		}
+	}
+	if !ok {
+		return AccessLogEntry{}, false, nil
+	}
+	addLoggedHeader(&request, "Referer", text("http_referer", "referer"))
+	addLoggedHeader(&request, "User-Agent", text("http_user_agent", "user_agent"))
+	return AccessLogEntry{Time: at, Request: request}, true, nil
+}
+
+// parseRequestLine splits a logged request line such as "GET /search?q=go HTTP/1.1". The logged path is escaped and
+// the executor escapes the path when it builds the request URL, so the spec holds it decoded.
+func parseRequestLine(line string) (types.RequestSpec, bool) {
+	parts := strings.Fields(line)
+	if len(parts) < 2 || !strings.HasPrefix(parts[1], "/") {
+		return types.RequestSpec{}, false
+	}
+	requestURL, err := url.ParseRequestURI(parts[1])
+	if err != nil {
+		return types.RequestSpec{}, false
+	}
+	return types.RequestSpec{
+		Method:      parts[0],
+		Path:        requestURL.Path,
+		QueryString: requestURL.RawQuery,
+	}, true
+}
+
+func parseLoggedTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("no request time")
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.UnixMilli(int64(seconds * 1000)), nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, clfTimeLayout} {
+		if at, err := time.Parse(layout, value); err == nil {
+			return at, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized request time %q", value)
+}
+
+// addLoggedHeader adds a header recorded in the log, skipping the "-" servers write for a missing value.
+func addLoggedHeader(request *types.RequestSpec, name string, value string) {
+	if value == "" || value == "-" {
+		return
+	}
+	if request.Headers == nil {
+		request.Headers = make(map[string][]string)
+	}
+	request.Headers[name] = []string{value}
+}
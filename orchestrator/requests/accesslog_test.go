@@ -0,0 +1,126 @@
+package requests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeAccessLog(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "access.log")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("unable to write access log: %v", err)
+	}
+	return path
+}
+
+func TestReadAccessLogCombined(t *testing.T) {
+	path := writeAccessLog(t,
+		`10.0.0.1 - - [01/May/2024:10:00:00 +0000] "GET /products?page=2 HTTP/1.1" 200 512 "https://shop.example.com/" "Mozilla/5.0 (X11)"`,
+		`10.0.0.2 - frank [01/May/2024:10:00:01 +0000] "POST /cart HTTP/1.1" 201 0`,
+		`10.0.0.3 - - [01/May/2024:10:00:01 +0000] "-" 400 0 "-" "-"`,
+		``,
+		`10.0.0.4 - - [01/May/2024:10:00:03 +0200] "GET /search/caf%C3%A9%20bar?q=a%20b HTTP/2.0" 200 90 "-" "curl/8.0"`,
+	)
+	entries, err := ReadAccessLog(path, AccessLogCombined)
+	if err != nil {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected the unreplayable line to be skipped, got %+v", entries)
+	}
+
+	first := entries[0]
+	if first.Request.Method != "GET" || first.Request.Path != "/products" || first.Request.QueryString != "page=2" {
+		t.Fatalf("unexpected first request: %+v", first.Request)
+	}
+	if first.Request.Headers["User-Agent"][0] != "Mozilla/5.0 (X11)" ||
+		first.Request.Headers["Referer"][0] != "https://shop.example.com/" {
+		t.Fatalf("expected the referer and user agent to be replayed, got %v", first.Request.Headers)
+	}
+	if !first.Time.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected first time: %s", first.Time)
+	}
+	if second := entries[1]; second.Request.Method != "POST" || second.Request.Headers != nil {
+		t.Fatalf("expected a common log line without headers, got %+v", second.Request)
+	}
+	if third := entries[2]; third.Request.QueryString != "q=a%20b" || len(third.Request.Headers) != 1 {
+		t.Fatalf("expected the query string and only the user agent, got %+v", third.Request)
+	}
+	if third := entries[2]; third.Request.Path != "/search/café bar" {
+		t.Fatalf("expected the logged path decoded, got %s", third.Request.Path)
+	}
+	if uri := replayedRequestURI(t, entries[2].Request); uri != "/search/caf%C3%A9%20bar?q=a%20b" {
+		t.Fatalf("expected the logged request URI to be replayed as is, got %s", uri)
+	}
+	if !entries[2].Time.Equal(time.Date(2024, 5, 1, 8, 0, 3, 0, time.UTC)) {
+		t.Fatalf("expected the time zone offset to be applied, got %s", entries[2].Time)
+	}
+}
+
+func TestReadAccessLogJSON(t *testing.T) {
+	path := writeAccessLog(t,
+		`{"time_iso8601": "2024-05-01T10:00:00+00:00", "request": "GET /products?page=2 HTTP/1.1", "status": 200, "http_user_agent": "Mozilla/5.0"}`,
+		`{"time_local": "01/May/2024:10:00:01 +0000", "method": "DELETE", "uri": "/cart/7", "args": "force=true", "http_referer": "-"}`,
+		`{"timestamp": 1714557602.5, "request_method": "GET", "request_uri": "/health"}`,
+		`{"time": "2024-05-01T10:00:03Z", "request": "-"}`,
+	)
+	entries, err := ReadAccessLog(path, AccessLogJSON)
+	if err != nil {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected the unreplayable line to be skipped, got %+v", entries)
+	}
+	if first := entries[0].Request; first.Path != "/products" || first.QueryString != "page=2" ||
+		first.Headers["User-Agent"][0] != "Mozilla/5.0" {
+		t.Fatalf("unexpected first request: %+v", first)
+	}
+	if second := entries[1].Request; second.Method != "DELETE" || second.Path != "/cart/7" ||
+		second.QueryString != "force=true" || second.Headers != nil {
+		t.Fatalf("unexpected second request: %+v", second)
+	}
+	if third := entries[2]; third.Request.Path != "/health" || third.Time.UnixMilli() != 1714557602500 {
+		t.Fatalf("unexpected third entry: %+v", third)
+	}
+}
+
+func TestReadAccessLogRejectsInvalidInput(t *testing.T) {
+	if _, err := ReadAccessLog(writeAccessLog(t, "not a log line"), AccessLogCombined); err == nil {
+		t.Fatalf("expected error for a line that does not match the format")
+	}
+	if _, err := ReadAccessLog(writeAccessLog(t, `{"request": "GET / HTTP/1.1"}`), AccessLogJSON); err == nil {
+		t.Fatalf("expected error for a JSON line without a time")
+	}
+	if _, err := ReadAccessLog(writeAccessLog(t, `{"time": "2024-05-01T10:00:03Z", "request": "-"}`),
+		AccessLogJSON); err == nil {
+		t.Fatalf("expected error for a log without replayable requests")
+	}
+	if _, err := ReadAccessLog(writeAccessLog(t, ""), "w3c"); err == nil {
+		t.Fatalf("expected error for an unsupported format")
+	}
+}
+
+func TestAccessLogSourceLoopsInLogOrder(t *testing.T) {
+	path := writeAccessLog(t,
+		`10.0.0.1 - - [01/May/2024:10:00:00 +0000] "GET /a HTTP/1.1" 200 1`,
+		`10.0.0.1 - - [01/May/2024:10:00:00 +0000] "GET /b HTTP/1.1" 200 1`,
+	)
+	source, err := NewAccessLogSource(path, AccessLogCombined)
+	if err != nil {
+		t.Fatalf("unexpected source error: %v", err)
+	}
+	requests := nextRequests(t, source, 3)
+	if requests[0].Path != "/a" || requests[1].Path != "/b" || requests[2].Path != "/a" {
+		t.Fatalf("expected the log to loop, got %+v", requests)
+	}
+	if err := source.Reset(); err != nil {
+		t.Fatalf("unexpected reset error: %v", err)
+	}
+	if next, _ := source.Next(); next.Path != "/a" {
+		t.Fatalf("expected reset to restart the log, got %+v", next)
+	}
+}
@@ -0,0 +1,275 @@
+package requests
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/PeladoCollado/imager/types"
+	"github.com/google/uuid"
+	"math"
+	mathrand "math/rand"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	FeederCSV          = "csv"
+	FeederCounter      = "counter"
+	FeederUUID         = "uuid"
+	FeederRandomInt    = "random-int"
+	FeederRandomString = "random-string"
+	FeederTimestamp    = "timestamp"
+	FeederEnv          = "env"
+)
+
+const (
+	// CSVSequential hands out rows in file order and wraps around at the end.
+	CSVSequential = "sequential"
+	// CSVRandom picks a row uniformly at random for every request.
+	CSVRandom = "random"
+	// CSVUnique hands out every row once. Since all requests are generated by the orchestrator, no two jobs, and
+	// therefore no two executors, ever share a row. The feeder is exhausted once the rows run out.
+	CSVUnique = "unique"
+)
+
+// ErrFeederExhausted is returned by a unique CSV feeder once every row has been handed out. It wraps
+// types.ErrSourceExhausted, so a template source that runs out of rows completes its run.
+var ErrFeederExhausted = fmt.Errorf("feeder has no rows left: %w", types.ErrSourceExhausted)
+
+const defaultRandomStringCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// FeederConfig configures one data feeder of a template source. Type selects the feeder; the other fields apply
+// to the types named in their comments.
+type FeederConfig struct {
+	Type string `json:"type"`
+
+	// File and Mode (sequential, random or unique, default sequential) configure a csv feeder. The file's first
+	// row names the columns, which templates reference as ${feeder.column}.
+	File string `json:"file,omitempty"`
+	Mode string `json:"mode,omitempty"`
+
+	// Start and Step configure a counter, which defaults to counting 0, 1, 2, ...
+	Start int64  `json:"start,omitempty"`
+	Step  *int64 `json:"step,omitempty"`
+
+	// Min and Max are the inclusive bounds of a random-int.
+	Min int64 `json:"min,omitempty"`
+	Max int64 `json:"max,omitempty"`
+
+	// MinLength, MaxLength and Charset configure a random-string. Charset defaults to ASCII letters and digits.
+	MinLength int    `json:"minLength,omitempty"`
+	MaxLength int    `json:"maxLength,omitempty"`
+	Charset   string `json:"charset,omitempty"`
+
+	// Format is rfc3339 (the default), rfc3339nano, unix, unix-millis, or a Go time layout for a timestamp.
+	Format string `json:"format,omitempty"`
+
+	// Variable names the environment variable of an env feeder. Default is used when it is unset; without a
+	// default, an unset variable is an error.
+	Variable string  `json:"variable,omitempty"`
+	Default  *string `json:"default,omitempty"`
+}
+
+// Feeder produces the values of one template placeholder. Next is called once per generated request, so every
+// reference to the feeder in a request sees the same values. Feeders are not safe for concurrent use; a
+// TemplateSource serializes its calls.
+type Feeder interface {
+	// Next returns the values for one request, keyed by field. Single-value feeders use the "" field.
+	Next() (map[string]string, error)
+	// Fields lists the fields Next returns.
+	Fields() []string
+	Reset() error
+}
+
+// NewFeeder builds the feeder described by cfg.
+func NewFeeder(cfg FeederConfig) (Feeder, error) {
+	switch cfg.Type {
+	case FeederCSV:
+		return newCSVFeeder(cfg.File, cfg.Mode)
+	case FeederCounter:
+		step := int64(1)
+		if cfg.Step != nil {
+			step = *cfg.Step
+		}
+		return &counterFeeder{start: cfg.Start, step: step, next: cfg.Start}, nil
+	case FeederUUID:
+		return valueFeeder(uuid.NewString), nil
+	case FeederRandomInt:
+		if cfg.Max < cfg.Min {
+			return nil, fmt.Errorf("random-int max must be >= min")
+		}
+		if uint64(cfg.Max)-uint64(cfg.Min) >= math.MaxInt64 {
+			return nil, fmt.Errorf("random-int max - min must be < %d", int64(math.MaxInt64))
+		}
+		rng := newFeederRand()
+		return valueFeeder(func() string {
+			return strconv.FormatInt(cfg.Min+rng.Int63n(cfg.Max-cfg.Min+1), 10)
+		}), nil
+	case FeederRandomString:
+		return newRandomStringFeeder(cfg)
+	case FeederTimestamp:
+		return newTimestampFeeder(cfg.Format), nil
+	case FeederEnv:
+		if cfg.Variable == "" {
+			return nil, fmt.Errorf("env feeder requires a variable")
+		}
+		value, ok := os.LookupEnv(cfg.Variable)
+		if !ok {
+			if cfg.Default == nil {
+				return nil, fmt.Errorf("environment variable %s is not set", cfg.Variable)
+			}
+			value = *cfg.Default
+		}
+		return valueFeeder(func() string { return value }), nil
+	default:
+		return nil, fmt.Errorf("unsupported feeder type %q", cfg.Type)
+	}
+}
+
+// valueFeeder adapts a function producing a single value to a Feeder.
+type valueFeeder func() string
+
+func (f valueFeeder) Next() (map[string]string, error) {
+	return map[string]string{"": f()}, nil
+}
+
+func (f valueFeeder) Fields() []string {
+	return []string{""}
+}
+
+func (f valueFeeder) Reset() error {
+	return nil
+}
+
+type csvFeeder struct {
+	columns []string
+	rows    [][]string
+	mode    string
+	next    int
+	rng     *mathrand.Rand
+}
+
+func newCSVFeeder(file string, mode string) (Feeder, error) {
+	if file == "" {
+		return nil, fmt.Errorf("csv feeder requires a file")
+	}
+	if mode == "" {
+		mode = CSVSequential
+	}
+	if mode != CSVSequential && mode != CSVRandom && mode != CSVUnique {
+		return nil, fmt.Errorf("unsupported csv feeder mode %q", mode)
+	}
+	fh, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	records, err := csv.NewReader(fh).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", file, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%s needs a header row and at least one data row", file)
+	}
+	return &csvFeeder{
+		columns: records[0],
+		rows:    records[1:],
+		mode:    mode,
+		rng:     newFeederRand(),
+	}, nil
+}
+
+func (c *csvFeeder) Next() (map[string]string, error) {
+	var row []string
+	switch c.mode {
+	case CSVRandom:
+		row = c.rows[c.rng.Intn(len(c.rows))]
+	case CSVUnique:
+		if c.next >= len(c.rows) {
+			return nil, ErrFeederExhausted
+		}
+		row = c.rows[c.next]
+		c.next++
+	default:
+		row = c.rows[c.next]
+		c.next = (c.next + 1) % len(c.rows)
+	}
+	values := make(map[string]string, len(c.columns))
+	for i, column := range c.columns {
+		values[column] = row[i]
+	}
+	return values, nil
+}
+
+func (c *csvFeeder) Fields() []string {
+	return slices.Clone(c.columns)
+}
+
+func (c *csvFeeder) Reset() error {
+	c.next = 0
+	return nil
+}
+
+type counterFeeder struct {
+	start int64
+	step  int64
+	next  int64
+}
+
+func (c *counterFeeder) Next() (map[string]string, error) {
+	value := c.next
+	c.next += c.step
+	return map[string]string{"": strconv.FormatInt(value, 10)}, nil
+}
+
+func (c *counterFeeder) Fields() []string {
+	return []string{""}
+}
+
+func (c *counterFeeder) Reset() error {
+	c.next = c.start
+	return nil
+}
+
+func newRandomStringFeeder(cfg FeederConfig) (Feeder, error) {
+	if cfg.MinLength < 0 || cfg.MaxLength < cfg.MinLength {
+		return nil, fmt.Errorf("random-string lengths must satisfy 0 <= minLength <= maxLength")
+	}
+	charset := []rune(cfg.Charset)
+	if len(charset) == 0 {
+		charset = []rune(defaultRandomStringCharset)
+	}
+	rng := newFeederRand()
+	return valueFeeder(func() string {
+		length := cfg.MinLength + rng.Intn(cfg.MaxLength-cfg.MinLength+1)
+		value := make([]rune, length)
+		for i := range value {
+			value[i] = charset[rng.Intn(len(charset))]
+		}
+		return string(value)
+	}), nil
+}
+
+func newTimestampFeeder(format string) Feeder {
+	return valueFeeder(func() string {
+		now := time.Now()
+		switch format {
+		case "", "rfc3339":
+			return now.UTC().Format(time.RFC3339)
+		case "rfc3339nano":
+			return now.UTC().Format(time.RFC3339Nano)
+		case "unix":
+			return strconv.FormatInt(now.Unix(), 10)
+		case "unix-millis":
+			return strconv.FormatInt(now.UnixMilli(), 10)
+		default:
+			return now.Format(format)
+		}
+	})
+}
+
+// newFeederRand returns a clock-seeded generator for a random feeder.
+func newFeederRand() *mathrand.Rand {
+	return mathrand.New(mathrand.NewSource(time.Now().UnixNano()))
+}
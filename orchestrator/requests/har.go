@@ -0,0 +1,169 @@
+package requests
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/PeladoCollado/imager/types"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultHARStripHeaders are the volatile request headers a HARSource drops unless told otherwise.
+var DefaultHARStripHeaders = []string{"Cookie", "Content-Length"}
+
+// HAROptions selects and cleans up the entries of a HAR file.
+type HAROptions struct {
+	// Hosts keeps only entries whose URL host (without port) is in the list. Empty keeps every host.
+	Hosts []string
+	// URLPattern keeps only entries whose full URL matches the regular expression. Empty keeps every URL.
+	URLPattern string
+	// StripHeaders lists request headers to drop, matched case-insensitively. HTTP/2 pseudo-headers and Host are
+	// always dropped, since the request goes to the run's target instead.
+	StripHeaders []string
+	// PreserveTiming sets each RequestSpec.DelayMillis to the recorded gap since the previous kept entry, for
+	// replay with the recorded arrival process.
+	PreserveTiming bool
+}
+
+// HARSource is an instance of RequestSource that replays the requests of a HAR (HTTP Archive) file, such as a
+// browser session exported from developer tools, in recorded order. It loops at the end of the file.
+type HARSource struct {
+	lock     sync.Mutex
+	requests []types.RequestSpec
+	next     int
+}
+
+type harFile struct {
+	Log struct {
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time  `json:"startedDateTime"`
+	Request         harRequest `json:"request"`
+}
+
+type harRequest struct {
+	Method   string         `json:"method"`
+	URL      string         `json:"url"`
+	Headers  []harNameValue `json:"headers"`
+	PostData *harPostData   `json:"postData"`
+}
+
+type harPostData struct {
+	MimeType string         `json:"mimeType"`
+	Text     string         `json:"text"`
+	Params   []harNameValue `json:"params"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func NewHARSource(file string, opts HAROptions) (types.RequestSource, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	har := harFile{}
+	if err := json.Unmarshal(content, &har); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+	var urlPattern *regexp.Regexp
+	if opts.URLPattern != "" {
+		urlPattern, err = regexp.Compile(opts.URLPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL pattern %q: %w", opts.URLPattern, err)
+		}
+	}
+
+	entries := har.Log.Entries
+	slices.SortStableFunc(entries, func(a, b harEntry) int {
+		return a.StartedDateTime.Compare(b.StartedDateTime)
+	})
+	requests := make([]types.RequestSpec, 0, len(entries))
+	var previous time.Time
+	for _, entry := range entries {
+		requestURL, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("entry %s %s: %w", entry.Request.Method, entry.Request.URL, err)
+		}
+		if len(opts.Hosts) > 0 && !slices.Contains(opts.Hosts, requestURL.Hostname()) {
+			continue
+		}
+		if urlPattern != nil && !urlPattern.MatchString(entry.Request.URL) {
+			continue
+		}
+		request := harRequestSpec(entry.Request, requestURL, opts.StripHeaders)
+		if opts.PreserveTiming && !previous.IsZero() {
+			request.DelayMillis = max(entry.StartedDateTime.Sub(previous).Milliseconds(), 0)
+		}
+		previous = entry.StartedDateTime
+		requests = append(requests, request)
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("%s has no entries matching the filters", file)
+	}
+	return &HARSource{requests: requests}, nil
+}
+
+func harRequestSpec(request harRequest, requestURL *url.URL, stripHeaders []string) types.RequestSpec {
+	// The executor escapes the path when it builds the request URL, so the spec holds it decoded.
+	spec := types.RequestSpec{
+		Method:      request.Method,
+		Path:        requestURL.Path,
+		QueryString: requestURL.RawQuery,
+	}
+	for _, header := range request.Headers {
+		if strings.HasPrefix(header.Name, ":") || strings.EqualFold(header.Name, "Host") ||
+			slices.ContainsFunc(stripHeaders, func(strip string) bool { return strings.EqualFold(strip, header.Name) }) {
+			continue
+		}
+		if spec.Headers == nil {
+			spec.Headers = make(map[string][]string)
+		}
+		// HTTP/2 captures record lower-case names; canonicalize them so repeated headers are merged.
+		name := http.CanonicalHeaderKey(header.Name)
+		spec.Headers[name] = append(spec.Headers[name], header.Value)
+	}
+	if postData := request.PostData; postData != nil {
+		spec.Body = postData.Text
+		if spec.Body == "" && len(postData.Params) > 0 {
+			form := url.Values{}
+			for _, param := range postData.Params {
+				form.Add(param.Name, param.Value)
+			}
+			spec.Body = form.Encode()
+		}
+		if _, ok := spec.Headers["Content-Type"]; !ok && postData.MimeType != "" && spec.Body != "" {
+			if spec.Headers == nil {
+				spec.Headers = make(map[string][]string)
+			}
+			spec.Headers["Content-Type"] = []string{postData.MimeType}
+		}
+	}
+	return spec
+}
+
+func (h *HARSource) Next() (types.RequestSpec, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	next := h.requests[h.next]
+	h.next = (h.next + 1) % len(h.requests)
+	return next, nil
+}
+
+func (h *HARSource) Reset() error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.next = 0
+	return nil
+}
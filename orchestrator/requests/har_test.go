@@ -0,0 +1,176 @@
+package requests
+
+import (
+	"context"
+	"github.com/PeladoCollado/imager/executor/worker"
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testHAR = `{"log": {"version": "1.2", "entries": [
+  {"startedDateTime": "2024-05-01T10:00:00.000Z", "request": {
+    "method": "GET", "url": "https://shop.example.com/products?page=2&sort=price",
+    "headers": [
+      {"name": ":authority", "value": "shop.example.com"},
+      {"name": "accept", "value": "application/json"},
+      {"name": "cookie", "value": "sid=abc"},
+      {"name": "x-trace", "value": "1"}
+    ]}},
+  {"startedDateTime": "2024-05-01T10:00:00.250Z", "request": {
+    "method": "GET", "url": "https://cdn.example.com/app.js", "headers": []}},
+  {"startedDateTime": "2024-05-01T10:00:01.500Z", "request": {
+    "method": "POST", "url": "https://shop.example.com/cart",
+    "headers": [{"name": "Content-Length", "value": "12"}, {"name": "Host", "value": "shop.example.com"}],
+    "postData": {"mimeType": "application/json", "text": "{\"sku\":\"a1\"}"}}},
+  {"startedDateTime": "2024-05-01T10:00:01.000Z", "request": {
+    "method": "POST", "url": "https://shop.example.com/login",
+    "headers": [{"name": "X-Trace", "value": "2"}],
+    "postData": {"mimeType": "application/x-www-form-urlencoded",
+      "params": [{"name": "user", "value": "ada"}, {"name": "password", "value": "p w"}]}}}
+]}}`
+
+func writeHAR(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "session.har")
+	if err := os.WriteFile(path, []byte(testHAR), 0o600); err != nil {
+		t.Fatalf("unable to write HAR file: %v", err)
+	}
+	return path
+}
+
+func nextRequests(t *testing.T, source types.RequestSource, count int) []types.RequestSpec {
+	t.Helper()
+	requests := make([]types.RequestSpec, 0, count)
+	for i := 0; i < count; i++ {
+		next, err := source.Next()
+		if err != nil {
+			t.Fatalf("unexpected request error: %v", err)
+		}
+		requests = append(requests, next)
+	}
+	return requests
+}
+
+func TestHARSourceMapsEntries(t *testing.T) {
+	source, err := NewHARSource(writeHAR(t), HAROptions{
+		Hosts:          []string{"shop.example.com"},
+		StripHeaders:   DefaultHARStripHeaders,
+		PreserveTiming: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected HAR source error: %v", err)
+	}
+
+	requests := nextRequests(t, source, 4)
+	products, login, cart, looped := requests[0], requests[1], requests[2], requests[3]
+	assertRequest(t, products, "GET", "/products")
+	if products.QueryString != "page=2&sort=price" || products.DelayMillis != 0 {
+		t.Fatalf("unexpected first request %+v", products)
+	}
+	if len(products.Headers) != 2 || products.Headers["Accept"][0] != "application/json" ||
+		products.Headers["X-Trace"][0] != "1" {
+		t.Fatalf("expected cookie and pseudo-headers to be stripped, got %v", products.Headers)
+	}
+	assertRequest(t, login, "POST", "/login")
+	if login.Body != "password=p+w&user=ada" || login.DelayMillis != 1000 {
+		t.Fatalf("expected the form body and a 1s recorded delay, got %+v", login)
+	}
+	if login.Headers["Content-Type"][0] != "application/x-www-form-urlencoded" {
+		t.Fatalf("expected the post data MIME type as content type, got %v", login.Headers)
+	}
+	assertRequest(t, cart, "POST", "/cart")
+	if cart.Body != `{"sku":"a1"}` || cart.DelayMillis != 500 {
+		t.Fatalf("unexpected cart request %+v", cart)
+	}
+	if _, ok := cart.Headers["Content-Length"]; ok || cart.Headers["Host"] != nil {
+		t.Fatalf("expected volatile headers to be stripped, got %v", cart.Headers)
+	}
+	assertRequest(t, looped, "GET", "/products")
+}
+
+// replayedRequestURI sends the request through the executor, as a job would, and returns the request URI the target
+// received.
+func replayedRequestURI(t *testing.T, spec types.RequestSpec) string {
+	t.Helper()
+	received := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.RequestURI
+	}))
+	defer server.Close()
+	job := types.Job{
+		ID:             "replay",
+		Requests:       []types.RequestSpec{spec},
+		TargetURLs:     []string{server.URL},
+		RatePerSec:     1,
+		DurationMillis: 100,
+	}
+	worker.RunJob(context.Background(), job, metrics.NewPrometheusMetricsCollector(prometheus.NewRegistry()))
+	select {
+	case uri := <-received:
+		return uri
+	default:
+		t.Fatalf("expected the request to reach the target")
+		return ""
+	}
+}
+
+func TestHARSourceReplaysEscapedPaths(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.har")
+	content := `{"log": {"entries": [{"startedDateTime": "2024-05-01T10:00:00.000Z", "request": {
+    "method": "GET", "url": "https://shop.example.com/search/caf%C3%A9%20bar?q=a%20b", "headers": []}}]}}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write HAR file: %v", err)
+	}
+	source, err := NewHARSource(path, HAROptions{})
+	if err != nil {
+		t.Fatalf("unexpected HAR source error: %v", err)
+	}
+
+	request := nextRequests(t, source, 1)[0]
+	assertRequest(t, request, "GET", "/search/café bar")
+	if uri := replayedRequestURI(t, request); uri != "/search/caf%C3%A9%20bar?q=a%20b" {
+		t.Fatalf("expected the recorded URL to be replayed as is, got %s", uri)
+	}
+}
+
+func TestHARSourceFiltersByURLPatternAndIgnoresTiming(t *testing.T) {
+	source, err := NewHARSource(writeHAR(t), HAROptions{URLPattern: `\.js$|/cart`})
+	if err != nil {
+		t.Fatalf("unexpected HAR source error: %v", err)
+	}
+
+	requests := nextRequests(t, source, 2)
+	assertRequest(t, requests[0], "GET", "/app.js")
+	assertRequest(t, requests[1], "POST", "/cart")
+	if requests[1].DelayMillis != 0 || requests[1].Headers["Content-Length"][0] != "12" {
+		t.Fatalf("expected no delay and headers kept as recorded, got %+v", requests[1])
+	}
+}
+
+func TestNewHARSourceRejectsInvalidInput(t *testing.T) {
+	har := writeHAR(t)
+	notHAR := filepath.Join(t.TempDir(), "not.har")
+	if err := os.WriteFile(notHAR, []byte("not json"), 0o600); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+	cases := map[string]struct {
+		file string
+		opts HAROptions
+	}{
+		"missing file":    {file: filepath.Join(t.TempDir(), "missing.har")},
+		"invalid json":    {file: notHAR},
+		"invalid pattern": {file: har, opts: HAROptions{URLPattern: "("}},
+		"nothing matches": {file: har, opts: HAROptions{Hosts: []string{"other.example.com"}}},
+	}
+	for name, tc := range cases {
+		if _, err := NewHARSource(tc.file, tc.opts); err == nil {
+			t.Fatalf("%s: expected HAR source error", name)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package requests
+
+import (
+	"fmt"
+	"github.com/PeladoCollado/imager/types"
+	"sync"
+)
+
+// WeightedChild is one traffic class of a WeightedSource.
+type WeightedChild struct {
+	Name   string
+	Weight int
+	Source types.RequestSource
+}
+
+// WeightedSource is an instance of RequestSource that draws from several child sources in proportion to their
+// weights and tags each request with the name of the child it came from. Children are interleaved with smooth
+// weighted round-robin, so every window of sum(weights) requests matches the configured mix exactly.
+type WeightedSource struct {
+	lock     sync.Mutex
+	children []WeightedChild
+	current  []int
+	total    int
+}
+
+func NewWeightedSource(children []WeightedChild) (types.RequestSource, error) {
+	if len(children) == 0 {
+		return nil, fmt.Errorf("at least one weighted source is required")
+	}
+	names := make(map[string]struct{}, len(children))
+	total := 0
+	for _, child := range children {
+		if child.Name == "" {
+			return nil, fmt.Errorf("weighted sources require a name")
+		}
+		if _, ok := names[child.Name]; ok {
+			return nil, fmt.Errorf("duplicate weighted source name %s", child.Name)
+		}
+		names[child.Name] = struct{}{}
+		if child.Weight <= 0 {
+			return nil, fmt.Errorf("weight of source %s must be > 0", child.Name)
+		}
+		if child.Source == nil {
+			return nil, fmt.Errorf("source %s is required", child.Name)
+		}
+		total += child.Weight
+	}
+	return &WeightedSource{
+		children: children,
+		current:  make([]int, len(children)),
+		total:    total,
+	}, nil
+}
+
+func (w *WeightedSource) Next() (types.RequestSpec, error) {
+	child := w.nextChild()
+	next, err := child.Source.Next()
+	if err != nil {
+		return types.RequestSpec{}, fmt.Errorf("source %s: %w", child.Name, err)
+	}
+	next.Source = child.Name
+	return next, nil
+}
+
+func (w *WeightedSource) Reset() error {
+	w.lock.Lock()
+	clear(w.current)
+	w.lock.Unlock()
+	for _, child := range w.children {
+		if err := child.Source.Reset(); err != nil {
+			return fmt.Errorf("source %s: %w", child.Name, err)
+		}
+	}
+	return nil
+}
+
+func (w *WeightedSource) nextChild() WeightedChild {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	selected := 0
+	for i, child := range w.children {
+		w.current[i] += child.Weight
+		if w.current[i] > w.current[selected] {
+			selected = i
+		}
+	}
+	w.current[selected] -= w.total
+	return w.children[selected]
+}
@@ -0,0 +1,98 @@
+package requests
+
+import (
+	"errors"
+	"github.com/PeladoCollado/imager/types"
+	"testing"
+)
+
+type pathSource struct {
+	path   string
+	resets int
+	err    error
+}
+
+func (p *pathSource) Next() (types.RequestSpec, error) {
+	if p.err != nil {
+		return types.RequestSpec{}, p.err
+	}
+	return types.RequestSpec{Method: "GET", Path: p.path}, nil
+}
+
+func (p *pathSource) Reset() error {
+	p.resets++
+	return nil
+}
+
+func TestWeightedSourceMatchesConfiguredMix(t *testing.T) {
+	source, err := NewWeightedSource([]WeightedChild{
+		{Name: "reads", Weight: 70, Source: &pathSource{path: "/read"}},
+		{Name: "searches", Weight: 25, Source: &pathSource{path: "/search"}},
+		{Name: "writes", Weight: 5, Source: &pathSource{path: "/write"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error constructing source: %v", err)
+	}
+
+	counts := make(map[string]int)
+	paths := map[string]string{"reads": "/read", "searches": "/search", "writes": "/write"}
+	for i := 0; i < 200; i++ {
+		req, reqErr := source.Next()
+		if reqErr != nil {
+			t.Fatalf("unexpected source error: %v", reqErr)
+		}
+		if req.Path != paths[req.Source] {
+			t.Fatalf("request tagged %q came from %s", req.Source, req.Path)
+		}
+		counts[req.Source]++
+	}
+	if counts["reads"] != 140 || counts["searches"] != 50 || counts["writes"] != 10 {
+		t.Fatalf("expected an exact 70/25/5 mix over two cycles, got %+v", counts)
+	}
+}
+
+func TestWeightedSourceResetsChildren(t *testing.T) {
+	reads := &pathSource{path: "/read"}
+	writes := &pathSource{path: "/write"}
+	source, err := NewWeightedSource([]WeightedChild{
+		{Name: "reads", Weight: 1, Source: reads},
+		{Name: "writes", Weight: 1, Source: writes},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error constructing source: %v", err)
+	}
+	if err := source.Reset(); err != nil {
+		t.Fatalf("unexpected reset error: %v", err)
+	}
+	if reads.resets != 1 || writes.resets != 1 {
+		t.Fatalf("expected every child to be reset, got %d/%d", reads.resets, writes.resets)
+	}
+}
+
+func TestWeightedSourceReportsFailingChild(t *testing.T) {
+	source, err := NewWeightedSource([]WeightedChild{
+		{Name: "broken", Weight: 1, Source: &pathSource{err: errors.New("boom")}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error constructing source: %v", err)
+	}
+	if _, err := source.Next(); err == nil {
+		t.Fatalf("expected child error to be returned")
+	}
+}
+
+func TestNewWeightedSourceValidatesChildren(t *testing.T) {
+	child := &pathSource{path: "/"}
+	tests := map[string][]WeightedChild{
+		"empty":          nil,
+		"missing name":   {{Weight: 1, Source: child}},
+		"zero weight":    {{Name: "a", Source: child}},
+		"missing source": {{Name: "a", Weight: 1}},
+		"duplicate":      {{Name: "a", Weight: 1, Source: child}, {Name: "a", Weight: 1, Source: child}},
+	}
+	for name, children := range tests {
+		if _, err := NewWeightedSource(children); err == nil {
+			t.Fatalf("%s: expected validation error", name)
+		}
+	}
+}
@@ -0,0 +1,731 @@
+package requests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/PeladoCollado/imager/types"
+	"github.com/google/uuid"
+	"maps"
+	"math"
+	mathrand "math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sigs.k8s.io/yaml"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSchemaDepth bounds how deeply nested or recursive schemas are synthesized.
+const maxSchemaDepth = 8
+
+// pathTemplatePattern matches a {name} path or server URL template variable.
+var pathTemplatePattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPIOptions selects the operations of an OpenAPI document to generate requests for. An operation is
+// identified by its operationId or, without one, by its method and path, e.g. "GET /pets/{petId}".
+type OpenAPIOptions struct {
+	// IncludeTags and IncludeOperations keep only operations with one of the tags or identifiers. With neither,
+	// every operation is kept.
+	IncludeTags       []string
+	IncludeOperations []string
+	// ExcludeTags and ExcludeOperations drop operations with one of the tags or identifiers.
+	ExcludeTags       []string
+	ExcludeOperations []string
+	// Weights sets the relative weight of operations by identifier. Operations without a weight have weight 1.
+	Weights map[string]int
+}
+
+type openAPIDocument struct {
+	Servers []struct {
+		URL       string `json:"url"`
+		Variables map[string]struct {
+			Default string `json:"default"`
+		} `json:"variables"`
+	} `json:"servers"`
+	Paths      map[string]openAPIPathItem `json:"paths"`
+	Components struct {
+		Schemas       map[string]*openAPISchema      `json:"schemas"`
+		Parameters    map[string]*openAPIParameter   `json:"parameters"`
+		RequestBodies map[string]*openAPIRequestBody `json:"requestBodies"`
+		Examples      map[string]*openAPIExample     `json:"examples"`
+	} `json:"components"`
+}
+
+type openAPIPathItem struct {
+	Parameters []*openAPIParameter `json:"parameters"`
+	Get        *openAPIOperation   `json:"get"`
+	Put        *openAPIOperation   `json:"put"`
+	Post       *openAPIOperation   `json:"post"`
+	Delete     *openAPIOperation   `json:"delete"`
+	Options    *openAPIOperation   `json:"options"`
+	Head       *openAPIOperation   `json:"head"`
+	Patch      *openAPIOperation   `json:"patch"`
+	Trace      *openAPIOperation   `json:"trace"`
+}
+
+func (p openAPIPathItem) operations() map[string]*openAPIOperation {
+	operations := map[string]*openAPIOperation{
+		http.MethodGet:     p.Get,
+		http.MethodPut:     p.Put,
+		http.MethodPost:    p.Post,
+		http.MethodDelete:  p.Delete,
+		http.MethodOptions: p.Options,
+		http.MethodHead:    p.Head,
+		http.MethodPatch:   p.Patch,
+		http.MethodTrace:   p.Trace,
+	}
+	maps.DeleteFunc(operations, func(_ string, operation *openAPIOperation) bool { return operation == nil })
+	return operations
+}
+
+type openAPIOperation struct {
+	OperationID string              `json:"operationId"`
+	Tags        []string            `json:"tags"`
+	Parameters  []*openAPIParameter `json:"parameters"`
+	RequestBody *openAPIRequestBody `json:"requestBody"`
+}
+
+type openAPIParameter struct {
+	Ref      string                      `json:"$ref"`
+	Name     string                      `json:"name"`
+	In       string                      `json:"in"`
+	Required bool                        `json:"required"`
+	Schema   *openAPISchema              `json:"schema"`
+	Example  any                         `json:"example"`
+	Examples map[string]*openAPIExample  `json:"examples"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIRequestBody struct {
+	Ref      string                      `json:"$ref"`
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema   *openAPISchema             `json:"schema"`
+	Example  any                        `json:"example"`
+	Examples map[string]*openAPIExample `json:"examples"`
+}
+
+type openAPIExample struct {
+	Ref   string `json:"$ref"`
+	Value any    `json:"value"`
+}
+
+type openAPISchema struct {
+	Ref string `json:"$ref"`
+	// Type is a single type name, or a list of them in OpenAPI 3.1.
+	Type     any    `json:"type"`
+	Format   string `json:"format"`
+	Enum     []any  `json:"enum"`
+	Const    any    `json:"const"`
+	Example  any    `json:"example"`
+	Examples []any  `json:"examples"`
+	Default  any    `json:"default"`
+	ReadOnly bool   `json:"readOnly"`
+
+	Minimum *float64 `json:"minimum"`
+	Maximum *float64 `json:"maximum"`
+	// ExclusiveMinimum and ExclusiveMaximum are booleans in OpenAPI 3.0 and bounds in 3.1.
+	ExclusiveMinimum any  `json:"exclusiveMinimum"`
+	ExclusiveMaximum any  `json:"exclusiveMaximum"`
+	MinLength        *int `json:"minLength"`
+	MaxLength        *int `json:"maxLength"`
+
+	Items      *openAPISchema            `json:"items"`
+	MinItems   *int                      `json:"minItems"`
+	MaxItems   *int                      `json:"maxItems"`
+	Properties map[string]*openAPISchema `json:"properties"`
+
+	AllOf []*openAPISchema `json:"allOf"`
+	OneOf []*openAPISchema `json:"oneOf"`
+	AnyOf []*openAPISchema `json:"anyOf"`
+}
+
+// NewOpenAPISource reads an OpenAPI 3 document in JSON or YAML and returns a RequestSource that generates requests
+// for its selected operations, interleaved by weight like a WeightedSource. Parameters and bodies use the
+// document's examples where present and are otherwise synthesized from their schemas.
+func NewOpenAPISource(file string, opts OpenAPIOptions) (types.RequestSource, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	jsonContent, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+	document := &openAPIDocument{}
+	decoder := json.NewDecoder(bytes.NewReader(jsonContent))
+	decoder.UseNumber()
+	if err := decoder.Decode(document); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", file, err)
+	}
+	basePath, err := document.basePath()
+	if err != nil {
+		return nil, err
+	}
+
+	var children []WeightedChild
+	weighted := make(map[string]struct{}, len(opts.Weights))
+	for _, path := range slices.Sorted(maps.Keys(document.Paths)) {
+		pathItem := document.Paths[path]
+		operations := pathItem.operations()
+		for _, method := range slices.Sorted(maps.Keys(operations)) {
+			operation := operations[method]
+			id := operation.OperationID
+			if id == "" {
+				id = method + " " + path
+			}
+			if !opts.selects(id, operation.Tags) {
+				continue
+			}
+			generator, err := newOperationGenerator(document, basePath, method, path, pathItem.Parameters, operation)
+			if err != nil {
+				return nil, fmt.Errorf("operation %s: %w", id, err)
+			}
+			generator.name = id
+			weight := 1
+			if configured, ok := opts.Weights[id]; ok {
+				weight = configured
+				weighted[id] = struct{}{}
+			}
+			children = append(children, WeightedChild{Name: id, Weight: weight, Source: generator})
+		}
+	}
+	for id := range opts.Weights {
+		if _, ok := weighted[id]; !ok {
+			return nil, fmt.Errorf("weight set for operation %s, which is not selected", id)
+		}
+	}
+	if len(children) == 0 {
+		return nil, fmt.Errorf("%s has no operations matching the filters", file)
+	}
+	return NewWeightedSource(children)
+}
+
+func (o OpenAPIOptions) selects(id string, tags []string) bool {
+	hasTag := func(filter []string) bool {
+		return slices.ContainsFunc(tags, func(tag string) bool { return slices.Contains(filter, tag) })
+	}
+	if slices.Contains(o.ExcludeOperations, id) || hasTag(o.ExcludeTags) {
+		return false
+	}
+	if len(o.IncludeOperations) == 0 && len(o.IncludeTags) == 0 {
+		return true
+	}
+	return slices.Contains(o.IncludeOperations, id) || hasTag(o.IncludeTags)
+}
+
+// basePath returns the decoded path of the document's first server URL, which prefixes every operation path.
+func (d *openAPIDocument) basePath() (string, error) {
+	if len(d.Servers) == 0 {
+		return "", nil
+	}
+	server := d.Servers[0]
+	serverURL := pathTemplatePattern.ReplaceAllStringFunc(server.URL, func(variable string) string {
+		return server.Variables[strings.Trim(variable, "{}")].Default
+	})
+	parsed, err := url.Parse(serverURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL %q: %w", server.URL, err)
+	}
+	return strings.TrimSuffix(parsed.Path, "/"), nil
+}
+
+// operationGenerator is the RequestSource of a single operation. It synthesizes a new request on every call.
+type operationGenerator struct {
+	lock     sync.Mutex
+	document *openAPIDocument
+	rng      *mathrand.Rand
+
+	name       string
+	method     string
+	path       string
+	parameters []*openAPIParameter
+	bodyType   string
+	body       *openAPIMediaType
+}
+
+func newOperationGenerator(document *openAPIDocument,
+	basePath string,
+	method string,
+	path string,
+	pathParameters []*openAPIParameter,
+	operation *openAPIOperation) (*operationGenerator, error) {
+	generator := &operationGenerator{
+		document: document,
+		rng:      newFeederRand(),
+		method:   method,
+		path:     basePath + path,
+	}
+	// Operation parameters override path item parameters with the same name and location.
+	byKey := make(map[string]*openAPIParameter)
+	var keys []string
+	for _, parameter := range slices.Concat(pathParameters, operation.Parameters) {
+		resolved, err := document.parameter(parameter)
+		if err != nil {
+			return nil, err
+		}
+		key := resolved.In + ":" + resolved.Name
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = resolved
+	}
+	for _, key := range keys {
+		generator.parameters = append(generator.parameters, byKey[key])
+	}
+	for _, match := range pathTemplatePattern.FindAllStringSubmatch(path, -1) {
+		if _, ok := byKey["path:"+match[1]]; !ok {
+			return nil, fmt.Errorf("path parameter %s is not defined", match[1])
+		}
+	}
+
+	if operation.RequestBody != nil {
+		requestBody, err := document.requestBody(operation.RequestBody)
+		if err != nil {
+			return nil, err
+		}
+		generator.bodyType = requestBodyType(requestBody.Content)
+		if generator.bodyType == "" && requestBody.Required {
+			return nil, fmt.Errorf("required request body has no JSON or form content type")
+		}
+		if generator.bodyType != "" {
+			body := requestBody.Content[generator.bodyType]
+			generator.body = &body
+		}
+	}
+
+	for _, parameter := range generator.parameters {
+		schemas := []*openAPISchema{parameter.Schema}
+		for _, media := range parameter.Content {
+			schemas = append(schemas, media.Schema)
+		}
+		for _, schema := range schemas {
+			if err := document.validateSchema(schema, 0); err != nil {
+				return nil, fmt.Errorf("parameter %s: %w", parameter.Name, err)
+			}
+		}
+	}
+	if generator.body != nil {
+		if err := document.validateSchema(generator.body.Schema, 0); err != nil {
+			return nil, fmt.Errorf("request body: %w", err)
+		}
+	}
+	return generator, nil
+}
+
+// requestBodyType picks the media type bodies are generated for: JSON if the operation accepts it, otherwise a
+// URL-encoded form.
+func requestBodyType(content map[string]openAPIMediaType) string {
+	if _, ok := content["application/json"]; ok {
+		return "application/json"
+	}
+	for _, mediaType := range slices.Sorted(maps.Keys(content)) {
+		if strings.HasSuffix(mediaType, "+json") {
+			return mediaType
+		}
+	}
+	if _, ok := content["application/x-www-form-urlencoded"]; ok {
+		return "application/x-www-form-urlencoded"
+	}
+	return ""
+}
+
+func (g *operationGenerator) Next() (types.RequestSpec, error) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	spec := types.RequestSpec{Name: g.name, Method: g.method}
+	path := g.path
+	query := url.Values{}
+	var cookies []string
+	for _, parameter := range g.parameters {
+		if !parameter.Required && parameter.In != "path" && !g.hasExample(parameter) {
+			continue
+		}
+		value := g.parameterValue(parameter)
+		switch parameter.In {
+		case "path":
+			path = strings.ReplaceAll(path, "{"+parameter.Name+"}", joinValue(value))
+		case "query":
+			addQueryValue(query, parameter.Name, value)
+		case "header":
+			if name := http.CanonicalHeaderKey(parameter.Name); name != "Accept" && name != "Content-Type" &&
+				name != "Authorization" {
+				if spec.Headers == nil {
+					spec.Headers = make(map[string][]string)
+				}
+				spec.Headers[name] = []string{joinValue(value)}
+			}
+		case "cookie":
+			cookies = append(cookies, parameter.Name+"="+joinValue(value))
+		}
+	}
+	spec.Path = path
+	spec.QueryString = query.Encode()
+	if len(cookies) > 0 {
+		if spec.Headers == nil {
+			spec.Headers = make(map[string][]string)
+		}
+		spec.Headers["Cookie"] = []string{strings.Join(cookies, "; ")}
+	}
+
+	if g.body != nil {
+		value := g.mediaValue(g.body)
+		if g.bodyType == "application/x-www-form-urlencoded" {
+			form := url.Values{}
+			if fields, ok := value.(map[string]any); ok {
+				for name, field := range fields {
+					addQueryValue(form, name, field)
+				}
+			}
+			spec.Body = form.Encode()
+		} else {
+			body, err := json.Marshal(value)
+			if err != nil {
+				return types.RequestSpec{}, fmt.Errorf("operation %s: encode body: %w", g.name, err)
+			}
+			spec.Body = string(body)
+		}
+		if spec.Headers == nil {
+			spec.Headers = make(map[string][]string)
+		}
+		spec.Headers["Content-Type"] = []string{g.bodyType}
+	}
+	return spec, nil
+}
+
+func (g *operationGenerator) Reset() error {
+	return nil
+}
+
+// hasExample reports whether an optional parameter documents an example value, in which case it is sent.
+func (g *operationGenerator) hasExample(parameter *openAPIParameter) bool {
+	if parameter.Example != nil || len(parameter.Examples) > 0 {
+		return true
+	}
+	schema := g.document.schema(parameter.Schema)
+	return schema != nil && (schema.Example != nil || len(schema.Examples) > 0)
+}
+
+func (g *operationGenerator) parameterValue(parameter *openAPIParameter) any {
+	if parameter.Example != nil {
+		return parameter.Example
+	}
+	if example, ok := g.document.firstExample(parameter.Examples); ok {
+		return example
+	}
+	if parameter.Schema == nil {
+		for _, mediaType := range slices.Sorted(maps.Keys(parameter.Content)) {
+			media := parameter.Content[mediaType]
+			return g.mediaValue(&media)
+		}
+	}
+	return g.schemaValue(parameter.Schema, 0)
+}
+
+func (g *operationGenerator) mediaValue(media *openAPIMediaType) any {
+	if media.Example != nil {
+		return media.Example
+	}
+	if example, ok := g.document.firstExample(media.Examples); ok {
+		return example
+	}
+	return g.schemaValue(media.Schema, 0)
+}
+
+// schemaValue synthesizes a value that satisfies the schema, preferring its example, default or enum values.
+// Patterns are not synthesized; schemas with a pattern need an example.
+func (g *operationGenerator) schemaValue(schema *openAPISchema, depth int) any {
+	schema = g.document.schema(schema)
+	if schema == nil || depth > maxSchemaDepth {
+		return nil
+	}
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case len(schema.Examples) > 0:
+		return schema.Examples[0]
+	case schema.Const != nil:
+		return schema.Const
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[g.rng.Intn(len(schema.Enum))]
+	case len(schema.AllOf) > 0:
+		merged := map[string]any{}
+		for _, part := range schema.AllOf {
+			if fields, ok := g.schemaValue(part, depth+1).(map[string]any); ok {
+				maps.Copy(merged, fields)
+			}
+		}
+		return merged
+	case len(schema.OneOf) > 0:
+		return g.schemaValue(schema.OneOf[g.rng.Intn(len(schema.OneOf))], depth+1)
+	case len(schema.AnyOf) > 0:
+		return g.schemaValue(schema.AnyOf[g.rng.Intn(len(schema.AnyOf))], depth+1)
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		value := make(map[string]any, len(schema.Properties))
+		for name, property := range schema.Properties {
+			if resolved := g.document.schema(property); resolved != nil && !resolved.ReadOnly {
+				value[name] = g.schemaValue(resolved, depth+1)
+			}
+		}
+		return value
+	case "array":
+		low := derefOr(schema.MinItems, 1)
+		high := max(derefOr(schema.MaxItems, low+2), low)
+		items := make([]any, low+g.rng.Intn(high-low+1))
+		for i := range items {
+			items[i] = g.schemaValue(schema.Items, depth+1)
+		}
+		return items
+	case "integer":
+		low, high := schema.bounds(1, 1000, 1)
+		return int64(low) + g.rng.Int63n(int64(high)-int64(low)+1)
+	case "number":
+		low, high := schema.bounds(0, 1000, 0.01)
+		return json.Number(strconv.FormatFloat(low+g.rng.Float64()*(high-low), 'f', 2, 64))
+	case "boolean":
+		return g.rng.Intn(2) == 1
+	default:
+		return g.stringValue(schema)
+	}
+}
+
+// validateSchema checks that the bounds of every schema values are synthesized from can be satisfied, walking
+// the schema the way schemaValue does.
+func (d *openAPIDocument) validateSchema(schema *openAPISchema, depth int) error {
+	schema = d.schema(schema)
+	if schema == nil || depth > maxSchemaDepth || schema.Example != nil || len(schema.Examples) > 0 ||
+		schema.Const != nil || schema.Default != nil || len(schema.Enum) > 0 {
+		return nil
+	}
+	if composed := slices.Concat(schema.AllOf, schema.OneOf, schema.AnyOf); len(composed) > 0 {
+		for _, part := range composed {
+			if err := d.validateSchema(part, depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch schemaType(schema) {
+	case "object":
+		for _, name := range slices.Sorted(maps.Keys(schema.Properties)) {
+			if err := d.validateSchema(schema.Properties[name], depth+1); err != nil {
+				return fmt.Errorf("property %s: %w", name, err)
+			}
+		}
+	case "array":
+		if derefOr(schema.MinItems, 0) < 0 || derefOr(schema.MaxItems, 0) < 0 {
+			return fmt.Errorf("minItems and maxItems must be >= 0")
+		}
+		return d.validateSchema(schema.Items, depth+1)
+	case "integer":
+		low, high := schema.bounds(1, 1000, 1)
+		if low < math.MinInt64 || high >= math.MaxInt64 || uint64(int64(high))-uint64(int64(low)) >= math.MaxInt64 {
+			return fmt.Errorf("integer range [%g, %g] is too wide to generate values from", low, high)
+		}
+	case "number":
+		if low, high := schema.bounds(0, 1000, 0.01); math.IsInf(high-low, 0) {
+			return fmt.Errorf("number range [%g, %g] is too wide to generate values from", low, high)
+		}
+	case "boolean":
+		// Booleans have no bounds.
+	default:
+		if derefOr(schema.MinLength, 0) < 0 || derefOr(schema.MaxLength, 0) < 0 {
+			return fmt.Errorf("minLength and maxLength must be >= 0")
+		}
+	}
+	return nil
+}
+
+func (g *operationGenerator) stringValue(schema *openAPISchema) string {
+	now := time.Now().UTC()
+	switch schema.Format {
+	case "date":
+		return now.Format(time.DateOnly)
+	case "date-time":
+		return now.Format(time.RFC3339)
+	case "uuid":
+		return uuid.NewString()
+	case "email":
+		return fmt.Sprintf("user%d@example.com", g.rng.Intn(100000))
+	case "uri", "url":
+		return fmt.Sprintf("https://example.com/%d", g.rng.Intn(100000))
+	case "ipv4":
+		return fmt.Sprintf("10.%d.%d.%d", g.rng.Intn(256), g.rng.Intn(256), 1+g.rng.Intn(254))
+	}
+	low := derefOr(schema.MinLength, 1)
+	high := max(min(derefOr(schema.MaxLength, 12), low+16), low)
+	value := make([]byte, low+g.rng.Intn(high-low+1))
+	for i := range value {
+		value[i] = defaultRandomStringCharset[g.rng.Intn(len(defaultRandomStringCharset))]
+	}
+	return string(value)
+}
+
+// bounds returns the inclusive range a numeric schema allows, with step as the smallest increment past an
+// exclusive bound.
+func (s *openAPISchema) bounds(defaultLow float64, defaultSpan float64, step float64) (float64, float64) {
+	low, high := defaultLow, defaultLow+defaultSpan
+	if s.Minimum != nil {
+		low = *s.Minimum
+		if exclusive, ok := s.ExclusiveMinimum.(bool); ok && exclusive {
+			low += step
+		}
+	}
+	if bound, ok := numericBound(s.ExclusiveMinimum); ok {
+		low = bound + step
+	}
+	if s.Maximum != nil {
+		high = *s.Maximum
+		if exclusive, ok := s.ExclusiveMaximum.(bool); ok && exclusive {
+			high -= step
+		}
+	} else if s.Minimum != nil {
+		high = low + defaultSpan
+	}
+	if bound, ok := numericBound(s.ExclusiveMaximum); ok {
+		high = bound - step
+	}
+	return low, max(high, low)
+}
+
+func numericBound(value any) (float64, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	bound, err := number.Float64()
+	return bound, err == nil
+}
+
+// schemaType returns the schema's type, inferring object and array schemas that leave it out.
+func schemaType(schema *openAPISchema) string {
+	switch declared := schema.Type.(type) {
+	case string:
+		return declared
+	case []any:
+		for _, candidate := range declared {
+			if name, ok := candidate.(string); ok && name != "null" {
+				return name
+			}
+		}
+	}
+	if schema.Properties != nil {
+		return "object"
+	}
+	if schema.Items != nil {
+		return "array"
+	}
+	return "string"
+}
+
+func (d *openAPIDocument) schema(schema *openAPISchema) *openAPISchema {
+	for depth := 0; schema != nil && schema.Ref != ""; depth++ {
+		if depth > maxSchemaDepth {
+			return nil
+		}
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+func (d *openAPIDocument) parameter(parameter *openAPIParameter) (*openAPIParameter, error) {
+	if parameter.Ref == "" {
+		return parameter, nil
+	}
+	resolved, ok := d.Components.Parameters[strings.TrimPrefix(parameter.Ref, "#/components/parameters/")]
+	if !ok || resolved.Ref != "" {
+		return nil, fmt.Errorf("unresolved parameter reference %s", parameter.Ref)
+	}
+	return resolved, nil
+}
+
+func (d *openAPIDocument) requestBody(requestBody *openAPIRequestBody) (*openAPIRequestBody, error) {
+	if requestBody.Ref == "" {
+		return requestBody, nil
+	}
+	resolved, ok := d.Components.RequestBodies[strings.TrimPrefix(requestBody.Ref, "#/components/requestBodies/")]
+	if !ok || resolved.Ref != "" {
+		return nil, fmt.Errorf("unresolved request body reference %s", requestBody.Ref)
+	}
+	return resolved, nil
+}
+
+// firstExample returns the value of the first named example, in name order.
+func (d *openAPIDocument) firstExample(examples map[string]*openAPIExample) (any, bool) {
+	for _, name := range slices.Sorted(maps.Keys(examples)) {
+		example := examples[name]
+		if example.Ref != "" {
+			example = d.Components.Examples[strings.TrimPrefix(example.Ref, "#/components/examples/")]
+		}
+		if example != nil && example.Value != nil {
+			return example.Value, true
+		}
+	}
+	return nil, false
+}
+
+// addQueryValue adds a parameter in the default form style: arrays repeat the name and objects add one pair per
+// property.
+func addQueryValue(values url.Values, name string, value any) {
+	switch typed := value.(type) {
+	case []any:
+		for _, item := range typed {
+			values.Add(name, scalarText(item))
+		}
+	case map[string]any:
+		for key, item := range typed {
+			values.Add(key, scalarText(item))
+		}
+	default:
+		values.Add(name, scalarText(value))
+	}
+}
+
+// joinValue formats a path, header or cookie parameter in the default simple style, with array items joined by
+// commas.
+func joinValue(value any) string {
+	if items, ok := value.([]any); ok {
+		texts := make([]string, len(items))
+		for i, item := range items {
+			texts[i] = scalarText(item)
+		}
+		return strings.Join(texts, ",")
+	}
+	return scalarText(value)
+}
+
+func scalarText(value any) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case map[string]any, []any:
+		encoded, _ := json.Marshal(typed)
+		return string(encoded)
+	default:
+		return fmt.Sprint(typed)
+	}
+}
+
+func derefOr(value *int, fallback int) int {
+	if value == nil {
+		return fallback
+	}
+	return *value
+}
@@ -0,0 +1,274 @@
+package requests
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+const testOpenAPI = `openapi: 3.0.3
+servers:
+  - url: https://{region}.example.com/v1
+    variables:
+      region:
+        default: eu
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      tags: [pets]
+      parameters:
+        - name: limit
+          in: query
+          required: true
+          schema: {type: integer, minimum: 1, maximum: 50}
+        - name: sort
+          in: query
+          schema: {type: string, example: name}
+        - name: cursor
+          in: query
+          schema: {type: string}
+        - name: X-Tenant
+          in: header
+          required: true
+          example: acme
+    post:
+      operationId: createPet
+      tags: [pets, writes]
+      requestBody:
+        $ref: '#/components/requestBodies/NewPet'
+  /pets/{petId}:
+    parameters:
+      - $ref: '#/components/parameters/PetId'
+    get:
+      operationId: getPet
+      tags: [pets]
+    delete:
+      tags: [admin]
+  /health:
+    get:
+      operationId: health
+      tags: [ops]
+components:
+  parameters:
+    PetId:
+      name: petId
+      in: path
+      required: true
+      schema: {type: string, format: uuid}
+  requestBodies:
+    NewPet:
+      required: true
+      content:
+        application/json:
+          schema:
+            $ref: '#/components/schemas/NewPet'
+  schemas:
+    NewPet:
+      type: object
+      required: [name]
+      properties:
+        id: {type: integer, readOnly: true}
+        name: {type: string, minLength: 3, maxLength: 8}
+        kind: {type: string, enum: [cat, dog]}
+        tags:
+          type: array
+          minItems: 2
+          maxItems: 2
+          items: {type: string, example: friendly}
+        owner:
+          $ref: '#/components/schemas/Owner'
+    Owner:
+      type: object
+      properties:
+        email: {type: string, format: email}
+        age: {type: integer, minimum: 18, exclusiveMaximum: true, maximum: 20}
+`
+
+func writeOpenAPI(t *testing.T, document string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "openapi.yaml")
+	if err := os.WriteFile(path, []byte(document), 0o600); err != nil {
+		t.Fatalf("unable to write OpenAPI document: %v", err)
+	}
+	return path
+}
+
+func TestOpenAPISourceGeneratesOperations(t *testing.T) {
+	source, err := NewOpenAPISource(writeOpenAPI(t, testOpenAPI), OpenAPIOptions{IncludeTags: []string{"pets"}})
+	if err != nil {
+		t.Fatalf("unexpected OpenAPI source error: %v", err)
+	}
+	byName := make(map[string]int)
+	for _, request := range nextRequests(t, source, 30) {
+		byName[request.Name]++
+		switch request.Name {
+		case "listPets":
+			query, _ := url.ParseQuery(request.QueryString)
+			limit, err := strconv.Atoi(query.Get("limit"))
+			if request.Method != "GET" || request.Path != "/v1/pets" || err != nil || limit < 1 || limit > 50 {
+				t.Fatalf("unexpected listPets request: %+v", request)
+			}
+			if query.Get("sort") != "name" || query.Has("cursor") {
+				t.Fatalf("expected only required and exemplified query parameters, got %s", request.QueryString)
+			}
+			if request.Headers["X-Tenant"][0] != "acme" {
+				t.Fatalf("expected the header parameter example, got %v", request.Headers)
+			}
+		case "getPet":
+			if request.Method != "GET" || len(request.Path) != len("/v1/pets/")+36 {
+				t.Fatalf("expected a UUID pet ID, got %+v", request)
+			}
+		case "createPet":
+			if request.Method != "POST" || request.Path != "/v1/pets" ||
+				request.Headers["Content-Type"][0] != "application/json" {
+				t.Fatalf("unexpected createPet request: %+v", request)
+			}
+			pet := struct {
+				ID    *int     `json:"id"`
+				Name  string   `json:"name"`
+				Kind  string   `json:"kind"`
+				Tags  []string `json:"tags"`
+				Owner struct {
+					Email string `json:"email"`
+					Age   int    `json:"age"`
+				} `json:"owner"`
+			}{}
+			if err := json.Unmarshal([]byte(request.Body), &pet); err != nil {
+				t.Fatalf("expected a JSON body, got %q: %v", request.Body, err)
+			}
+			if pet.ID != nil || len(pet.Name) < 3 || len(pet.Name) > 8 || (pet.Kind != "cat" && pet.Kind != "dog") {
+				t.Fatalf("expected the body to satisfy the schema, got %s", request.Body)
+			}
+			if len(pet.Tags) != 2 || pet.Tags[0] != "friendly" || pet.Owner.Email == "" ||
+				pet.Owner.Age < 18 || pet.Owner.Age > 19 {
+				t.Fatalf("expected nested values to satisfy the schema, got %s", request.Body)
+			}
+		default:
+			t.Fatalf("unexpected operation %s outside the pets tag", request.Name)
+		}
+	}
+	if byName["listPets"] != 10 || byName["getPet"] != 10 || byName["createPet"] != 10 {
+		t.Fatalf("expected equal weights by default, got %v", byName)
+	}
+}
+
+func TestOpenAPISourceFiltersAndWeights(t *testing.T) {
+	source, err := NewOpenAPISource(writeOpenAPI(t, testOpenAPI), OpenAPIOptions{
+		ExcludeTags:       []string{"writes"},
+		ExcludeOperations: []string{"health"},
+		Weights:           map[string]int{"listPets": 3, "DELETE /pets/{petId}": 2},
+	})
+	if err != nil {
+		t.Fatalf("unexpected OpenAPI source error: %v", err)
+	}
+	byName := make(map[string]int)
+	for _, request := range nextRequests(t, source, 12) {
+		byName[request.Name]++
+	}
+	if byName["listPets"] != 6 || byName["DELETE /pets/{petId}"] != 4 || byName["getPet"] != 2 || len(byName) != 3 {
+		t.Fatalf("expected a 3:2:1 mix of the remaining operations, got %v", byName)
+	}
+
+	source, err = NewOpenAPISource(writeOpenAPI(t, testOpenAPI), OpenAPIOptions{
+		IncludeOperations: []string{"health"},
+		IncludeTags:       []string{"admin"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected OpenAPI source error: %v", err)
+	}
+	byName = make(map[string]int)
+	for _, request := range nextRequests(t, source, 4) {
+		byName[request.Name]++
+	}
+	if byName["health"] != 2 || byName["DELETE /pets/{petId}"] != 2 {
+		t.Fatalf("expected the included operation and tag, got %v", byName)
+	}
+}
+
+func TestOpenAPISourceReplaysEscapedPaths(t *testing.T) {
+	source, err := NewOpenAPISource(writeOpenAPI(t, `openapi: 3.0.3
+servers:
+  - url: https://shop.example.com/caf%C3%A9/
+paths:
+  /search/{term}:
+    get:
+      operationId: search
+      parameters:
+        - name: term
+          in: path
+          required: true
+          example: a b
+`), OpenAPIOptions{})
+	if err != nil {
+		t.Fatalf("unexpected OpenAPI source error: %v", err)
+	}
+
+	request := nextRequests(t, source, 1)[0]
+	assertRequest(t, request, "GET", "/café/search/a b")
+	if uri := replayedRequestURI(t, request); uri != "/caf%C3%A9/search/a%20b" {
+		t.Fatalf("expected the path to be escaped once, got %s", uri)
+	}
+}
+
+func TestOpenAPISourceRejectsInvalidInput(t *testing.T) {
+	path := writeOpenAPI(t, testOpenAPI)
+	if _, err := NewOpenAPISource(path, OpenAPIOptions{IncludeTags: []string{"missing"}}); err == nil {
+		t.Fatalf("expected error when no operation matches the filters")
+	}
+	if _, err := NewOpenAPISource(path, OpenAPIOptions{Weights: map[string]int{"unknown": 2}}); err == nil {
+		t.Fatalf("expected error for a weight on an unknown operation")
+	}
+	if _, err := NewOpenAPISource(path, OpenAPIOptions{Weights: map[string]int{"health": 0}}); err == nil {
+		t.Fatalf("expected error for a non-positive weight")
+	}
+
+	undefined := writeOpenAPI(t, `openapi: 3.0.3
+paths:
+  /pets/{petId}:
+    get:
+      operationId: getPet
+`)
+	if _, err := NewOpenAPISource(undefined, OpenAPIOptions{}); err == nil {
+		t.Fatalf("expected error for an undefined path parameter")
+	}
+
+	binary := writeOpenAPI(t, `openapi: 3.0.3
+paths:
+  /upload:
+    put:
+      operationId: upload
+      requestBody:
+        required: true
+        content:
+          application/octet-stream: {}
+`)
+	if _, err := NewOpenAPISource(binary, OpenAPIOptions{}); err == nil {
+		t.Fatalf("expected error for a required body that cannot be generated")
+	}
+
+	for _, schema := range []string{
+		"{type: array, minItems: -1, items: {type: string}}",
+		"{type: array, items: {type: string, minLength: -2}}",
+		"{type: object, properties: {count: {type: integer, minimum: -9.3e18, maximum: 9.3e18}}}",
+		"{type: integer, minimum: 1e19}",
+		"{type: number, minimum: -1.7e308, maximum: 1.7e308}",
+	} {
+		bounded := writeOpenAPI(t, `openapi: 3.0.3
+paths:
+  /items:
+    post:
+      operationId: createItem
+      requestBody:
+        content:
+          application/json:
+            schema: `+schema+`
+`)
+		if _, err := NewOpenAPISource(bounded, OpenAPIOptions{}); err == nil {
+			t.Fatalf("expected error for the unsatisfiable schema %s", schema)
+		}
+	}
+}
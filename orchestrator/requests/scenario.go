@@ -0,0 +1,45 @@
+package requests
+
+import (
+	"fmt"
+	"github.com/PeladoCollado/imager/types"
+	"sync"
+)
+
+// ScenarioSource is an instance of RequestSource that cycles through a fixed list of scenarios. Each RequestSpec
+// it returns is a whole scenario, which executors run as one session.
+type ScenarioSource struct {
+	lock      sync.Mutex
+	scenarios []types.RequestSpec
+	next      int
+}
+
+func NewScenarioSource(scenarios []types.RequestSpec) (types.RequestSource, error) {
+	if len(scenarios) == 0 {
+		return nil, fmt.Errorf("at least one scenario is required")
+	}
+	for i, scenario := range scenarios {
+		if scenario.Scenario == nil {
+			return nil, fmt.Errorf("request %d is not a scenario", i+1)
+		}
+		if err := scenario.Scenario.Validate(); err != nil {
+			return nil, fmt.Errorf("scenario %s: %w", scenario.Name, err)
+		}
+	}
+	return &ScenarioSource{scenarios: scenarios}, nil
+}
+
+func (s *ScenarioSource) Next() (types.RequestSpec, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	next := s.scenarios[s.next]
+	s.next = (s.next + 1) % len(s.scenarios)
+	return next, nil
+}
+
+func (s *ScenarioSource) Reset() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.next = 0
+	return nil
+}
@@ -0,0 +1,46 @@
+package requests
+
+import (
+	"github.com/PeladoCollado/imager/types"
+	"testing"
+)
+
+func TestScenarioSourceCyclesThroughScenarios(t *testing.T) {
+	steps := []types.ScenarioStep{{RequestSpec: types.RequestSpec{Path: "/login"}}}
+	source, err := NewScenarioSource([]types.RequestSpec{
+		{Name: "browse", Scenario: &types.Scenario{Steps: steps}},
+		{Name: "checkout", Scenario: &types.Scenario{Steps: steps}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected scenario source error: %v", err)
+	}
+
+	for _, expected := range []string{"browse", "checkout", "browse"} {
+		next, err := source.Next()
+		if err != nil {
+			t.Fatalf("unexpected next error: %v", err)
+		}
+		if next.Name != expected || next.Scenario == nil {
+			t.Fatalf("expected scenario %s, got %+v", expected, next)
+		}
+	}
+	if err := source.Reset(); err != nil {
+		t.Fatalf("unexpected reset error: %v", err)
+	}
+	if next, _ := source.Next(); next.Name != "browse" {
+		t.Fatalf("expected reset to restart at browse, got %s", next.Name)
+	}
+}
+
+func TestNewScenarioSourceValidatesScenarios(t *testing.T) {
+	invalid := [][]types.RequestSpec{
+		nil,
+		{{Name: "plain", Path: "/"}},
+		{{Name: "empty", Scenario: &types.Scenario{}}},
+	}
+	for _, scenarios := range invalid {
+		if _, err := NewScenarioSource(scenarios); err == nil {
+			t.Fatalf("expected scenarios %+v to be rejected", scenarios)
+		}
+	}
+}
@@ -1,57 +1,534 @@
 package requests
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"github.com/PeladoCollado/imager/types"
+	"github.com/klauspost/compress/zstd"
 	"io"
+	"math"
+	mathrand "math/rand"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 )
 
-// StreamReader is an instance of RequestSource that iterates over a list of JSON request specs.
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+	// maxShuffledFrameBytes caps the decoded size of a compressed frame a shuffled reader accepts, since every
+	// shuffled read decodes from the start of the record's frame.
+	maxShuffledFrameBytes int64 = 4 << 20
+)
+
+// StreamOptions configures how a StreamReader walks its file.
+type StreamOptions struct {
+	// StopAtEOF returns types.ErrSourceExhausted at the end of the file, which ends the run, instead of looping.
+	StopAtEOF bool
+	// Shuffle replays the records in a random order, reshuffled on every pass.
+	Shuffle bool
+	// Seed makes the shuffled order reproducible. Zero seeds from the clock.
+	Seed int64
+	// Skip leaves out the first Skip records of the file on every pass.
+	Skip int
+}
+
+// StreamReader is an instance of RequestSource that iterates over JSON request specs, one per line. It reads a
+// single file, or a directory of shard files in name order, and each file may be plain, gzip or zstd compressed. It
+// is safe for concurrent use. A malformed line, or one whose body file is missing, is returned as a
+// *types.RecordError and skipped.
+//
+// The offset of every record is indexed once, when the reader is created. Compressed shards are never decoded to
+// disk: the index records where each gzip member or zstd frame starts, and a record is read by decoding from the
+// start of the frame it begins in. Records read in order continue from the previous one. A shuffled read decodes
+// from the record's frame, so a shuffled reader rejects compressed frames larger than 4MiB decoded.
 type StreamReader struct {
-	decoder *json.Decoder
-	r       io.Reader
+	lock sync.Mutex
+	name string
+	opts StreamOptions
+
+	shards  []streamShard
+	frames  []streamFrame
+	records []streamRecord
+
+	// next is the position in the current pass, valid counts the well-formed records of the pass and order holds
+	// its shuffled order.
+	next  int
+	valid int
+	order []int
+	rng   *mathrand.Rand
+
+	// cursor is the decoded stream the previous record was read from, which the next record continues from when it
+	// lies further along the same shard.
+	cursor streamCursor
+	gzip   *gzip.Reader
+	zstd   *zstd.Decoder
+}
+
+type streamCodec int
+
+const (
+	codecNone streamCodec = iota
+	codecGzip
+	codecZstd
+)
+
+// streamShard is one file of the corpus, held open for the life of the reader. decoded is the size of its
+// decoded content.
+type streamShard struct {
+	name    string
+	file    *os.File
+	size    int64
+	decoded int64
+	codec   streamCodec
+}
+
+// streamFrame is a point of a shard that decoding can start from: the start of the file, or of a gzip member or
+// zstd frame. start is its offset in the file and decoded its offset in the shard's decoded content.
+type streamFrame struct {
+	shard   int32
+	start   int64
+	decoded int64
+}
+
+// streamRecord locates one record by its offset in the decoded content of its shard and the frame it starts in.
+// It is kept small since large corpora index millions of records. checked is set once the files its body
+// references have been found, so they are looked up once rather than on every pass.
+type streamRecord struct {
+	offset  int64
+	frame   int32
+	line    int32
+	length  int32
+	checked bool
+}
+
+// streamCursor is a decoder positioned at pos in the decoded content of a shard.
+type streamCursor struct {
+	reader io.Reader
+	shard  int32
+	pos    int64
 }
 
 func NewFileReader(file string) (types.RequestSource, error) {
-	fh, err := os.Open(file)
+	return NewStreamReader(file, StreamOptions{})
+}
+
+// NewStreamReader indexes the file or directory of shards at path.
+func NewStreamReader(path string, opts StreamOptions) (types.RequestSource, error) {
+	if opts.Skip < 0 {
+		return nil, fmt.Errorf("skip must be >= 0")
+	}
+	files, err := shardFiles(path)
 	if err != nil {
 		return nil, err
 	}
-	decoder := json.NewDecoder(fh)
-	return &StreamReader{
-		decoder: decoder,
-		r:       fh,
-	}, nil
+	s := &StreamReader{name: path, opts: opts}
+	for _, file := range files {
+		if err := s.indexShard(file); err != nil {
+			s.closeShards()
+			return nil, fmt.Errorf("index %s: %w", file, err)
+		}
+	}
+	if len(s.records) == 0 {
+		s.closeShards()
+		return nil, fmt.Errorf("%s has no records", path)
+	}
+	if len(s.records) <= opts.Skip {
+		s.closeShards()
+		return nil, fmt.Errorf("%s has %d records, none left after skipping %d", path, len(s.records), opts.Skip)
+	}
+	s.records = s.records[opts.Skip:]
+	if opts.Shuffle {
+		if err := s.checkShuffledFrames(); err != nil {
+			s.closeShards()
+			return nil, err
+		}
+		seed := opts.Seed
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		s.rng = mathrand.New(mathrand.NewSource(seed))
+	}
+	return s, nil
 }
 
-func (s *StreamReader) Next() (types.RequestSpec, error) {
-	for {
-		next := types.RequestSpec{}
-		err := s.decoder.Decode(&next)
-		if errors.Is(err, io.EOF) {
-			if resetErr := s.Reset(); resetErr != nil {
-				return types.RequestSpec{}, resetErr
-			}
+// checkShuffledFrames rejects compressed frames too large to decode from for every shuffled read.
+func (s *StreamReader) checkShuffledFrames() error {
+	for i, frame := range s.frames {
+		shard := s.shards[frame.shard]
+		if shard.codec == codecNone {
 			continue
 		}
-		if err != nil {
-			return types.RequestSpec{}, err
+		end := shard.decoded
+		if i+1 < len(s.frames) && s.frames[i+1].shard == frame.shard {
+			end = s.frames[i+1].decoded
+		}
+		if size := end - frame.decoded; size > maxShuffledFrameBytes {
+			return fmt.Errorf("%s: the compressed frame at byte %d decodes to %d bytes, more than the %d a shuffled "+
+				"read decodes per record; recompress it in small frames, e.g. with bgzip or seekable zstd, or split it "+
+				"into shards", shard.name, frame.start, size, maxShuffledFrameBytes)
 		}
-		return next, nil
 	}
+	return nil
 }
 
-func (s *StreamReader) Reset() error {
-	if seeker, ok := s.r.(io.Seeker); ok {
-		_, err := seeker.Seek(0, io.SeekStart)
+func (s *StreamReader) closeShards() {
+	for _, shard := range s.shards {
+		shard.file.Close()
+	}
+}
+
+// shardFiles returns the file at path, or the regular, non-hidden files of the directory at path in name order.
+func shardFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s has no shard files", path)
+	}
+	return files, nil
+}
+
+// indexShard records the frames of a shard and the offset of every non-blank line of its decoded content.
+func (s *StreamReader) indexShard(file string) error {
+	fh, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	info, err := fh.Stat()
+	if err != nil {
+		fh.Close()
+		return err
+	}
+	shard := streamShard{name: file, file: fh, size: info.Size()}
+	input := &countingReader{Reader: bufio.NewReaderSize(fh, 64*1024)}
+	magic, _ := input.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		shard.codec = codecGzip
+	case bytes.HasPrefix(magic, zstdMagic):
+		shard.codec = codecZstd
+	}
+	s.shards = append(s.shards, shard)
+	index := &lineIndex{reader: s, shard: int32(len(s.shards) - 1), frame: int32(len(s.frames)), blank: true}
+
+	switch shard.codec {
+	case codecNone:
+		index.mark(0)
+		if _, err := io.Copy(index, input); err != nil {
+			return err
+		}
+	case codecGzip:
+		// Each member is decoded on its own to find where the next one starts. The gzip reader reads its input a
+		// byte at a time through io.ByteReader, so it never consumes past the end of a member.
+		gz := new(gzip.Reader)
+		defer gz.Close()
+		for {
+			index.mark(input.count)
+			if err := gz.Reset(input); err != nil {
+				return err
+			}
+			gz.Multistream(false)
+			if _, err := io.Copy(index, gz); err != nil {
+				return err
+			}
+			if _, err := input.Peek(1); err == io.EOF {
+				break
+			}
+		}
+	case codecZstd:
+		zr, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
 		if err != nil {
 			return err
 		}
-		s.decoder = json.NewDecoder(s.r)
+		defer zr.Close()
+		for start := int64(0); start < shard.size; {
+			size, err := zstdFrameSize(fh, start)
+			if err != nil {
+				return err
+			}
+			index.mark(start)
+			if err := zr.Reset(io.NewSectionReader(fh, start, size)); err != nil {
+				return err
+			}
+			if _, err := io.Copy(index, zr); err != nil {
+				return err
+			}
+			start += size
+		}
+	}
+	if err := index.end(); err != nil {
+		return err
+	}
+	s.shards[index.shard].decoded = index.offset
+	return nil
+}
+
+// countingReader counts the bytes read from a buffered reader.
+type countingReader struct {
+	*bufio.Reader
+	count int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	c.count += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.Reader.ReadByte()
+	if err == nil {
+		c.count++
+	}
+	return b, err
+}
+
+// zstdFrameSize returns the size of the zstd frame, or skippable frame, at start, walking its block headers
+// without decoding it.
+func zstdFrameSize(file io.ReaderAt, start int64) (int64, error) {
+	header := make([]byte, 8)
+	if _, err := file.ReadAt(header[:4], start); err != nil {
+		return 0, fmt.Errorf("read zstd frame at %d: %w", start, err)
+	}
+	magic := binary.LittleEndian.Uint32(header)
+	if magic&0xfffffff0 == 0x184d2a50 {
+		if _, err := file.ReadAt(header[4:8], start+4); err != nil {
+			return 0, fmt.Errorf("read zstd frame at %d: %w", start, err)
+		}
+		return 8 + int64(binary.LittleEndian.Uint32(header[4:])), nil
+	}
+	if magic != binary.LittleEndian.Uint32(zstdMagic) {
+		return 0, fmt.Errorf("no zstd frame at %d", start)
+	}
+	if _, err := file.ReadAt(header[:1], start+4); err != nil {
+		return 0, fmt.Errorf("read zstd frame at %d: %w", start, err)
+	}
+	descriptor := header[0]
+	singleSegment := descriptor>>5&1 == 1
+	headerSize := int64(1 + []int{0, 1, 2, 4}[descriptor&3])
+	if !singleSegment {
+		headerSize++
+	}
+	contentSize := []int64{0, 2, 4, 8}[descriptor>>6]
+	if contentSize == 0 && singleSegment {
+		contentSize = 1
+	}
+	position := start + 4 + headerSize + contentSize
+	for last := false; !last; {
+		if _, err := file.ReadAt(header[:3], position); err != nil {
+			return 0, fmt.Errorf("read zstd block at %d: %w", position, err)
+		}
+		block := uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16
+		last = block&1 == 1
+		size := int64(block >> 3)
+		switch block >> 1 & 3 {
+		case 1:
+			// An RLE block holds the one byte it repeats.
+			size = 1
+		case 3:
+			return 0, fmt.Errorf("invalid zstd block at %d", position)
+		}
+		position += 3 + size
+	}
+	if descriptor>>2&1 == 1 {
+		position += 4
+	}
+	return position - start, nil
+}
+
+// lineIndex is written the decoded content of a shard, in any chunks, and records its non-blank lines.
+type lineIndex struct {
+	reader *StreamReader
+	shard  int32
+	frame  int32
+
+	// start is the offset of the current line and offset of the end of the content written so far.
+	start  int64
+	offset int64
+	line   int32
+	blank  bool
+}
+
+// mark adds a frame at the given offset of the shard file, starting at the end of the content written so far.
+func (l *lineIndex) mark(start int64) {
+	l.reader.frames = append(l.reader.frames, streamFrame{shard: l.shard, start: start, decoded: l.offset})
+}
+
+func (l *lineIndex) Write(content []byte) (int, error) {
+	written := len(content)
+	for len(content) > 0 {
+		chunk := content
+		newline := bytes.IndexByte(content, '\n')
+		if newline >= 0 {
+			chunk = content[:newline+1]
+		}
+		if l.blank && len(bytes.TrimSpace(chunk)) > 0 {
+			l.blank = false
+		}
+		l.offset += int64(len(chunk))
+		content = content[len(chunk):]
+		if newline >= 0 {
+			if err := l.end(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// end records the current line, if it is not blank, and starts the next one.
+func (l *lineIndex) end() error {
+	length := l.offset - l.start
+	if length == 0 {
 		return nil
-	} else {
-		return io.EOF
 	}
+	if l.line == math.MaxInt32 || length > math.MaxInt32 {
+		return fmt.Errorf("line %d is too long or follows too many lines", l.line+1)
+	}
+	l.line++
+	if !l.blank {
+		// The line is decoded from the last frame that starts at or before it.
+		frames := l.reader.frames
+		for int(l.frame)+1 < len(frames) && frames[l.frame+1].decoded <= l.start {
+			l.frame++
+		}
+		l.reader.records = append(l.reader.records, streamRecord{offset: l.start, frame: l.frame, line: l.line,
+			length: int32(length)})
+	}
+	l.start = l.offset
+	l.blank = true
+	return nil
+}
+
+func (s *StreamReader) Next() (types.RequestSpec, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.next >= len(s.records) {
+		if s.opts.StopAtEOF {
+			return types.RequestSpec{}, types.ErrSourceExhausted
+		}
+		if s.valid == 0 {
+			return types.RequestSpec{}, fmt.Errorf("%s has no valid records", s.name)
+		}
+		s.reset()
+	}
+	position := s.next
+	if s.rng != nil {
+		if s.order == nil {
+			s.order = s.rng.Perm(len(s.records))
+		}
+		position = s.order[s.next]
+	}
+	s.next++
+
+	record := s.records[position]
+	shard := s.shards[s.frames[record.frame].shard]
+	content, err := s.read(record)
+	if err != nil {
+		return types.RequestSpec{}, fmt.Errorf("%s: read line %d: %w", shard.name, record.line, err)
+	}
+	next := types.RequestSpec{}
+	err = json.Unmarshal(bytes.TrimSpace(content), &next)
+	if err == nil && !record.checked {
+		if err = next.ValidateBody(); err == nil {
+			s.records[position].checked = true
+		}
+	}
+	if err != nil {
+		return types.RequestSpec{}, fmt.Errorf("%s: %w", shard.name, &types.RecordError{Line: int(record.line), Err: err})
+	}
+	s.valid++
+	return next, nil
+}
+
+// read returns the content of a record. A plain shard is read at the record's offset. A compressed one is decoded
+// on from the cursor when the record lies ahead of it in the same shard, and from the record's frame otherwise.
+func (s *StreamReader) read(record streamRecord) ([]byte, error) {
+	frame := s.frames[record.frame]
+	shard := s.shards[frame.shard]
+	content := make([]byte, record.length)
+	if shard.codec == codecNone {
+		_, err := shard.file.ReadAt(content, record.offset)
+		return content, err
+	}
+	cursor := s.cursor
+	if cursor.reader == nil || cursor.shard != frame.shard || cursor.pos < frame.decoded || cursor.pos > record.offset {
+		reader, err := s.decode(shard, frame.start)
+		if err != nil {
+			return nil, err
+		}
+		cursor = streamCursor{reader: reader, shard: frame.shard, pos: frame.decoded}
+	}
+	// The cursor is dropped until the read succeeds, so a failed read starts over from the frame.
+	s.cursor = streamCursor{}
+	if _, err := io.CopyN(io.Discard, cursor.reader, record.offset-cursor.pos); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(cursor.reader, content); err != nil {
+		return nil, err
+	}
+	cursor.pos = record.offset + int64(record.length)
+	s.cursor = cursor
+	return content, nil
+}
+
+// decode returns the decoded content of a compressed shard from the frame at start to the end of the file. The
+// decoders are reused from one frame to the next.
+func (s *StreamReader) decode(shard streamShard, start int64) (io.Reader, error) {
+	compressed := io.NewSectionReader(shard.file, start, shard.size-start)
+	if shard.codec == codecGzip {
+		if s.gzip == nil {
+			s.gzip = new(gzip.Reader)
+		}
+		if err := s.gzip.Reset(bufio.NewReader(compressed)); err != nil {
+			return nil, err
+		}
+		return s.gzip, nil
+	}
+	if s.zstd == nil {
+		decoder, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		s.zstd = decoder
+	}
+	if err := s.zstd.Reset(compressed); err != nil {
+		return nil, err
+	}
+	return s.zstd, nil
+}
+
+func (s *StreamReader) Reset() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.reset()
+	return nil
+}
+
+func (s *StreamReader) reset() {
+	s.next = 0
+	s.valid = 0
+	s.order = nil
 }
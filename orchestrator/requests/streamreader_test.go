@@ -1,9 +1,17 @@
 package requests
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
 	"github.com/PeladoCollado/imager/types"
+	"github.com/klauspost/compress/zstd"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -39,6 +47,364 @@ func TestStreamReaderLoopsAtEOF(t *testing.T) {
 	assertRequest(t, looped, "GET", "/first")
 }
 
+func writeRequestFile(t *testing.T, lines ...string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "requests.json")
+	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatalf("unable to write temp request file: %v", err)
+	}
+	return file
+}
+
+func numberedRequests(count int) []string {
+	lines := make([]string, count)
+	for i := range lines {
+		lines[i] = fmt.Sprintf(`{"method":"GET","path":"/%d"}`, i+1)
+	}
+	return lines
+}
+
+func TestStreamReaderStopsAtEOF(t *testing.T) {
+	source, err := NewStreamReader(writeRequestFile(t, numberedRequests(2)...), StreamOptions{StopAtEOF: true})
+	if err != nil {
+		t.Fatalf("unable to create stream reader: %v", err)
+	}
+	nextRequests(t, source, 2)
+	for i := 0; i < 2; i++ {
+		if _, err := source.Next(); !errors.Is(err, types.ErrSourceExhausted) {
+			t.Fatalf("expected the source to stay exhausted, got %v", err)
+		}
+	}
+	if err := source.Reset(); err != nil {
+		t.Fatalf("unexpected reset error: %v", err)
+	}
+	first, err := source.Next()
+	if err != nil {
+		t.Fatalf("unexpected error after reset: %v", err)
+	}
+	assertRequest(t, first, "GET", "/1")
+}
+
+func TestStreamReaderSkipsRecords(t *testing.T) {
+	lines := append([]string{""}, numberedRequests(4)...)
+	source, err := NewStreamReader(writeRequestFile(t, lines...), StreamOptions{Skip: 2})
+	if err != nil {
+		t.Fatalf("unable to create stream reader: %v", err)
+	}
+	requests := nextRequests(t, source, 3)
+	assertRequest(t, requests[0], "GET", "/3")
+	assertRequest(t, requests[1], "GET", "/4")
+	assertRequest(t, requests[2], "GET", "/3")
+
+	if _, err := NewStreamReader(writeRequestFile(t, lines...), StreamOptions{Skip: 4}); err == nil {
+		t.Fatalf("expected error when every record is skipped")
+	}
+}
+
+func TestStreamReaderShufflesWithSeed(t *testing.T) {
+	file := writeRequestFile(t, numberedRequests(20)...)
+	paths := func(opts StreamOptions) []string {
+		source, err := NewStreamReader(file, opts)
+		if err != nil {
+			t.Fatalf("unable to create stream reader: %v", err)
+		}
+		var paths []string
+		for _, request := range nextRequests(t, source, 40) {
+			paths = append(paths, request.Path)
+		}
+		return paths
+	}
+
+	first := paths(StreamOptions{Shuffle: true, Seed: 7})
+	if !slices.Equal(first, paths(StreamOptions{Shuffle: true, Seed: 7})) {
+		t.Fatalf("expected the same seed to replay the same order")
+	}
+	inOrder := paths(StreamOptions{})
+	if slices.Equal(first[:20], inOrder[:20]) {
+		t.Fatalf("expected a shuffled order, got file order")
+	}
+	if slices.Equal(first[:20], first[20:]) {
+		t.Fatalf("expected every pass to be reshuffled")
+	}
+	pass := slices.Clone(first[:20])
+	slices.Sort(pass)
+	expected := slices.Clone(inOrder[:20])
+	slices.Sort(expected)
+	if !slices.Equal(pass, expected) {
+		t.Fatalf("expected every record once per pass, got %v", first[:20])
+	}
+
+	skipped := paths(StreamOptions{Shuffle: true, Seed: 7, Skip: 18})
+	for _, path := range skipped {
+		if path != "/19" && path != "/20" {
+			t.Fatalf("expected only the records past the skipped ones, got %s", path)
+		}
+	}
+}
+
+func TestStreamReaderReportsMalformedLines(t *testing.T) {
+	file := writeRequestFile(t, `{"method":"GET","path":"/first"}`, `{"method":`, ``, `{"method":"GET","path":"/last"}`)
+	source, err := NewFileReader(file)
+	if err != nil {
+		t.Fatalf("unable to create stream reader: %v", err)
+	}
+	first, err := source.Next()
+	if err != nil {
+		t.Fatalf("unexpected error reading first request: %v", err)
+	}
+	assertRequest(t, first, "GET", "/first")
+
+	_, err = source.Next()
+	var recordErr *types.RecordError
+	if !errors.As(err, &recordErr) || recordErr.Line != 2 {
+		t.Fatalf("expected a record error on line 2, got %v", err)
+	}
+	last, err := source.Next()
+	if err != nil {
+		t.Fatalf("expected reading to continue past the malformed line, got %v", err)
+	}
+	assertRequest(t, last, "GET", "/last")
+
+	invalid, err := NewFileReader(writeRequestFile(t, `not json`))
+	if err != nil {
+		t.Fatalf("unable to create stream reader: %v", err)
+	}
+	if _, err := invalid.Next(); !errors.As(err, &recordErr) {
+		t.Fatalf("expected a record error, got %v", err)
+	}
+	if _, err := invalid.Next(); err == nil || errors.As(err, &recordErr) {
+		t.Fatalf("expected a file without valid records to fail, got %v", err)
+	}
+}
+
+func TestStreamReaderSkipsRecordsWithMissingBodyFiles(t *testing.T) {
+	body := filepath.Join(t.TempDir(), "upload.bin")
+	if err := os.WriteFile(body, []byte{0x00, 0xff}, 0o644); err != nil {
+		t.Fatalf("unable to write body file: %v", err)
+	}
+	file := writeRequestFile(t,
+		fmt.Sprintf(`{"method":"POST","path":"/present","bodyFile":%q}`, body),
+		fmt.Sprintf(`{"method":"POST","path":"/missing","bodyFile":%q}`, body+".missing"))
+	source, err := NewFileReader(file)
+	if err != nil {
+		t.Fatalf("unable to create stream reader: %v", err)
+	}
+	present, err := source.Next()
+	if err != nil {
+		t.Fatalf("unexpected error reading request: %v", err)
+	}
+	if present.BodyFile != body {
+		t.Fatalf("expected body file %s, got %s", body, present.BodyFile)
+	}
+	var recordErr *types.RecordError
+	if _, err := source.Next(); !errors.As(err, &recordErr) || recordErr.Line != 2 {
+		t.Fatalf("expected a record error on line 2, got %v", err)
+	}
+
+	// Body files are looked up once per record, not on every pass.
+	if err := os.Remove(body); err != nil {
+		t.Fatalf("unable to remove body file: %v", err)
+	}
+	if next, err := source.Next(); err != nil || next.Path != "/present" {
+		t.Fatalf("expected the checked record on the next pass, got %+v (%v)", next, err)
+	}
+	if _, err := source.Next(); !errors.As(err, &recordErr) || recordErr.Line != 2 {
+		t.Fatalf("expected a record error on line 2 again, got %v", err)
+	}
+}
+
+func TestStreamReaderIsSafeForConcurrentUse(t *testing.T) {
+	source, err := NewFileReader(writeRequestFile(t, numberedRequests(10)...))
+	if err != nil {
+		t.Fatalf("unable to create stream reader: %v", err)
+	}
+	var lock sync.Mutex
+	counts := make(map[string]int)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				next, err := source.Next()
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+					return
+				}
+				lock.Lock()
+				counts[next.Path]++
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	for path, count := range counts {
+		if count != 50 {
+			t.Fatalf("expected every record 50 times, got %d for %s", count, path)
+		}
+	}
+}
+
+func TestStreamReaderReadsCompressedShards(t *testing.T) {
+	dir := t.TempDir()
+	long := fmt.Sprintf(`{"method":"POST","path":"/long","body":"%s"}`, strings.Repeat("x", 100*1024))
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(`{"method":"GET","path":"/1"}` + "\n\n" + long + "\n"))
+	gz.Close()
+	var zstdCompressed bytes.Buffer
+	zw, err := zstd.NewWriter(&zstdCompressed)
+	if err != nil {
+		t.Fatalf("unable to create zstd writer: %v", err)
+	}
+	zw.Write([]byte(`{"method":"GET","path":"/3"}` + "\n" + `{"method":` + "\n"))
+	zw.Close()
+	shards := map[string][]byte{
+		"part-0.json.gz":  gzipped.Bytes(),
+		"part-1.json.zst": zstdCompressed.Bytes(),
+		"part-2.json":     []byte(`{"method":"GET","path":"/4"}`),
+		".part-3.json":    []byte(`{"method":"GET","path":"/hidden"}`),
+	}
+	for name, content := range shards {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0o644); err != nil {
+			t.Fatalf("unable to write shard: %v", err)
+		}
+	}
+
+	source, err := NewStreamReader(dir, StreamOptions{StopAtEOF: true})
+	if err != nil {
+		t.Fatalf("unable to create stream reader: %v", err)
+	}
+	// The shards are held open, so records are still read once the files are unlinked.
+	for name := range shards {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatalf("unable to remove shard: %v", err)
+		}
+	}
+	for pass := 0; pass < 2; pass++ {
+		var paths []string
+		for {
+			next, err := source.Next()
+			var recordErr *types.RecordError
+			if errors.As(err, &recordErr) {
+				if recordErr.Line != 2 || !strings.Contains(err.Error(), "part-1.json.zst") {
+					t.Fatalf("expected a record error on line 2 of the zstd shard, got %v", err)
+				}
+				continue
+			}
+			if errors.Is(err, types.ErrSourceExhausted) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			paths = append(paths, next.Path)
+			if next.Path == "/long" && len(next.Body) != 100*1024 {
+				t.Fatalf("expected the long body to be read whole, got %d bytes", len(next.Body))
+			}
+		}
+		if expected := []string{"/1", "/long", "/3", "/4"}; !slices.Equal(paths, expected) {
+			t.Fatalf("expected %v, got %v", expected, paths)
+		}
+		if err := source.Reset(); err != nil {
+			t.Fatalf("unexpected reset error: %v", err)
+		}
+	}
+
+	if _, err := NewStreamReader(t.TempDir(), StreamOptions{}); err == nil {
+		t.Fatalf("expected error for a directory without shards")
+	}
+}
+
+func TestStreamReaderReadsRecordsFromCompressedFrames(t *testing.T) {
+	dir := t.TempDir()
+	var lines []string
+	for i := 0; i < 30; i++ {
+		lines = append(lines, fmt.Sprintf(`{"method":"GET","path":"/%d"}`, i))
+	}
+	content := strings.Join(lines, "\n") + "\n"
+
+	// Both shards are split into frames every 100 bytes, in the middle of records.
+	var gzipped, zstdCompressed bytes.Buffer
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("unable to create zstd encoder: %v", err)
+	}
+	for start := 0; start < len(content); start += 100 {
+		chunk := []byte(content[start:min(start+100, len(content))])
+		gz := gzip.NewWriter(&gzipped)
+		gz.Write(chunk)
+		gz.Close()
+		zstdCompressed.Write(encoder.EncodeAll(chunk, nil))
+		// A skippable frame of 3 bytes.
+		zstdCompressed.Write([]byte{0x50, 0x2a, 0x4d, 0x18, 3, 0, 0, 0, 1, 2, 3})
+	}
+	for name, compressed := range map[string][]byte{"part-0.json.gz": gzipped.Bytes(),
+		"part-1.json.zst": zstdCompressed.Bytes()} {
+		if err := os.WriteFile(filepath.Join(dir, name), compressed, 0o644); err != nil {
+			t.Fatalf("unable to write shard: %v", err)
+		}
+	}
+
+	source, err := NewStreamReader(dir, StreamOptions{StopAtEOF: true, Shuffle: true, Seed: 5})
+	if err != nil {
+		t.Fatalf("unable to create stream reader: %v", err)
+	}
+	if frames := len(source.(*StreamReader).frames); frames < 20 {
+		t.Fatalf("expected a frame per gzip member and zstd frame, got %d", frames)
+	}
+	counts := map[string]int{}
+	for {
+		next, err := source.Next()
+		if errors.Is(err, types.ErrSourceExhausted) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		counts[next.Path]++
+	}
+	if len(counts) != len(lines) {
+		t.Fatalf("expected %d distinct records, got %d", len(lines), len(counts))
+	}
+	for path, count := range counts {
+		if count != 2 {
+			t.Fatalf("expected every record once per shard, got %d for %s", count, path)
+		}
+	}
+}
+
+func TestStreamReaderRejectsShufflingLargeSingleFrameShards(t *testing.T) {
+	limit := maxShuffledFrameBytes
+	maxShuffledFrameBytes = 1024
+	t.Cleanup(func() { maxShuffledFrameBytes = limit })
+
+	content := strings.Join(numberedRequests(60), "\n") + "\n"
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(content))
+	gz.Close()
+	file := filepath.Join(t.TempDir(), "requests.json.gz")
+	if err := os.WriteFile(file, gzipped.Bytes(), 0o644); err != nil {
+		t.Fatalf("unable to write shard: %v", err)
+	}
+
+	if _, err := NewStreamReader(file, StreamOptions{Shuffle: true}); err == nil ||
+		!strings.Contains(err.Error(), "bgzip") {
+		t.Fatalf("expected error for shuffling a single %d byte frame, got %v", len(content), err)
+	}
+	source, err := NewStreamReader(file, StreamOptions{StopAtEOF: true})
+	if err != nil {
+		t.Fatalf("expected the single frame to be read in order, got %v", err)
+	}
+	for i := 0; i < 60; i++ {
+		if _, err := source.Next(); err != nil {
+			t.Fatalf("unexpected error reading record %d: %v", i, err)
+		}
+	}
+}
+
 func assertRequest(t *testing.T, req types.RequestSpec, method string, path string) {
 	t.Helper()
 	if req.Method != method {
@@ -0,0 +1,113 @@
+package requests
+
+import (
+	"fmt"
+	"github.com/PeladoCollado/imager/types"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// placeholderPattern matches a ${feeder} or ${feeder.field} template placeholder.
+var placeholderPattern = regexp.MustCompile(`\$\{([^}.]+)(?:\.([^}]+))?\}`)
+
+// TemplateSource is an instance of RequestSource that fills a request template from data feeders. The template's
+// path, query string, header values and body may contain ${feeder} placeholders, or ${feeder.column} for CSV
+// feeders. Each request draws one set of values from every feeder it references.
+type TemplateSource struct {
+	lock     sync.Mutex
+	template types.RequestSpec
+	feeders  map[string]Feeder
+	// used lists the referenced feeders in a fixed order, so feeders are advanced deterministically.
+	used []string
+}
+
+func NewTemplateSource(template types.RequestSpec, feeders map[string]Feeder) (types.RequestSource, error) {
+	if template.Scenario != nil {
+		return nil, fmt.Errorf("templates cannot be scenarios")
+	}
+	// Placeholders only fill text, so the body fields ValidateBody checks are the same in every rendered request
+	// and are checked once here.
+	if err := template.ValidateBody(); err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]struct{})
+	for _, text := range templateTexts(template) {
+		for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			feeder, ok := feeders[match[1]]
+			if !ok {
+				return nil, fmt.Errorf("placeholder %s references unknown feeder %s", match[0], match[1])
+			}
+			if !slices.Contains(feeder.Fields(), match[2]) {
+				return nil, fmt.Errorf("placeholder %s references a field that feeder %s does not have", match[0],
+					match[1])
+			}
+			referenced[match[1]] = struct{}{}
+		}
+	}
+	return &TemplateSource{
+		template: template,
+		feeders:  feeders,
+		used:     slices.Sorted(maps.Keys(referenced)),
+	}, nil
+}
+
+func (t *TemplateSource) Next() (types.RequestSpec, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	values := make(map[string]map[string]string, len(t.used))
+	for _, name := range t.used {
+		feederValues, err := t.feeders[name].Next()
+		if err != nil {
+			return types.RequestSpec{}, fmt.Errorf("feeder %s: %w", name, err)
+		}
+		values[name] = feederValues
+	}
+	fill := func(text string) string {
+		return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+			match := placeholderPattern.FindStringSubmatch(placeholder)
+			return values[match[1]][match[2]]
+		})
+	}
+
+	next := t.template
+	next.Path = fill(next.Path)
+	next.QueryString = fill(next.QueryString)
+	next.Body = fill(next.Body)
+	if len(next.Headers) > 0 {
+		headers := make(map[string][]string, len(next.Headers))
+		for key, headerValues := range next.Headers {
+			filled := make([]string, len(headerValues))
+			for i, value := range headerValues {
+				filled[i] = fill(value)
+			}
+			headers[key] = filled
+		}
+		next.Headers = headers
+	}
+	return next, nil
+}
+
+func (t *TemplateSource) Reset() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, name := range t.used {
+		if err := t.feeders[name].Reset(); err != nil {
+			return fmt.Errorf("feeder %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// templateTexts returns the parts of a template that may contain placeholders.
+func templateTexts(template types.RequestSpec) []string {
+	texts := []string{template.Path, template.QueryString, template.Body}
+	for _, values := range template.Headers {
+		texts = append(texts, values...)
+	}
+	return slices.DeleteFunc(texts, func(text string) bool {
+		return !strings.Contains(text, "${")
+	})
+}
@@ -0,0 +1,174 @@
+package requests
+
+import (
+	"errors"
+	"github.com/PeladoCollado/imager/types"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func writeCSV(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "users.csv")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("unable to write csv file: %v", err)
+	}
+	return path
+}
+
+func newTestFeeders(t *testing.T, configs map[string]FeederConfig) map[string]Feeder {
+	t.Helper()
+	feeders := make(map[string]Feeder, len(configs))
+	for name, cfg := range configs {
+		feeder, err := NewFeeder(cfg)
+		if err != nil {
+			t.Fatalf("unable to create feeder %s: %v", name, err)
+		}
+		feeders[name] = feeder
+	}
+	return feeders
+}
+
+func TestTemplateSourceFillsPlaceholdersFromFeeders(t *testing.T) {
+	t.Setenv("IMAGER_TEST_REGION", "eu-west-1")
+	step := int64(10)
+	feeders := newTestFeeders(t, map[string]FeederConfig{
+		"user":    {Type: FeederCSV, File: writeCSV(t, "id,name\n1,ada\n2,grace\n")},
+		"page":    {Type: FeederCounter, Start: 5, Step: &step},
+		"request": {Type: FeederUUID},
+		"amount":  {Type: FeederRandomInt, Min: 3, Max: 7},
+		"code":    {Type: FeederRandomString, MinLength: 4, MaxLength: 4, Charset: "ab"},
+		"now":     {Type: FeederTimestamp, Format: "unix"},
+		"region":  {Type: FeederEnv, Variable: "IMAGER_TEST_REGION"},
+	})
+	source, err := NewTemplateSource(types.RequestSpec{
+		Method:      "POST",
+		Path:        "/users/${user.id}/orders",
+		QueryString: "page=${page}&region=${region}",
+		Headers:     map[string][]string{"X-Request-Id": {"${request}"}},
+		Body:        `{"name":"${user.name}","amount":${amount},"code":"${code}","at":${now},"user":${user.id}}`,
+		Name:        "create-order",
+	}, feeders)
+	if err != nil {
+		t.Fatalf("unexpected template error: %v", err)
+	}
+
+	body := regexp.MustCompile(`^\{"name":"(\w+)","amount":(\d+),"code":"[ab]{4}","at":\d+,"user":(\d)\}$`)
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	expected := []struct {
+		id   string
+		name string
+		page string
+	}{{"1", "ada", "5"}, {"2", "grace", "15"}, {"1", "ada", "25"}}
+	for _, want := range expected {
+		next, err := source.Next()
+		if err != nil {
+			t.Fatalf("unexpected request error: %v", err)
+		}
+		if next.Path != "/users/"+want.id+"/orders" || next.QueryString != "page="+want.page+"&region=eu-west-1" {
+			t.Fatalf("unexpected path and query %s?%s", next.Path, next.QueryString)
+		}
+		match := body.FindStringSubmatch(next.Body)
+		if match == nil || match[1] != want.name || match[3] != want.id {
+			t.Fatalf("unexpected body %s", next.Body)
+		}
+		if amount, _ := strconv.Atoi(match[2]); amount < 3 || amount > 7 {
+			t.Fatalf("expected amount within [3, 7], got %d", amount)
+		}
+		if !uuid.MatchString(next.Headers["X-Request-Id"][0]) {
+			t.Fatalf("expected a v4 UUID header, got %s", next.Headers["X-Request-Id"][0])
+		}
+		if next.Method != "POST" || next.Name != "create-order" {
+			t.Fatalf("expected literal fields to be kept, got %+v", next)
+		}
+	}
+
+	if err := source.Reset(); err != nil {
+		t.Fatalf("unexpected reset error: %v", err)
+	}
+	if next, _ := source.Next(); next.Path != "/users/1/orders" || next.QueryString != "page=5&region=eu-west-1" {
+		t.Fatalf("expected reset to restart the feeders, got %s?%s", next.Path, next.QueryString)
+	}
+}
+
+func TestCSVFeederModes(t *testing.T) {
+	file := writeCSV(t, "id\n1\n2\n3\n")
+
+	unique := newTestFeeders(t, map[string]FeederConfig{"id": {Type: FeederCSV, File: file, Mode: CSVUnique}})["id"]
+	for _, expected := range []string{"1", "2", "3"} {
+		values, err := unique.Next()
+		if err != nil || values["id"] != expected {
+			t.Fatalf("expected unique row %s, got %v (%v)", expected, values, err)
+		}
+	}
+	if _, err := unique.Next(); !errors.Is(err, ErrFeederExhausted) || !errors.Is(err, types.ErrSourceExhausted) {
+		t.Fatalf("expected the unique feeder to be exhausted, got %v", err)
+	}
+
+	random := newTestFeeders(t, map[string]FeederConfig{"id": {Type: FeederCSV, File: file, Mode: CSVRandom}})["id"]
+	seen := make(map[string]int)
+	for i := 0; i < 300; i++ {
+		values, err := random.Next()
+		if err != nil {
+			t.Fatalf("unexpected random row error: %v", err)
+		}
+		seen[values["id"]]++
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expected every row to be picked, got %v", seen)
+	}
+}
+
+func TestNewFeederRejectsInvalidConfigs(t *testing.T) {
+	invalid := []FeederConfig{
+		{Type: "faker"},
+		{Type: FeederCSV},
+		{Type: FeederCSV, File: writeCSV(t, "id\n1\n"), Mode: "shuffled"},
+		{Type: FeederCSV, File: writeCSV(t, "id\n")},
+		{Type: FeederCSV, File: filepath.Join(t.TempDir(), "missing.csv")},
+		{Type: FeederRandomInt, Min: 5, Max: 1},
+		{Type: FeederRandomInt, Min: math.MinInt64, Max: math.MaxInt64},
+		{Type: FeederRandomInt, Min: -1, Max: math.MaxInt64},
+		{Type: FeederRandomString, MinLength: 3, MaxLength: 2},
+		{Type: FeederEnv},
+		{Type: FeederEnv, Variable: "IMAGER_TEST_UNSET_VARIABLE"},
+	}
+	for _, cfg := range invalid {
+		if _, err := NewFeeder(cfg); err == nil {
+			t.Fatalf("expected feeder config %+v to be rejected", cfg)
+		}
+	}
+	widest, err := NewFeeder(FeederConfig{Type: FeederRandomInt, Min: math.MinInt64, Max: -2})
+	if err != nil {
+		t.Fatalf("unexpected error for the widest random-int range: %v", err)
+	}
+	if _, err := widest.Next(); err != nil {
+		t.Fatalf("unexpected random-int error: %v", err)
+	}
+}
+
+func TestNewTemplateSourceRejectsInvalidTemplates(t *testing.T) {
+	feeders := newTestFeeders(t, map[string]FeederConfig{
+		"user": {Type: FeederCSV, File: writeCSV(t, "id\n1\n")},
+		"page": {Type: FeederCounter},
+	})
+	invalid := []types.RequestSpec{
+		{Path: "/items/${item}"},
+		{Path: "/users/${user}"},
+		{Path: "/users/${user.email}"},
+		{Path: "/", QueryString: "page=${page.next}"},
+		{Scenario: &types.Scenario{}},
+		{Path: "/", Body: "${user.id}", BodyBase64: "aWQ="},
+		{Path: "/", BodyBase64: "${user.id}"},
+		{Path: "/", BodyFile: "missing-body.json"},
+	}
+	for _, template := range invalid {
+		if _, err := NewTemplateSource(template, feeders); err == nil {
+			t.Fatalf("expected template %+v to be rejected", template)
+		}
+	}
+}
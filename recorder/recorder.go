@@ -0,0 +1,279 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/PeladoCollado/imager/orchestrator/logger"
+	"github.com/PeladoCollado/imager/types"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+// hopByHopHeaders only apply to a single connection, so they are never recorded.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+type recorderConfig struct {
+	ListenPort   int
+	Upstream     string
+	Output       string
+	SampleRate   float64
+	IncludePath  string
+	ExcludePath  string
+	RedactHeader string
+	RedactValue  string
+	DropHeaders  string
+	MaxBodyBytes int64
+}
+
+func defaultRecorderConfig() recorderConfig {
+	return recorderConfig{
+		ListenPort:   8080,
+		Output:       "requests.json",
+		SampleRate:   1,
+		RedactHeader: "Authorization,Cookie,X-Api-Key",
+		RedactValue:  "REDACTED",
+		MaxBodyBytes: 1 << 20,
+	}
+}
+
+func main() {
+	cfg := defaultRecorderConfig()
+	flag.IntVar(&cfg.ListenPort, "listen-port", cfg.ListenPort, "The port to accept proxied traffic on")
+	flag.StringVar(&cfg.Upstream, "upstream", cfg.Upstream,
+		"Absolute URL of the service to forward traffic to, e.g. http://api.staging:8080")
+	flag.StringVar(&cfg.Output, "output", cfg.Output,
+		"File that recorded requests are appended to, one JSON request spec per line")
+	flag.Float64Var(&cfg.SampleRate, "sample-rate", cfg.SampleRate,
+		"Share of matching requests to record, between 0 and 1 (every request is still forwarded)")
+	flag.StringVar(&cfg.IncludePath, "include-path", cfg.IncludePath,
+		"Regular expression request paths must match to be recorded (empty records every path)")
+	flag.StringVar(&cfg.ExcludePath, "exclude-path", cfg.ExcludePath,
+		"Regular expression of request paths not to record, e.g. ^/(healthz|metrics)")
+	flag.StringVar(&cfg.RedactHeader, "redact-headers", cfg.RedactHeader,
+		"Comma-separated request headers recorded with their value replaced by redact-value")
+	flag.StringVar(&cfg.RedactValue, "redact-value", cfg.RedactValue, "Value recorded for redacted headers")
+	flag.StringVar(&cfg.DropHeaders, "drop-headers", cfg.DropHeaders,
+		"Comma-separated request headers left out of recordings entirely")
+	flag.Int64Var(&cfg.MaxBodyBytes, "max-body-bytes", cfg.MaxBodyBytes,
+		"Requests with larger bodies are forwarded but not recorded")
+	flag.Parse()
+
+	if err := run(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg recorderConfig) error {
+	upstream, err := url.Parse(cfg.Upstream)
+	if err != nil || !upstream.IsAbs() {
+		return fmt.Errorf("upstream must be an absolute URL")
+	}
+	out, err := os.OpenFile(cfg.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("unable to open output: %w", err)
+	}
+	defer out.Close()
+	rec, err := newRecorder(cfg, out)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.ListenPort),
+		Handler: rec.handler(newProxy(upstream)),
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	logger.Logger.Info("Recording traffic", cfg.Output, upstream.String())
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	logger.Logger.Info("Recorded requests", rec.recordedCount())
+	return nil
+}
+
+// newProxy forwards every request to upstream, keeping the request path under the upstream URL's path.
+func newProxy(upstream *url.URL) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(upstream)
+			r.SetXForwarded()
+		},
+	}
+}
+
+// recorder writes a sample of the requests passing through it as types.RequestSpec lines that a
+// requests.StreamReader can replay.
+type recorder struct {
+	lock     sync.Mutex
+	encoder  *json.Encoder
+	rng      *rand.Rand
+	recorded int
+
+	sampleRate   float64
+	includePath  *regexp.Regexp
+	excludePath  *regexp.Regexp
+	redact       map[string]struct{}
+	redactValue  string
+	drop         map[string]struct{}
+	maxBodyBytes int64
+}
+
+func newRecorder(cfg recorderConfig, out io.Writer) (*recorder, error) {
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		return nil, fmt.Errorf("sample-rate must be between 0 and 1")
+	}
+	if cfg.MaxBodyBytes < 0 {
+		return nil, fmt.Errorf("max-body-bytes must be >= 0")
+	}
+	rec := &recorder{
+		encoder:      json.NewEncoder(out),
+		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		sampleRate:   cfg.SampleRate,
+		redact:       headerSet(cfg.RedactHeader),
+		redactValue:  cfg.RedactValue,
+		drop:         headerSet(cfg.DropHeaders),
+		maxBodyBytes: cfg.MaxBodyBytes,
+	}
+	var err error
+	if cfg.IncludePath != "" {
+		if rec.includePath, err = regexp.Compile(cfg.IncludePath); err != nil {
+			return nil, fmt.Errorf("invalid include-path: %w", err)
+		}
+	}
+	if cfg.ExcludePath != "" {
+		if rec.excludePath, err = regexp.Compile(cfg.ExcludePath); err != nil {
+			return nil, fmt.Errorf("invalid exclude-path: %w", err)
+		}
+	}
+	for _, header := range hopByHopHeaders {
+		rec.drop[header] = struct{}{}
+	}
+	// The replayed body sets its own length.
+	rec.drop["Content-Length"] = struct{}{}
+	return rec, nil
+}
+
+// headerSet parses a comma-separated list of header names into a set of canonical names.
+func headerSet(value string) map[string]struct{} {
+	headers := make(map[string]struct{})
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			headers[http.CanonicalHeaderKey(name)] = struct{}{}
+		}
+	}
+	return headers
+}
+
+func (r *recorder) handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if r.selects(req) {
+			if err := r.record(req); err != nil {
+				logger.Logger.Warn("Unable to record request", req.Method, req.URL.Path, err)
+			}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (r *recorder) selects(req *http.Request) bool {
+	if r.includePath != nil && !r.includePath.MatchString(req.URL.Path) {
+		return false
+	}
+	if r.excludePath != nil && r.excludePath.MatchString(req.URL.Path) {
+		return false
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.sampleRate >= 1 || r.rng.Float64() < r.sampleRate
+}
+
+// record writes the request as one line. The body is read up front and put back for the proxy, so a request whose
+// body cannot be recorded is still forwarded intact. Bodies that are not UTF-8 text are recorded as bodyBase64.
+func (r *recorder) record(req *http.Request) error {
+	// The executor escapes the path when it builds the request URL, so the spec holds it decoded.
+	spec := types.RequestSpec{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		QueryString: req.URL.RawQuery,
+	}
+	for name, values := range req.Header {
+		if _, ok := r.drop[name]; ok {
+			continue
+		}
+		if spec.Headers == nil {
+			spec.Headers = make(map[string][]string)
+		}
+		if _, ok := r.redact[name]; ok {
+			spec.Headers[name] = []string{r.redactValue}
+			continue
+		}
+		spec.Headers[name] = values
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		body, err := io.ReadAll(io.LimitReader(req.Body, r.maxBodyBytes+1))
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		if int64(len(body)) > r.maxBodyBytes {
+			return fmt.Errorf("body is larger than %d bytes", r.maxBodyBytes)
+		}
+		if utf8.Valid(body) {
+			spec.Body = string(body)
+		} else {
+			spec.BodyBase64 = base64.StdEncoding.EncodeToString(body)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.encoder.Encode(spec); err != nil {
+		return err
+	}
+	r.recorded++
+	return nil
+}
+
+func (r *recorder) recordedCount() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.recorded
+}
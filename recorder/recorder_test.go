@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PeladoCollado/imager/executor/worker"
+	"github.com/PeladoCollado/imager/metrics"
+	"github.com/PeladoCollado/imager/orchestrator/requests"
+	"github.com/PeladoCollado/imager/types"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func startRecorder(t *testing.T, cfg recorderConfig, upstream http.HandlerFunc) (string, string) {
+	t.Helper()
+	upstreamServer := httptest.NewServer(upstream)
+	t.Cleanup(upstreamServer.Close)
+	upstreamURL, _ := url.Parse(upstreamServer.URL + "/api")
+
+	output := filepath.Join(t.TempDir(), "requests.json")
+	out, err := os.Create(output)
+	if err != nil {
+		t.Fatalf("unable to create output: %v", err)
+	}
+	t.Cleanup(func() { out.Close() })
+	rec, err := newRecorder(cfg, out)
+	if err != nil {
+		t.Fatalf("unexpected recorder error: %v", err)
+	}
+	proxy := httptest.NewServer(rec.handler(newProxy(upstreamURL)))
+	t.Cleanup(proxy.Close)
+	return proxy.URL, output
+}
+
+func readRecording(t *testing.T, output string, count int) []types.RequestSpec {
+	t.Helper()
+	source, err := requests.NewFileReader(output)
+	if err != nil {
+		t.Fatalf("unable to open recording: %v", err)
+	}
+	recorded := make([]types.RequestSpec, count)
+	for i := range recorded {
+		if recorded[i], err = source.Next(); err != nil {
+			t.Fatalf("unable to read recorded request %d: %v", i, err)
+		}
+	}
+	return recorded
+}
+
+func send(t *testing.T, method string, target string, body string, headers map[string]string) string {
+	t.Helper()
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("unable to create request: %v", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("proxied request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	response, _ := io.ReadAll(resp.Body)
+	return string(response)
+}
+
+func TestRecorderWritesReplayableRequests(t *testing.T) {
+	cfg := defaultRecorderConfig()
+	cfg.DropHeaders = "X-Trace"
+	cfg.MaxBodyBytes = 16
+	proxyURL, output := startRecorder(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.Method + " " + r.URL.RequestURI() + " " + r.Header.Get("Authorization") + " " + string(body)))
+	})
+
+	if got := send(t, http.MethodPost, proxyURL+"/orders?dry=true", `{"sku":"a1"}`, map[string]string{
+		"Authorization": "Bearer secret",
+		"Content-Type":  "application/json",
+		"X-Trace":       "abc",
+	}); got != `POST /api/orders?dry=true Bearer secret {"sku":"a1"}` {
+		t.Fatalf("expected the request to be forwarded unchanged, got %q", got)
+	}
+	large := strings.Repeat("x", 17)
+	if got := send(t, http.MethodPut, proxyURL+"/large", large, nil); got != "PUT /api/large  "+large {
+		t.Fatalf("expected a large body to be forwarded in full, got %q", got)
+	}
+	send(t, http.MethodGet, proxyURL+"/search/caf%C3%A9%20bar", "", nil)
+
+	recorded := readRecording(t, output, 3)
+	first := recorded[0]
+	if first.Method != http.MethodPost || first.Path != "/orders" || first.QueryString != "dry=true" ||
+		first.Body != `{"sku":"a1"}` {
+		t.Fatalf("unexpected recorded request: %+v", first)
+	}
+	if first.Headers["Authorization"][0] != "REDACTED" || first.Headers["Content-Type"][0] != "application/json" {
+		t.Fatalf("expected redacted and kept headers, got %v", first.Headers)
+	}
+	if _, ok := first.Headers["X-Trace"]; ok {
+		t.Fatalf("expected dropped header to be left out, got %v", first.Headers)
+	}
+	if _, ok := first.Headers["Content-Length"]; ok {
+		t.Fatalf("expected Content-Length to be left out, got %v", first.Headers)
+	}
+	if second := recorded[1]; second.Method != http.MethodGet || second.Path != "/search/café bar" {
+		t.Fatalf("expected the oversized request to be skipped and the path decoded, got %+v", second)
+	}
+	if third := recorded[2]; third.Method != http.MethodPost {
+		t.Fatalf("expected the recording to loop back to the first request, got %+v", third)
+	}
+}
+
+func TestRecordedRequestsReplayTheirURL(t *testing.T) {
+	proxyURL, output := startRecorder(t, defaultRecorderConfig(), func(w http.ResponseWriter, r *http.Request) {})
+	send(t, http.MethodGet, proxyURL+"/search/caf%C3%A9%20bar?q=a%20b", "", nil)
+	recorded := readRecording(t, output, 1)
+
+	received := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.RequestURI
+	}))
+	defer target.Close()
+	job := types.Job{
+		ID:             "replay",
+		Requests:       recorded,
+		TargetURLs:     []string{target.URL},
+		RatePerSec:     1,
+		DurationMillis: 100,
+	}
+	worker.RunJob(context.Background(), job, metrics.NewPrometheusMetricsCollector(prometheus.NewRegistry()))
+	select {
+	case uri := <-received:
+		if uri != "/search/caf%C3%A9%20bar?q=a%20b" {
+			t.Fatalf("expected the recorded request URI to be replayed as is, got %s", uri)
+		}
+	default:
+		t.Fatalf("expected the replayed request to reach the target")
+	}
+}
+
+func TestRecorderRecordsBinaryBodiesAsBase64(t *testing.T) {
+	proxyURL, output := startRecorder(t, defaultRecorderConfig(), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	send(t, http.MethodPost, proxyURL+"/images", "\x00\x01\xfe\xff", nil)
+	recorded := readRecording(t, output, 1)[0]
+	if recorded.Body != "" || recorded.BodyBase64 != "AAH+/w==" {
+		t.Fatalf("expected the binary body to be recorded as base64, got %+v", recorded)
+	}
+}
+
+func TestRecorderFiltersAndSamples(t *testing.T) {
+	cfg := defaultRecorderConfig()
+	cfg.IncludePath = "^/v1/"
+	cfg.ExcludePath = "^/v1/health"
+	proxyURL, output := startRecorder(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	for _, path := range []string{"/v1/health", "/v2/orders", "/v1/orders"} {
+		send(t, http.MethodGet, proxyURL+path, "", nil)
+	}
+	if recorded := readRecording(t, output, 2); recorded[0].Path != "/v1/orders" || recorded[1].Path != "/v1/orders" {
+		t.Fatalf("expected only the included, non-excluded path, got %+v", recorded)
+	}
+
+	cfg = defaultRecorderConfig()
+	cfg.SampleRate = 0
+	proxyURL, output = startRecorder(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	send(t, http.MethodGet, proxyURL+"/orders", "", nil)
+	if content, _ := os.ReadFile(output); len(content) != 0 {
+		t.Fatalf("expected nothing recorded at sample rate 0, got %q", content)
+	}
+}
+
+func TestNewRecorderRejectsInvalidConfig(t *testing.T) {
+	cfg := defaultRecorderConfig()
+	cfg.SampleRate = 1.5
+	if _, err := newRecorder(cfg, io.Discard); err == nil {
+		t.Fatalf("expected error for a sample rate above 1")
+	}
+	cfg = defaultRecorderConfig()
+	cfg.IncludePath = "("
+	if _, err := newRecorder(cfg, io.Discard); err == nil {
+		t.Fatalf("expected error for an invalid include-path")
+	}
+}
@@ -0,0 +1,70 @@
+package types
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+)
+
+// MultipartPart is one part of a multipart/form-data body. A part holds either an inline Value or the content of
+// File, a path on the executor that is streamed when the request is sent.
+type MultipartPart struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+	File  string `json:"file,omitempty"`
+	// FileName is the file name sent for a file part. It defaults to the base name of File.
+	FileName string `json:"fileName,omitempty"`
+	// ContentType of a file part. It defaults to application/octet-stream.
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// ValidateBody checks that the request sets at most one of Body, BodyBase64, BodyFile and Multipart, that an
+// encoded body decodes, and that every file the body references is present. Referenced files are read from the
+// local file system, so request sources and executors both call it against their own mount of the files.
+func (r RequestSpec) ValidateBody() error {
+	bodies := 0
+	for _, set := range []bool{r.Body != "", r.BodyBase64 != "", r.BodyFile != "", len(r.Multipart) > 0} {
+		if set {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		return fmt.Errorf("request sets more than one of body, bodyBase64, bodyFile and multipart")
+	}
+	if r.BodyBase64 != "" {
+		if _, err := base64.StdEncoding.DecodeString(r.BodyBase64); err != nil {
+			return fmt.Errorf("invalid bodyBase64: %w", err)
+		}
+	}
+	if r.BodyFile != "" {
+		if err := checkBodyFile(r.BodyFile); err != nil {
+			return fmt.Errorf("bodyFile: %w", err)
+		}
+	}
+	for i, part := range r.Multipart {
+		if part.Name == "" {
+			return fmt.Errorf("multipart part %d requires a name", i+1)
+		}
+		if part.File == "" {
+			continue
+		}
+		if part.Value != "" {
+			return fmt.Errorf("multipart part %s sets both value and file", part.Name)
+		}
+		if err := checkBodyFile(part.File); err != nil {
+			return fmt.Errorf("multipart part %s: %w", part.Name, err)
+		}
+	}
+	return nil
+}
+
+func checkBodyFile(file string) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", file)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package types
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateBody(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "upload.bin")
+	if err := os.WriteFile(file, []byte{0x00, 0xff}, 0o644); err != nil {
+		t.Fatalf("unable to write body file: %v", err)
+	}
+	missing := filepath.Join(t.TempDir(), "missing.bin")
+
+	valid := []RequestSpec{
+		{},
+		{Body: "text"},
+		{BodyBase64: "AP8="},
+		{BodyFile: file},
+		{Multipart: []MultipartPart{{Name: "title", Value: "cat"}, {Name: "image", File: file}}},
+	}
+	for _, spec := range valid {
+		if err := spec.ValidateBody(); err != nil {
+			t.Fatalf("expected %+v to be valid, got %v", spec, err)
+		}
+	}
+
+	invalid := map[string]RequestSpec{
+		"more than one":   {Body: "text", BodyFile: file},
+		"invalid base64":  {BodyBase64: "not base64!"},
+		"no such file":    {BodyFile: missing},
+		"not a regular":   {BodyFile: t.TempDir()},
+		"unnamed part":    {Multipart: []MultipartPart{{Value: "cat"}}},
+		"value and file":  {Multipart: []MultipartPart{{Name: "image", Value: "cat", File: file}}},
+		"missing in part": {Multipart: []MultipartPart{{Name: "image", File: missing}}},
+	}
+	for name, spec := range invalid {
+		if err := spec.ValidateBody(); err == nil {
+			t.Fatalf("expected %s to be invalid", name)
+		}
+	}
+
+	scenario := Scenario{Steps: []ScenarioStep{{RequestSpec: RequestSpec{Path: "/upload", BodyFile: missing}}}}
+	if err := scenario.Validate(); err == nil || !strings.Contains(err.Error(), "step-1") {
+		t.Fatalf("expected a scenario step with a missing body file to be invalid, got %v", err)
+	}
+}
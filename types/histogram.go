@@ -0,0 +1,148 @@
+package types
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+	"time"
+)
+
+// histogramSubBucketBits sets the precision of LatencyHistogram: every power of two is split into
+// 2^histogramSubBucketBits linear buckets, so a recorded value is off by less than 1/64 (about 1.6%).
+const histogramSubBucketBits = 6
+
+const histogramSubBuckets = 1 << histogramSubBucketBits
+
+// LatencyHistogram is a compact, mergeable latency distribution. Latencies are recorded in microseconds into
+// HDR-style log-linear buckets, and only buckets that hold at least one value are kept, so its size depends on
+// the spread of the latencies rather than how many were recorded. The zero value is an empty histogram.
+type LatencyHistogram struct {
+	Count     int64 `json:"count"`
+	MinMicros int64 `json:"minMicros"`
+	MaxMicros int64 `json:"maxMicros"`
+	// Buckets maps a bucket index to the number of values recorded in it.
+	Buckets map[int]int64 `json:"buckets,omitempty"`
+}
+
+// PhaseLatencies holds the latency distribution of each request phase. DNS, Connect and TLSHandshake only include
+// requests that opened a new connection.
+type PhaseLatencies struct {
+	DNS          LatencyHistogram `json:"dns,omitzero"`
+	Connect      LatencyHistogram `json:"connect,omitzero"`
+	TLSHandshake LatencyHistogram `json:"tlsHandshake,omitzero"`
+	// FirstByte runs from the request being written to the first response byte.
+	FirstByte LatencyHistogram `json:"firstByte,omitzero"`
+	// Transfer runs from the first response byte until the body was read.
+	Transfer LatencyHistogram `json:"transfer,omitzero"`
+}
+
+func (p *PhaseLatencies) Merge(other PhaseLatencies) {
+	p.DNS.Merge(other.DNS)
+	p.Connect.Merge(other.Connect)
+	p.TLSHandshake.Merge(other.TLSHandshake)
+	p.FirstByte.Merge(other.FirstByte)
+	p.Transfer.Merge(other.Transfer)
+}
+
+// Record adds a latency to the histogram. Negative latencies are recorded as zero.
+func (h *LatencyHistogram) Record(d time.Duration) {
+	h.RecordMicros(d.Microseconds())
+}
+
+func (h *LatencyHistogram) RecordMicros(micros int64) {
+	micros = max(micros, 0)
+	if h.Buckets == nil {
+		h.Buckets = make(map[int]int64)
+	}
+	h.Buckets[histogramBucket(micros)]++
+	if h.Count == 0 || micros < h.MinMicros {
+		h.MinMicros = micros
+	}
+	if micros > h.MaxMicros {
+		h.MaxMicros = micros
+	}
+	h.Count++
+}
+
+// Merge adds every value recorded in other to h.
+func (h *LatencyHistogram) Merge(other LatencyHistogram) {
+	if other.Count == 0 {
+		return
+	}
+	if h.Buckets == nil {
+		h.Buckets = make(map[int]int64, len(other.Buckets))
+	}
+	for bucket, count := range other.Buckets {
+		h.Buckets[bucket] += count
+	}
+	if h.Count == 0 || other.MinMicros < h.MinMicros {
+		h.MinMicros = other.MinMicros
+	}
+	h.MaxMicros = max(h.MaxMicros, other.MaxMicros)
+	h.Count += other.Count
+}
+
+// IsZero reports whether nothing has been recorded, which lets reports omit empty histograms.
+func (h LatencyHistogram) IsZero() bool {
+	return h.Count == 0
+}
+
+// Quantile returns the latency at quantile q (0.99 for p99): the smallest recorded latency that at least q of
+// all recorded latencies are at or below, to within the histogram's precision. It returns 0 for an empty
+// histogram.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	return time.Duration(h.quantileMicros(q)) * time.Microsecond
+}
+
+// QuantileMillis is Quantile in whole milliseconds.
+func (h LatencyHistogram) QuantileMillis(q float64) int64 {
+	return h.Quantile(q).Milliseconds()
+}
+
+func (h LatencyHistogram) quantileMicros(q float64) int64 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(h.Count)))
+	rank = min(max(rank, 1), h.Count)
+
+	buckets := make([]int, 0, len(h.Buckets))
+	for bucket := range h.Buckets {
+		buckets = append(buckets, bucket)
+	}
+	slices.Sort(buckets)
+
+	var seen int64
+	for _, bucket := range buckets {
+		seen += h.Buckets[bucket]
+		if seen >= rank {
+			// The upper bound of a bucket never understates a latency; clamping to the recorded range makes
+			// the extremes exact.
+			return min(max(histogramBucketUpperBound(bucket), h.MinMicros), h.MaxMicros)
+		}
+	}
+	return h.MaxMicros
+}
+
+// histogramBucket returns the index of the bucket that holds a value. Values below histogramSubBuckets get a
+// bucket each; above that every power of two gets histogramSubBuckets equal-width buckets.
+func histogramBucket(value int64) int {
+	if value < histogramSubBuckets {
+		return int(value)
+	}
+	exponent := bits.Len64(uint64(value)) - 1
+	shift := exponent - histogramSubBucketBits
+	subBucket := int(value>>shift) - histogramSubBuckets
+	return histogramSubBuckets + shift*histogramSubBuckets + subBucket
+}
+
+// histogramBucketUpperBound returns the largest value that falls into a bucket.
+func histogramBucketUpperBound(bucket int) int64 {
+	if bucket < histogramSubBuckets {
+		return int64(bucket)
+	}
+	shift := (bucket - histogramSubBuckets) / histogramSubBuckets
+	subBucket := (bucket - histogramSubBuckets) % histogramSubBuckets
+	// Computed unsigned: the top bucket's bound is math.MaxInt64, one below 1<<63.
+	return int64((uint64(histogramSubBuckets+subBucket+1) << shift) - 1)
+}
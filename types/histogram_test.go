@@ -0,0 +1,109 @@
+package types
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogramQuantilesStayWithinPrecision(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	values := make([]int64, 10000)
+	var histogram LatencyHistogram
+	for i := range values {
+		values[i] = int64(rng.ExpFloat64() * 50000)
+		histogram.RecordMicros(values[i])
+	}
+	slices.Sort(values)
+
+	for _, q := range []float64{0.5, 0.9, 0.99, 0.999} {
+		exact := values[int(math.Ceil(q*float64(len(values))))-1]
+		got := histogram.Quantile(q).Microseconds()
+		if got < exact || float64(got-exact) > float64(exact)/histogramSubBuckets+1 {
+			t.Fatalf("quantile %.3f expected within precision of %dus, got %dus", q, exact, got)
+		}
+	}
+	if histogram.Quantile(0) != time.Duration(values[0])*time.Microsecond {
+		t.Fatalf("expected quantile 0 to be the minimum %dus, got %s", values[0], histogram.Quantile(0))
+	}
+	if histogram.Quantile(1) != time.Duration(values[len(values)-1])*time.Microsecond {
+		t.Fatalf("expected quantile 1 to be the maximum %dus, got %s", values[len(values)-1], histogram.Quantile(1))
+	}
+	if len(histogram.Buckets) > 1000 {
+		t.Fatalf("expected a compact histogram, got %d buckets for %d values", len(histogram.Buckets), len(values))
+	}
+}
+
+func TestLatencyHistogramMergeMatchesSingleHistogram(t *testing.T) {
+	var whole, left, right LatencyHistogram
+	for i := int64(1); i <= 1000; i++ {
+		whole.Record(time.Duration(i) * time.Millisecond)
+		if i%2 == 0 {
+			left.Record(time.Duration(i) * time.Millisecond)
+		} else {
+			right.Record(time.Duration(i) * time.Millisecond)
+		}
+	}
+
+	var merged LatencyHistogram
+	merged.Merge(left)
+	merged.Merge(LatencyHistogram{})
+	merged.Merge(right)
+
+	if merged.Count != whole.Count || merged.MinMicros != whole.MinMicros || merged.MaxMicros != whole.MaxMicros {
+		t.Fatalf("expected merged histogram %+v to match %+v", merged, whole)
+	}
+	for _, q := range []float64{0.5, 0.9, 0.99, 0.999} {
+		if merged.Quantile(q) != whole.Quantile(q) {
+			t.Fatalf("quantile %.3f expected %s, got %s", q, whole.Quantile(q), merged.Quantile(q))
+		}
+	}
+	if whole.QuantileMillis(0.99) < 990 || whole.QuantileMillis(0.99) > 1000 {
+		t.Fatalf("expected p99 near 990ms, got %dms", whole.QuantileMillis(0.99))
+	}
+}
+
+func TestLatencyHistogramRoundTripsThroughJSON(t *testing.T) {
+	report := JobReport{JobID: "job-1", RoundID: "round-1"}
+	for _, millis := range []int64{1, 5, 250, 3000} {
+		report.Latency.Record(time.Duration(millis) * time.Millisecond)
+	}
+
+	body, err := json.Marshal(report)
+	if err != nil {
+		t.Fatalf("unable to marshal report: %v", err)
+	}
+	var decoded JobReport
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		t.Fatalf("unable to unmarshal report: %v", err)
+	}
+	if decoded.Latency.Count != 4 || decoded.Latency.QuantileMillis(1) != 3000 {
+		t.Fatalf("expected latency histogram to survive JSON, got %+v", decoded.Latency)
+	}
+	var raw map[string]any
+	if err := json.Unmarshal(body, &raw); err != nil {
+		t.Fatalf("unable to unmarshal report: %v", err)
+	}
+	if _, ok := raw["correctedLatency"]; ok {
+		t.Fatalf("expected empty corrected latency to be omitted, got %s", body)
+	}
+}
+
+func TestLatencyHistogramHandlesExtremeValues(t *testing.T) {
+	var histogram LatencyHistogram
+	histogram.RecordMicros(-5)
+	histogram.RecordMicros(math.MaxInt64)
+
+	if histogram.Quantile(0) != 0 {
+		t.Fatalf("expected negative latency to be recorded as zero, got %s", histogram.Quantile(0))
+	}
+	if histogram.quantileMicros(1) != math.MaxInt64 {
+		t.Fatalf("expected the maximum to be exact, got %d", histogram.quantileMicros(1))
+	}
+	if (LatencyHistogram{}).Quantile(0.99) != 0 {
+		t.Fatalf("expected an empty histogram to report zero")
+	}
+}
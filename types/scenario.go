@@ -0,0 +1,84 @@
+package types
+
+import (
+	"fmt"
+)
+
+// Scenario is an ordered list of steps that an executor runs as one unit for a single session, for flows such as
+// logging in and then using the returned token. Each session has its own cookie jar and variables; steps capture
+// response values into variables with Extract, and later steps reference them as ${name} in their path, query
+// string, header values and body. A scenario stops at its first failed step.
+type Scenario struct {
+	Steps []ScenarioStep `json:"steps"`
+	// Variables seeds the variables of every session.
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// ScenarioStep is one request of a scenario. Its results are reported under its ResultName, or under "step-N"
+// when it has none.
+type ScenarioStep struct {
+	RequestSpec
+	Extract []Extraction `json:"extract,omitempty"`
+}
+
+// Extraction captures a value from a step's response into the session variable Variable. Exactly one of JSONPath,
+// Regex, Header or Cookie selects the value.
+type Extraction struct {
+	Variable string `json:"variable"`
+	// JSONPath selects a value from a JSON response body, e.g. "$.session.token". Strings are captured as-is and
+	// other values as their JSON encoding.
+	JSONPath string `json:"jsonPath,omitempty"`
+	// Regex is matched against the response body (RE2 syntax). The first capture group is captured, or the whole
+	// match if the pattern has no groups.
+	Regex string `json:"regex,omitempty"`
+	// Header names a response header whose first value is captured.
+	Header string `json:"header,omitempty"`
+	// Cookie names a cookie set by the response, or already held by the session, whose value is captured.
+	Cookie string `json:"cookie,omitempty"`
+}
+
+// StepName is the name the step at index i of the scenario is reported under.
+func (s Scenario) StepName(i int) string {
+	if name := s.Steps[i].ResultName(); name != "" {
+		return name
+	}
+	return fmt.Sprintf("step-%d", i+1)
+}
+
+// Validate checks that the scenario can be run: it has steps, none of them is itself a scenario, each body is
+// valid and each extraction names a variable and exactly one value selector.
+func (s Scenario) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("scenario has no steps")
+	}
+	for i, step := range s.Steps {
+		if step.Scenario != nil {
+			return fmt.Errorf("step %s cannot itself be a scenario", s.StepName(i))
+		}
+		if err := step.ValidateBody(); err != nil {
+			return fmt.Errorf("step %s: %w", s.StepName(i), err)
+		}
+		for _, extraction := range step.Extract {
+			if err := extraction.validate(); err != nil {
+				return fmt.Errorf("step %s: %w", s.StepName(i), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e Extraction) validate() error {
+	if e.Variable == "" {
+		return fmt.Errorf("extraction requires a variable name")
+	}
+	selectors := 0
+	for _, selector := range []string{e.JSONPath, e.Regex, e.Header, e.Cookie} {
+		if selector != "" {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return fmt.Errorf("extraction of %s requires exactly one of jsonPath, regex, header or cookie", e.Variable)
+	}
+	return nil
+}
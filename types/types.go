@@ -1,6 +1,8 @@
 package types
 
 import (
+	"errors"
+	"fmt"
 	"time"
 )
 
@@ -10,6 +12,102 @@ type RequestSpec struct {
 	QueryString string              `json:"queryString,omitempty"`
 	Headers     map[string][]string `json:"headers,omitempty"`
 	Body        string              `json:"body,omitempty"`
+	// BodyBase64 is a binary body, such as protobuf or an image, encoded as standard base64.
+	BodyBase64 string `json:"bodyBase64,omitempty"`
+	// BodyFile is the path of a file on the executor that is streamed as the body, for uploads too large to send
+	// inline with every job.
+	BodyFile string `json:"bodyFile,omitempty"`
+	// Multipart builds a multipart/form-data body and sets its Content-Type header. A request sets at most one of
+	// Body, BodyBase64, BodyFile and Multipart; see ValidateBody.
+	Multipart []MultipartPart `json:"multipart,omitempty"`
+	// Source names the traffic class the request was drawn from when it comes from a weighted request mix.
+	Source string `json:"source,omitempty"`
+	// Name groups results for reporting, e.g. "search" or "checkout". Tags are free-form attributes that are passed
+	// to metrics collectors but not used as Prometheus labels.
+	Name string            `json:"name,omitempty"`
+	Tags map[string]string `json:"tags,omitempty"`
+	// Expect holds optional checks the response must pass to count as a success.
+	Expect *Expectation `json:"expect,omitempty"`
+	// DelayMillis is the recorded pause between the previous request and this one, e.g. from a HAR capture. Only
+	// the recorded arrival process uses it.
+	DelayMillis int64 `json:"delayMillis,omitempty"`
+	// Scenario, when set, makes this spec a multi-step scenario run as one unit; the request fields above are then
+	// ignored and Name names the scenario.
+	Scenario *Scenario `json:"scenario,omitempty"`
+}
+
+// Expectation validates a response beyond its status code. Every configured check must pass; a response that
+// fails one is reported as an assertion failure.
+type Expectation struct {
+	// Status lists the accepted status codes. When empty, any status below 300 is accepted.
+	Status []int `json:"status,omitempty"`
+	// BodyContains is a substring the response body must contain.
+	BodyContains string `json:"bodyContains,omitempty"`
+	// BodyMatches is a regular expression (RE2 syntax) the response body must match.
+	BodyMatches string `json:"bodyMatches,omitempty"`
+	// JSONPath checks values in a JSON response body.
+	JSONPath []JSONPathExpectation `json:"jsonPath,omitempty"`
+	// Headers lists response headers that must be present. A non-empty value must also match exactly.
+	Headers map[string]string `json:"headers,omitempty"`
+	// MaxBodyBytes is the largest accepted response body. Zero means unlimited.
+	MaxBodyBytes int64 `json:"maxBodyBytes,omitempty"`
+}
+
+// JSONPathExpectation requires the value at Path, e.g. "$.items[0].status", to equal Equals.
+type JSONPathExpectation struct {
+	Path   string `json:"path"`
+	Equals any    `json:"equals"`
+}
+
+// ResultName is the name results of this request are reported under: its Name, or else its Source.
+func (r RequestSpec) ResultName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.Source
+}
+
+const (
+	// LoadModelOpen sends requests at a fixed arrival rate regardless of how quickly the target responds.
+	LoadModelOpen = "open"
+	// LoadModelClosed runs a fixed number of virtual users that each loop request -> think time -> request.
+	LoadModelClosed = "closed"
+)
+
+const (
+	ThinkTimeConstant    = "constant"
+	ThinkTimeUniform     = "uniform"
+	ThinkTimeExponential = "exponential"
+)
+
+// ThinkTime describes the pause a virtual user takes between receiving a response and sending its next request.
+type ThinkTime struct {
+	Distribution string `json:"distribution,omitempty"`
+	MeanMillis   int64  `json:"meanMillis,omitempty"`
+	MinMillis    int64  `json:"minMillis,omitempty"`
+	MaxMillis    int64  `json:"maxMillis,omitempty"`
+}
+
+const (
+	// ArrivalUniform spaces requests evenly at the job rate.
+	ArrivalUniform = "uniform"
+	// ArrivalPoisson draws exponentially distributed inter-arrival gaps with a mean of 1/rate.
+	ArrivalPoisson = "poisson"
+	// ArrivalBurst sends requests in back-to-back bursts of BurstSize, with bursts spaced so the average
+	// rate is unchanged.
+	ArrivalBurst = "burst"
+	// ArrivalRecorded replays each request's DelayMillis as the gap since the previous request. The orchestrator
+	// lays the requests out on one timeline across rounds and ships their send times in Job.OffsetsMillis; requests
+	// without a delay are spaced at the rate.
+	ArrivalRecorded = "recorded"
+)
+
+// ArrivalProcess selects how an open-model job spreads its requests over time.
+type ArrivalProcess struct {
+	Type      string `json:"type,omitempty"`
+	BurstSize int    `json:"burstSize,omitempty"`
+	// Seed makes the generated gaps reproducible. Zero seeds from the clock.
+	Seed int64 `json:"seed,omitempty"`
 }
 
 type Job struct {
@@ -19,6 +117,18 @@ type Job struct {
 	TargetURLs     []string      `json:"targetUrls"`
 	RatePerSec     int           `json:"ratePerSec"`
 	DurationMillis int64         `json:"durationMillis"`
+
+	LoadModel    string          `json:"loadModel,omitempty"`
+	Arrival      *ArrivalProcess `json:"arrival,omitempty"`
+	VirtualUsers int             `json:"virtualUsers,omitempty"`
+	ThinkTime    *ThinkTime      `json:"thinkTime,omitempty"`
+
+	// OffsetsMillis holds the send time of each request, relative to the job start, for the recorded arrival
+	// process.
+	OffsetsMillis []int64 `json:"offsetsMillis,omitempty"`
+
+	// RequestTimeoutMillis bounds each request of the job, overriding the executor's default. Zero keeps the default.
+	RequestTimeoutMillis int64 `json:"requestTimeoutMillis,omitempty"`
 }
 
 type RequestSource interface {
@@ -26,27 +136,92 @@ type RequestSource interface {
 	Reset() error
 }
 
+// ErrSourceExhausted is returned by a RequestSource that has no requests left. The run completes once its source
+// is exhausted.
+var ErrSourceExhausted = errors.New("request source exhausted")
+
+// RecordError is returned by a RequestSource for a malformed record. The record is skipped, and the next call
+// moves on to the following one.
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
 func (j Job) Duration() time.Duration {
 	return time.Duration(j.DurationMillis) * time.Millisecond
 }
 
+func (j Job) RequestTimeout() time.Duration {
+	return time.Duration(j.RequestTimeoutMillis) * time.Millisecond
+}
+
 func (j Job) RequestedCount() int {
 	return len(j.Requests)
 }
 
+// Mean returns the expected think time for the configured distribution.
+func (t ThinkTime) Mean() time.Duration {
+	if t.Distribution == ThinkTimeUniform {
+		return time.Duration(t.MinMillis+t.MaxMillis) * time.Millisecond / 2
+	}
+	return time.Duration(t.MeanMillis) * time.Millisecond
+}
+
 type WorkerId struct {
 	Id      string `json:"id"`
 	Workers int    `json:"workers"`
 }
 
 type JobReport struct {
-	ExecutorID        string  `json:"executorId,omitempty"`
-	JobID             string  `json:"jobId"`
-	RoundID           string  `json:"roundId"`
-	PlannedRequests   int     `json:"plannedRequests"`
-	CompletedRequests int     `json:"completedRequests"`
-	SuccessCount      int     `json:"successCount"`
-	FailureCount      int     `json:"failureCount"`
-	TimeoutCount      int     `json:"timeoutCount"`
-	LatencyMillis     []int64 `json:"latencyMillis,omitempty"`
+	ExecutorID        string `json:"executorId,omitempty"`
+	JobID             string `json:"jobId"`
+	RoundID           string `json:"roundId"`
+	PlannedRequests   int    `json:"plannedRequests"`
+	CompletedRequests int    `json:"completedRequests"`
+	SuccessCount      int    `json:"successCount"`
+	FailureCount      int    `json:"failureCount"`
+	TimeoutCount      int    `json:"timeoutCount"`
+	// AssertionFailureCount counts the failures caused by a response failing its RequestSpec.Expect checks.
+	AssertionFailureCount int `json:"assertionFailureCount,omitempty"`
+	// Errors counts the failed requests by error category, such as "timeout" or "server_error".
+	Errors map[string]int `json:"errors,omitempty"`
+
+	// Latency holds service times, measured from when each request was actually sent.
+	Latency LatencyHistogram `json:"latency,omitzero"`
+	// CorrectedLatency holds latencies measured from each request's scheduled start time, so they include any
+	// time a request spent waiting behind a stalled target or a saturated executor.
+	CorrectedLatency LatencyHistogram `json:"correctedLatency,omitzero"`
+	// Phases breaks request time down into connection setup and server phases.
+	Phases PhaseLatencies `json:"phases,omitzero"`
+
+	MeanSendLagMillis int64 `json:"meanSendLagMillis"`
+	MaxSendLagMillis  int64 `json:"maxSendLagMillis"`
+
+	// ThroughputRPS is the achieved completion rate for the job, comparable across open and closed models.
+	ThroughputRPS float64 `json:"throughputRps"`
+
+	// Names breaks the results down by RequestSpec.ResultName. Unnamed requests are only counted in the totals.
+	Names map[string]NamedResults `json:"names,omitempty"`
+	// Steps breaks scenario results down by step, keyed "<scenario>/<step>". The totals and Names count each
+	// scenario run once, as a single unit.
+	Steps map[string]NamedResults `json:"steps,omitempty"`
+}
+
+// NamedResults holds the results of the requests in a job that share a name.
+type NamedResults struct {
+	CompletedRequests int              `json:"completedRequests"`
+	SuccessCount      int              `json:"successCount"`
+	FailureCount      int              `json:"failureCount"`
+	TimeoutCount      int              `json:"timeoutCount"`
+	Latency           LatencyHistogram `json:"latency,omitzero"`
+
+	AssertionFailureCount int `json:"assertionFailureCount,omitempty"`
 }